@@ -0,0 +1,86 @@
+package app
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/auth"
+	"time"
+)
+
+// adminScope must be granted to manage API keys.
+const adminScope = "poi:admin"
+
+type apiKeyRequest struct {
+	Name    string   `json:"name"`
+	Subject string   `json:"subject"`
+	Scopes  []string `json:"scopes"`
+	// ExpiresIn is the lifetime of the key in seconds, 0 means the key never expires.
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+type apiKeyResponse struct {
+	auth.ApiKey
+	// Key must be handed to the client, it is only returned once.
+	Key string `json:"key"`
+}
+
+func (a *App) createApiKey(rw http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+	if err := decode(r, &req); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if req.Subject == "" || req.ExpiresIn < 0 {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	key, secret, err := auth.NewApiKey(req.Name, req.Subject, req.Scopes, time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("generating api key failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.apiKeys.Add(r.Context(), key); err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("storing api key failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("id", key.Id).Str("subject", key.Subject).Str("createdBy", auth.SubjectFromContext(r.Context())).Msg("api key created")
+
+	rw.WriteHeader(http.StatusCreated)
+	encode(rw, &apiKeyResponse{ApiKey: key, Key: secret})
+}
+
+func (a *App) listApiKeys(rw http.ResponseWriter, r *http.Request) {
+	keys, err := a.apiKeys.List(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("listing api keys failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &keys)
+}
+
+func (a *App) deleteApiKey(rw http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := a.apiKeys.Delete(r.Context(), id)
+	if err == auth.ApiKeyNotFound {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("deleting api key failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("id", id).Str("deletedBy", auth.SubjectFromContext(r.Context())).Msg("api key deleted")
+	rw.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiKeys(t *testing.T) {
+	components := testComponents(t, handler.NewMockPoiHandler(gomock.NewController(t)), adminScope)
+	router := newTestRouter(t, components)
+
+	assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/admin/apikeys", apiKeyRequest{Name: "no subject"}).Code)
+
+	w := serve(router, http.MethodPost, "/admin/apikeys", apiKeyRequest{Name: "importer", Subject: "importer", Scopes: []string{"poi:write"}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created apiKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Key)
+
+	// the key authenticates the client
+	req := httptest.NewRequest(http.MethodGet, "/v1/pois/1", nil)
+	req.Header.Set(auth.ApiKeyHeader, created.Key)
+	principal, err := auth.NewApiKeyAuthenticator(components.ApiKeys).Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "importer", principal.Subject)
+
+	w = serve(router, http.MethodGet, "/admin/apikeys", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var keys []auth.ApiKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	require.Len(t, keys, 1)
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodDelete, "/admin/apikeys/"+keys[0].Id, nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodDelete, "/admin/apikeys/"+keys[0].Id, nil).Code)
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"net/http"
+	"poi-service/cmd/audit"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/dedup"
+	"poi-service/cmd/events"
+	"poi-service/cmd/geofence"
+	"poi-service/cmd/handler"
+	"poi-service/cmd/health"
+	"poi-service/cmd/idempotency"
+	"poi-service/cmd/logging"
+	"poi-service/cmd/metrics"
+	"poi-service/cmd/ratelimit"
+	"poi-service/cmd/requestid"
+	"reflect"
+)
+
+// ServiceName identifies the service in the traces.
+const ServiceName = "poi-service"
+
+// MissingComponent is given if a required component is nil.
+const MissingComponent = MissingComponentError("component missing")
+
+type MissingComponentError string
+
+func (e MissingComponentError) Error() string { return string(e) }
+
+// Components are the dependencies of the App. All of them are required except DevIssuer and the rate limiters.
+type Components struct {
+	Pois       handler.PoiHandler
+	Authorizer auth.Authorizer
+	ApiKeys    auth.ApiKeyStore
+	AuditLog   *audit.Log
+	EventBus   *events.Bus
+	Webhooks   events.WebhookStore
+	Dispatcher *events.Dispatcher
+	Geofences  *geofence.Registry
+	Health     *health.Checker
+	// Duplicates finds and merges pois stored twice.
+	Duplicates *dedup.Deduplicator
+	// IdempotencyKeys makes retries of creating pois safe.
+	IdempotencyKeys *idempotency.Keys
+	// DevIssuer serves tokens to everyone if set. Never use in production!
+	DevIssuer *auth.DevIssuer
+	// IpRateLimiter limits the requests of every IP address before the authorization if set, so requests that are
+	// not authenticated are limited as well.
+	IpRateLimiter *ratelimit.Limiter
+	// RateLimiter limits the requests of every principal after the authorization if set.
+	RateLimiter *ratelimit.Limiter
+}
+
+// App serves the HTTP api of the service. It does not start background tasks, these are run by the owner of the
+// components.
+type App struct {
+	pois       handler.PoiHandler
+	authorizer auth.Authorizer
+	apiKeys    auth.ApiKeyStore
+	auditLog   *audit.Log
+	eventBus   *events.Bus
+	webhooks   events.WebhookStore
+	dispatcher *events.Dispatcher
+	geofences  *geofence.Registry
+	health     *health.Checker
+	keys       *idempotency.Keys
+	duplicates *dedup.Deduplicator
+	devIssuer  *auth.DevIssuer
+	ipLimiter  *ratelimit.Limiter
+	limiter    *ratelimit.Limiter
+}
+
+// New creates the App or returns MissingComponent if a required component is nil.
+func New(c Components) (*App, error) {
+	required := map[string]interface{}{
+		"Pois":            c.Pois,
+		"Authorizer":      c.Authorizer,
+		"ApiKeys":         c.ApiKeys,
+		"AuditLog":        c.AuditLog,
+		"EventBus":        c.EventBus,
+		"Webhooks":        c.Webhooks,
+		"Dispatcher":      c.Dispatcher,
+		"Geofences":       c.Geofences,
+		"Health":          c.Health,
+		"IdempotencyKeys": c.IdempotencyKeys,
+		"Duplicates":      c.Duplicates,
+	}
+	for name, component := range required {
+		if isNil(component) {
+			return nil, fmt.Errorf("%w: %s", MissingComponent, name)
+		}
+	}
+
+	return &App{
+		pois:       c.Pois,
+		authorizer: c.Authorizer,
+		apiKeys:    c.ApiKeys,
+		auditLog:   c.AuditLog,
+		eventBus:   c.EventBus,
+		webhooks:   c.Webhooks,
+		dispatcher: c.Dispatcher,
+		geofences:  c.Geofences,
+		health:     c.Health,
+		keys:       c.IdempotencyKeys,
+		duplicates: c.Duplicates,
+		devIssuer:  c.DevIssuer,
+		ipLimiter:  c.IpRateLimiter,
+		limiter:    c.RateLimiter,
+	}, nil
+}
+
+// isNil returns true for nil interfaces and for interfaces holding a nil pointer.
+func isNil(component interface{}) bool {
+	if component == nil {
+		return true
+	}
+	v := reflect.ValueOf(component)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// Router returns the handler of all routes of the api.
+func (a *App) Router() http.Handler {
+	r := mux.NewRouter()
+	// the trace of the caller is continued, so all following spans belong to it
+	r.Use(otelmux.Middleware(ServiceName), requestid.Middleware, logging.Middleware, metrics.Middleware)
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	r.HandleFunc("/healthz", a.health.LivenessHandler).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", a.health.ReadinessHandler).Methods(http.MethodGet)
+	if a.devIssuer != nil {
+		r.HandleFunc("/.well-known/jwks.json", a.devIssuer.JwksHandler).Methods(http.MethodGet)
+		r.Handle("/dev/token", a.limitIp(http.HandlerFunc(a.devIssuer.TokenHandler))).Methods(http.MethodPost)
+	}
+
+	auditApi := r.PathPrefix("/v1/audit").Subrouter()
+	auditApi.Use(a.limitIp, a.authorizer.Authorize, a.rateLimit, auth.RequireScope(auditScope))
+	auditApi.HandleFunc("", a.queryAudit).Methods(http.MethodGet)
+	auditApi.HandleFunc("/verify", a.verifyAudit).Methods(http.MethodGet)
+
+	api := r.PathPrefix("/v1").Subrouter()
+	api.Use(a.limitIp, a.authorizer.Authorize, a.rateLimit)
+	api.HandleFunc("/pois/{id}", a.getPoi).Methods(http.MethodGet)
+	api.Handle("/pois", a.keys.Middleware(http.HandlerFunc(a.createPoi))).Methods(http.MethodPost)
+	api.HandleFunc("/pois/{id}", a.deletePoi).Methods(http.MethodDelete)
+	api.HandleFunc("/pois/{id}", a.updatePoi).Methods(http.MethodPut)
+	api.HandleFunc("/pois/list", a.listPoi).Methods(http.MethodPost)
+	api.HandleFunc("/pois/{id}/revisions", a.listRevisions).Methods(http.MethodGet)
+	api.HandleFunc("/pois/{id}/revisions/{revision}", a.getRevision).Methods(http.MethodGet)
+	api.HandleFunc("/pois/{id}/revisions/{revision}/revert", a.revertPoi).Methods(http.MethodPost)
+	api.HandleFunc("/pois/{id}/diff", a.diffRevisions).Methods(http.MethodGet)
+	api.HandleFunc("/events/stream", events.SSEHandler(a.eventBus)).Methods(http.MethodGet)
+	api.HandleFunc("/geofences", a.createGeofence).Methods(http.MethodPost)
+	api.HandleFunc("/geofences", a.listGeofences).Methods(http.MethodGet)
+	api.HandleFunc("/geofences/{id}", a.deleteGeofence).Methods(http.MethodDelete)
+	api.HandleFunc("/geofences/{id}/ws", a.watchGeofence).Methods(http.MethodGet)
+	api.HandleFunc("/sources/{source}/pois/{externalId}", a.getSourcePoi).Methods(http.MethodGet)
+	api.HandleFunc("/sources/{source}/pois/{externalId}", a.upsertSourcePoi).Methods(http.MethodPut)
+	api.HandleFunc("/duplicates/list", a.listDuplicates).Methods(http.MethodPost)
+	api.Handle("/duplicates/merge", auth.RequireScope(adminScope)(http.HandlerFunc(a.mergeDuplicates))).Methods(http.MethodPost)
+	api.HandleFunc("/trash", a.listTrash).Methods(http.MethodGet)
+	api.HandleFunc("/trash/{id}/restore", a.restorePoi).Methods(http.MethodPost)
+
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(a.limitIp, a.authorizer.Authorize, a.rateLimit, auth.RequireScope(adminScope))
+	admin.HandleFunc("/apikeys", a.createApiKey).Methods(http.MethodPost)
+	admin.HandleFunc("/apikeys", a.listApiKeys).Methods(http.MethodGet)
+	admin.HandleFunc("/apikeys/{id}", a.deleteApiKey).Methods(http.MethodDelete)
+	admin.HandleFunc("/webhooks", a.createWebhook).Methods(http.MethodPost)
+	admin.HandleFunc("/webhooks", a.listWebhooks).Methods(http.MethodGet)
+	admin.HandleFunc("/webhooks/deadletters", a.listDeadLetters).Methods(http.MethodGet)
+	admin.HandleFunc("/webhooks/deadletters/{id}/redeliver", a.redeliverDeadLetter).Methods(http.MethodPost)
+	admin.HandleFunc("/webhooks/{id}", a.deleteWebhook).Methods(http.MethodDelete)
+	return r
+}
+
+// limitIp limits the requests of the IP address, it is used before the authorization so requests that are rejected
+// by it are limited as well.
+func (a *App) limitIp(next http.Handler) http.Handler {
+	if a.ipLimiter == nil {
+		return next
+	}
+	return a.ipLimiter.Middleware(next)
+}
+
+// rateLimit limits the requests of the client, it is used after the authorization so the principal is known.
+func (a *App) rateLimit(next http.Handler) http.Handler {
+	if a.limiter == nil {
+		return next
+	}
+	return a.limiter.Middleware(next)
+}
+
+func decode(r *http.Request, poi interface{}) (err error) {
+	if poi == nil {
+		return errors.New("is nil")
+	}
+	err = json.NewDecoder(r.Body).Decode(poi)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("json decoding failed:")
+	}
+	return
+}
+
+func encode(rw http.ResponseWriter, poi interface{}) (err error) {
+	if poi == nil {
+		return errors.New("poi is nil")
+	}
+	err = json.NewEncoder(rw).Encode(poi)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}
+	return
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/audit"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/dedup"
+	"poi-service/cmd/download"
+	"poi-service/cmd/events"
+	"poi-service/cmd/geofence"
+	"poi-service/cmd/handler"
+	"poi-service/cmd/health"
+	"poi-service/cmd/idempotency"
+	"poi-service/cmd/ratelimit"
+	"poi-service/cmd/requestid"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// principalAuthorizer accepts every request as the principal, or rejects all requests if the principal is nil.
+type principalAuthorizer struct {
+	principal *auth.Principal
+}
+
+func (a principalAuthorizer) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.principal == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), a.principal)))
+	})
+}
+
+// testComponents creates components backed by memory stores, the caller is authenticated as alice with scopes.
+func testComponents(t *testing.T, pois handler.PoiHandler, scopes ...string) Components {
+	ctrl := gomock.NewController(t)
+	webhooks := events.NewMemoryWebhookStore()
+	dispatcher := events.NewDispatcher(webhooks, download.NewMockHttpRequester(ctrl))
+	geofences, err := geofence.NewRegistry(context.Background(), geofence.NewMemoryStore(), dispatcher)
+	require.NoError(t, err)
+
+	return Components{
+		Pois:            pois,
+		Authorizer:      principalAuthorizer{principal: &auth.Principal{Subject: "alice", Scopes: scopes}},
+		ApiKeys:         auth.NewMemoryApiKeyStore(),
+		AuditLog:        audit.NewLog(audit.NewMemoryStore(), nil),
+		EventBus:        events.NewBus(),
+		Webhooks:        webhooks,
+		Dispatcher:      dispatcher,
+		Geofences:       geofences,
+		Health:          health.NewChecker(),
+		IdempotencyKeys: idempotency.NewKeys(idempotency.NewMemoryStore()),
+		Duplicates:      dedup.NewDeduplicator(handler.NewMockDbHandler(ctrl), pois, dedup.NewMemoryRedirectStore()),
+	}
+}
+
+func newTestRouter(t *testing.T, components Components) http.Handler {
+	a, err := New(components)
+	require.NoError(t, err)
+	return a.Router()
+}
+
+// serve sends a request with the JSON of body, if not nil, to router.
+func serve(router http.Handler, method, target string, body interface{}) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		content, _ := json.Marshal(body)
+		reader = bytes.NewReader(content)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, reader))
+	return w
+}
+
+func TestNew(t *testing.T) {
+	components := testComponents(t, handler.NewMockPoiHandler(gomock.NewController(t)))
+	_, err := New(components)
+	require.NoError(t, err)
+
+	components.Pois = nil
+	_, err = New(components)
+	assert.ErrorIs(t, err, MissingComponent)
+
+	// typed nil pointers are detected as well
+	components = testComponents(t, handler.NewMockPoiHandler(gomock.NewController(t)))
+	components.AuditLog = (*audit.Log)(nil)
+	_, err = New(components)
+	assert.ErrorIs(t, err, MissingComponent)
+}
+
+func TestRouter(t *testing.T) {
+	components := testComponents(t, handler.NewMockPoiHandler(gomock.NewController(t)))
+	router := newTestRouter(t, components)
+
+	t.Run("health without authentication", func(t *testing.T) {
+		w := serve(router, http.MethodGet, "/healthz", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get(requestid.Header))
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/readyz", nil).Code)
+	})
+
+	t.Run("dev issuer disabled", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodPost, "/dev/token", nil).Code)
+	})
+
+	t.Run("admin scope required", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, "/admin/apikeys", nil).Code)
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, "/v1/audit", nil).Code)
+	})
+
+	t.Run("not authenticated", func(t *testing.T) {
+		components.Authorizer = principalAuthorizer{}
+		router := newTestRouter(t, components)
+		assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, "/v1/pois/1", nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, "/admin/webhooks", nil).Code)
+	})
+	t.Run("rate limited", func(t *testing.T) {
+		components := testComponents(t, handler.NewMockPoiHandler(gomock.NewController(t)))
+		components.RateLimiter = ratelimit.NewLimiter(ratelimit.Policy{
+			Default: ratelimit.Limit{Rate: 10, Burst: 10},
+			Routes:  map[string]ratelimit.Limit{"GET /v1/geofences": {Rate: 0.1, Burst: 1}},
+		}, ratelimit.NewMemoryQuotaStore())
+		router := newTestRouter(t, components)
+
+		w := serve(router, http.MethodGet, "/v1/geofences", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1", w.Header().Get(ratelimit.HeaderLimit))
+		w = serve(router, http.MethodGet, "/v1/geofences", nil)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+		// other routes have their own limit
+		w = serve(router, http.MethodGet, "/admin/apikeys", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "9", w.Header().Get(ratelimit.HeaderRemaining))
+	})
+
+	t.Run("rate limited by ip before authorization", func(t *testing.T) {
+		components := testComponents(t, handler.NewMockPoiHandler(gomock.NewController(t)))
+		components.Authorizer = principalAuthorizer{}
+		components.IpRateLimiter = ratelimit.NewLimiter(ratelimit.Policy{Default: ratelimit.Limit{Rate: 0.1, Burst: 1}}, nil, ratelimit.ByIp())
+		router := newTestRouter(t, components)
+
+		assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, "/v1/pois/1", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/v1/pois/1", nil).Code)
+	})
+}
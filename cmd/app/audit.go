@@ -0,0 +1,85 @@
+package app
+
+import (
+	"errors"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/audit"
+	"poi-service/cmd/auth"
+	"strconv"
+	"time"
+)
+
+// auditScope must be granted to read the audit log.
+const auditScope = "poi:audit"
+
+type auditVerifyResponse struct {
+	Verified uint64 `json:"verified"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+}
+
+// queryAudit returns the audit records matching the query parameters poiId, subject, action, from, to (RFC 3339),
+// after (sequence number for paging) and limit.
+func (a *App) queryAudit(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		PoiId:   query.Get("poiId"),
+		Subject: query.Get("subject"),
+		Action:  audit.Action(query.Get("action")),
+	}
+
+	var err error
+	if value := query.Get("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("after"); value != "" {
+		if filter.AfterSeq, err = strconv.ParseUint(value, 10, 64); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	records, err := a.auditLog.Query(r.Context(), filter)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("querying audit log failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("subject", auth.SubjectFromContext(r.Context())).Int("records", len(records)).Msg("audit log queried")
+	encode(rw, &records)
+}
+
+// verifyAudit checks the hash chain and the signatures of the complete audit log.
+func (a *App) verifyAudit(rw http.ResponseWriter, r *http.Request) {
+	verified, err := a.auditLog.Verify(r.Context())
+	if err != nil && !errors.Is(err, audit.ChainBroken) {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("reading audit log failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rsp := auditVerifyResponse{Verified: verified, Valid: err == nil}
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Uint64("verified", verified).Msg("audit log verification failed")
+		rsp.Error = err.Error()
+	}
+
+	encode(rw, &rsp)
+}
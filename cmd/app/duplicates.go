@@ -0,0 +1,105 @@
+package app
+
+import (
+	"errors"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/dedup"
+	"poi-service/cmd/handler"
+	"strconv"
+)
+
+type mergeRequest struct {
+	// Keep is the id of the poi that is kept.
+	Keep data.Id `json:"keep"`
+	// Merge is the id of the duplicate, it is deleted and redirected to Keep.
+	Merge data.Id `json:"merge"`
+}
+
+// listDuplicates returns the duplicates in the area of the body, the query parameters offset and limit select the page.
+func (a *App) listDuplicates(rw http.ResponseWriter, r *http.Request) {
+	var area data.SearchArea
+	if err := decode(r, &area); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var page dedup.Page
+	query := r.URL.Query()
+	var err error
+	if value := query.Get("offset"); value != "" {
+		if page.Offset, err = strconv.Atoi(value); err != nil || page.Offset < 0 {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if page.Limit, err = strconv.Atoi(value); err != nil || page.Limit < 0 {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	candidates, err := a.duplicates.Find(r.Context(), area, page)
+	var invalid dedup.FindError
+	if errors.As(err, &invalid) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("listDuplicates failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &candidates)
+}
+
+// mergeDuplicates merges the pois of the body, it can be repeated if it failed. It requires the admin scope, because
+// the merged poi is deleted for all clients.
+func (a *App) mergeDuplicates(rw http.ResponseWriter, r *http.Request) {
+	var req mergeRequest
+	if err := decode(r, &req); err != nil || req.Keep == "" || req.Merge == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err := a.duplicates.Merge(r.Context(), req.Keep, req.Merge)
+	if errors.Is(err, dedup.SamePoi) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, handler.PoiNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, handler.ExternalIdConflict) {
+		http.Error(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("subject", auth.SubjectFromContext(r.Context())).Msg("mergeDuplicates failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// redirectMerged answers the request for a poi that is not found with a redirect to the poi it was merged into. It
+// returns false if the poi was not merged.
+func (a *App) redirectMerged(rw http.ResponseWriter, r *http.Request, id data.Id) bool {
+	target, err := a.duplicates.Resolve(r.Context(), id)
+	if errors.Is(err, dedup.NoRedirect) {
+		return false
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("resolving redirect failed")
+		return false
+	}
+
+	http.Redirect(rw, r, "/v1/pois/"+string(target), http.StatusTemporaryRedirect)
+	return true
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"poi-service/cmd/data"
+	"poi-service/cmd/dedup"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pois := handler.NewMockPoiHandler(ctrl)
+	db := handler.NewMockDbHandler(ctrl)
+	components := testComponents(t, pois, adminScope)
+	components.Duplicates = dedup.NewDeduplicator(db, pois, dedup.NewMemoryRedirectStore())
+	router := newTestRouter(t, components)
+
+	t.Run("list", func(t *testing.T) {
+		church := handler.PoiDbEntry{Id: "a", Name: "Frauenkirche", Location: handler.NewLocation(51.0519, 13.7415)}
+		duplicate := handler.PoiDbEntry{Id: "b", Name: "Frauenkirche", Location: handler.NewLocation(51.0519, 13.7416)}
+		db.EXPECT().SearchByRadius(gomock.Any(), church.Location, uint64(1000+dedup.DefaultMaxDistance), int64(dedup.DefaultMaxPois+1)).Return(handler.PoiDbEntries{church, duplicate}, nil)
+
+		w := serve(router, http.MethodPost, "/v1/duplicates/list?offset=0&limit=10", data.SearchArea{Latitude: 51.0519, Longitude: 13.7415, RadiusInMeter: 1000})
+		require.Equal(t, http.StatusOK, w.Code)
+		var candidates []dedup.Candidate
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &candidates))
+		require.Len(t, candidates, 1)
+		assert.Equal(t, "a", candidates[0].A.Id)
+		assert.Equal(t, "b", candidates[0].B.Id)
+
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/duplicates/list", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/duplicates/list", data.SearchArea{}).Code,
+			"an area is required")
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/duplicates/list?limit=-1", data.SearchArea{RadiusInMeter: 10}).Code)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/duplicates/merge", mergeRequest{Keep: "a"}).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/duplicates/merge", mergeRequest{Keep: "a", Merge: "a"}).Code)
+
+		pois.EXPECT().Merge(gomock.Any(), data.Id("a"), data.Id("unknown")).Return(handler.PoiNotFound)
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodPost, "/v1/duplicates/merge", mergeRequest{Keep: "a", Merge: "unknown"}).Code)
+
+		pois.EXPECT().Merge(gomock.Any(), data.Id("a"), data.Id("c")).Return(handler.ExternalIdConflict)
+		assert.Equal(t, http.StatusConflict, serve(router, http.MethodPost, "/v1/duplicates/merge", mergeRequest{Keep: "a", Merge: "c"}).Code)
+
+		pois.EXPECT().Merge(gomock.Any(), data.Id("a"), data.Id("b")).Return(nil)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/v1/duplicates/merge", mergeRequest{Keep: "a", Merge: "b"}).Code)
+	})
+
+	t.Run("merge requires admin scope", func(t *testing.T) {
+		components := testComponents(t, pois)
+		router := newTestRouter(t, components)
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPost, "/v1/duplicates/merge", mergeRequest{Keep: "a", Merge: "b"}).Code)
+	})
+
+	t.Run("merged poi redirected", func(t *testing.T) {
+		pois.EXPECT().Get(gomock.Any(), data.Id("b")).Return(data.Poi{}, handler.PoiNotFound)
+		w := serve(router, http.MethodGet, "/v1/pois/b", nil)
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, "/v1/pois/a", w.Header().Get("Location"))
+
+		pois.EXPECT().Get(gomock.Any(), data.Id("c")).Return(data.Poi{}, handler.PoiNotFound)
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodGet, "/v1/pois/c", nil).Code)
+	})
+}
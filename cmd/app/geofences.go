@@ -0,0 +1,109 @@
+package app
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"net/url"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/geofence"
+)
+
+type geofenceRequest struct {
+	Area       data.SearchArea `json:"area"`
+	WebhookUrl string          `json:"webhookUrl"`
+}
+
+type geofenceResponse struct {
+	geofence.Fence
+	// Secret is used to sign the webhook payloads, it is only returned once.
+	Secret string `json:"secret,omitempty"`
+}
+
+func (a *App) createGeofence(rw http.ResponseWriter, r *http.Request) {
+	var req geofenceRequest
+	if err := decode(r, &req); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if req.WebhookUrl != "" {
+		target, err := url.Parse(req.WebhookUrl)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	fence, err := geofence.NewFence(req.Area, callerId(r), req.WebhookUrl)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("creating geofence failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = a.geofences.Add(r.Context(), fence)
+	if errors.Is(err, geofence.InvalidArea) {
+		rw.WriteHeader(http.StatusBadRequest)
+		encode(rw, err.Error())
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("storing geofence failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+	encode(rw, &geofenceResponse{Fence: fence, Secret: fence.Secret})
+}
+
+func (a *App) listGeofences(rw http.ResponseWriter, r *http.Request) {
+	fences := a.geofences.List(callerId(r))
+	encode(rw, &fences)
+}
+
+func (a *App) deleteGeofence(rw http.ResponseWriter, r *http.Request) {
+	fence, ok := a.ownGeofence(rw, r)
+	if !ok {
+		return
+	}
+
+	if err := a.geofences.Remove(r.Context(), fence.Id); err != nil && !errors.Is(err, geofence.NotFound) {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("deleting geofence failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// watchGeofence streams the notifications of the fence via WebSocket.
+func (a *App) watchGeofence(rw http.ResponseWriter, r *http.Request) {
+	fence, ok := a.ownGeofence(rw, r)
+	if !ok {
+		return
+	}
+
+	a.geofences.ServeWebSocket(rw, r, fence.Id)
+}
+
+// ownGeofence returns the fence of the path if it belongs to the caller, otherwise it responds with 404.
+func (a *App) ownGeofence(rw http.ResponseWriter, r *http.Request) (geofence.Fence, bool) {
+	fence, err := a.geofences.Get(mux.Vars(r)["id"])
+	if err != nil || fence.Owner == "" || fence.Owner != callerId(r) {
+		rw.WriteHeader(http.StatusNotFound)
+		return geofence.Fence{}, false
+	}
+	return fence, true
+}
+
+// callerId identifies the owner of fences, clients without subject are identified by their client id.
+func callerId(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return principal.Id()
+	}
+	return ""
+}
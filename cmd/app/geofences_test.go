@@ -0,0 +1,67 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/events"
+	"poi-service/cmd/geofence"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeofences(t *testing.T) {
+	components := testComponents(t, handler.NewMockPoiHandler(gomock.NewController(t)))
+	router := newTestRouter(t, components)
+	area := data.SearchArea{Latitude: 51.05, Longitude: 13.74, RadiusInMeter: 1000}
+
+	assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/geofences", geofenceRequest{Area: area, WebhookUrl: "ftp://x"}).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/geofences", geofenceRequest{Area: data.SearchArea{}}).Code)
+
+	w := serve(router, http.MethodPost, "/v1/geofences", geofenceRequest{Area: area})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created geofenceResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "alice", created.Owner)
+
+	w = serve(router, http.MethodGet, "/v1/geofences", nil)
+	var fences []geofence.Fence
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fences))
+	assert.Len(t, fences, 1)
+
+	// fences of other callers are not visible
+	components.Authorizer = principalAuthorizer{principal: &auth.Principal{Subject: "bob"}}
+	other := newTestRouter(t, components)
+	assert.JSONEq(t, `[]`, serve(other, http.MethodGet, "/v1/geofences", nil).Body.String())
+	assert.Equal(t, http.StatusNotFound, serve(other, http.MethodDelete, "/v1/geofences/"+created.Id, nil).Code)
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodDelete, "/v1/geofences/"+created.Id, nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodDelete, "/v1/geofences/"+created.Id, nil).Code)
+}
+
+func TestWebhooks(t *testing.T) {
+	components := testComponents(t, handler.NewMockPoiHandler(gomock.NewController(t)), adminScope)
+	router := newTestRouter(t, components)
+
+	assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/admin/webhooks", webhookRequest{Url: "not a url"}).Code)
+
+	w := serve(router, http.MethodPost, "/admin/webhooks", webhookRequest{Url: "https://example.com/hook"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created webhookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Secret)
+
+	w = serve(router, http.MethodGet, "/admin/webhooks", nil)
+	var subscriptions []events.Subscription
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &subscriptions))
+	assert.Len(t, subscriptions, 1)
+
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodPost, "/admin/webhooks/deadletters/unknown/redeliver", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodDelete, "/admin/webhooks/"+created.Id, nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodDelete, "/admin/webhooks/"+created.Id, nil).Code)
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"time"
+)
+
+func (a *App) createPoi(rw http.ResponseWriter, r *http.Request) {
+	var poi data.Poi
+	if err := decode(r, &poi); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := a.pois.Create(r.Context(), &poi)
+	var invalid handler.InvalidPoiError
+	if errors.As(err, &invalid) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, handler.ExternalIdTaken) {
+		http.Error(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("subject", auth.SubjectFromContext(r.Context())).Msg("createPoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := encode(rw, &id); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	return
+}
+
+func (a *App) updatePoi(rw http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if _, ok := params["id"]; !ok {
+		rw.WriteHeader(http.StatusInternalServerError)
+		log.Ctx(r.Context()).Warn().Msg("id not available in path")
+		return
+	}
+
+	var poi data.Poi
+	if err := decode(r, &poi); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err := a.pois.Update(r.Context(), data.Id(params["id"]), &poi)
+	var invalid handler.InvalidPoiError
+	if errors.As(err, &invalid) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, handler.PoiNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("subject", auth.SubjectFromContext(r.Context())).Msg("updatePoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	return
+}
+
+func (a *App) getPoi(rw http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if _, ok := params["id"]; !ok {
+		rw.WriteHeader(http.StatusInternalServerError)
+		log.Ctx(r.Context()).Warn().Msg("getPoi id not available in path")
+		return
+	}
+
+	var resp data.Poi
+	var err error
+	if value := r.URL.Query().Get("asOf"); value != "" {
+		// point-in-time read of the history
+		asOf, parseErr := time.Parse(time.RFC3339, value)
+		if parseErr != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp, err = a.pois.GetAsOf(r.Context(), data.Id(params["id"]), asOf)
+	} else {
+		resp, err = a.pois.Get(r.Context(), data.Id(params["id"]))
+		// merged duplicates are redirected to the poi they were merged into
+		if errors.Is(err, handler.PoiNotFound) && a.redirectMerged(rw, r, data.Id(params["id"])) {
+			return
+		}
+	}
+	if errors.Is(err, handler.PoiNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("getPoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := encode(rw, &resp); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	return
+}
+
+func (a *App) listPoi(rw http.ResponseWriter, r *http.Request) {
+	var area data.SearchArea
+
+	// if provided set a search area
+	if r.ContentLength > 0 {
+		if err := decode(r, &area); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := a.pois.Search(r.Context(), area)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("listPoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := encode(rw, &resp); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	return
+}
+
+func (a *App) deletePoi(rw http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if _, ok := params["id"]; !ok {
+		rw.WriteHeader(http.StatusInternalServerError)
+		log.Ctx(r.Context()).Warn().Msg("id not available in path")
+		return
+	}
+
+	err := a.pois.Delete(r.Context(), data.Id(params["id"]))
+	if errors.Is(err, handler.PoiNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("subject", auth.SubjectFromContext(r.Context())).Msg("deletePoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	return
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"poi-service/cmd/idempotency"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPois(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pois := handler.NewMockPoiHandler(ctrl)
+	router := newTestRouter(t, testComponents(t, pois))
+	poi := data.Poi{Name: "Frauenkirche", Latitude: 51.05, Longitude: 13.74}
+	failed := errors.New("db down")
+
+	t.Run("create", func(t *testing.T) {
+		pois.EXPECT().Create(gomock.Any(), &poi).Return("1", nil)
+		w := serve(router, http.MethodPost, "/v1/pois", poi)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `"1"`, w.Body.String())
+
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/pois", "no poi").Code)
+
+		pois.EXPECT().Create(gomock.Any(), gomock.Any()).Return("", failed)
+		assert.Equal(t, http.StatusInternalServerError, serve(router, http.MethodPost, "/v1/pois", poi).Code)
+	})
+
+	t.Run("create with idempotency key", func(t *testing.T) {
+		request := func(poi data.Poi) *httptest.ResponseRecorder {
+			content, _ := json.Marshal(poi)
+			req := httptest.NewRequest(http.MethodPost, "/v1/pois", bytes.NewReader(content))
+			req.Header.Set(idempotency.Header, "retry-1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		// created once only
+		pois.EXPECT().Create(gomock.Any(), &poi).Return("2", nil)
+		assert.JSONEq(t, `"2"`, request(poi).Body.String())
+		w := request(poi)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `"2"`, w.Body.String())
+		assert.Equal(t, "true", w.Header().Get(idempotency.ReplayedHeader))
+
+		other := poi
+		other.Name = "Zwinger"
+		assert.Equal(t, http.StatusUnprocessableEntity, request(other).Code)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		pois.EXPECT().Get(gomock.Any(), data.Id("1")).Return(poi, nil)
+		w := serve(router, http.MethodGet, "/v1/pois/1", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var got data.Poi
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, poi, got)
+
+		pois.EXPECT().Get(gomock.Any(), data.Id("2")).Return(data.Poi{}, handler.PoiNotFound)
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodGet, "/v1/pois/2", nil).Code)
+
+		pois.EXPECT().Get(gomock.Any(), data.Id("3")).Return(data.Poi{}, failed)
+		assert.Equal(t, http.StatusInternalServerError, serve(router, http.MethodGet, "/v1/pois/3", nil).Code)
+	})
+
+	t.Run("get as of", func(t *testing.T) {
+		asOf := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+		pois.EXPECT().GetAsOf(gomock.Any(), data.Id("1"), asOf).Return(poi, nil)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/v1/pois/1?asOf=2021-01-01T12:00:00Z", nil).Code)
+
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/v1/pois/1?asOf=yesterday", nil).Code)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		pois.EXPECT().Update(gomock.Any(), data.Id("1"), &poi).Return(nil)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodPut, "/v1/pois/1", poi).Code)
+
+		pois.EXPECT().Update(gomock.Any(), data.Id("2"), &poi).Return(handler.PoiNotFound)
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodPut, "/v1/pois/2", poi).Code)
+
+		pois.EXPECT().Update(gomock.Any(), data.Id("3"), &poi).Return(handler.LongitudeOutOfRange)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPut, "/v1/pois/3", poi).Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		pois.EXPECT().Delete(gomock.Any(), data.Id("1")).Return(nil)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodDelete, "/v1/pois/1", nil).Code)
+
+		pois.EXPECT().Delete(gomock.Any(), data.Id("2")).Return(handler.PoiNotFound)
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodDelete, "/v1/pois/2", nil).Code)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		area := data.SearchArea{Latitude: 51.05, Longitude: 13.74, RadiusInMeter: 500}
+		pois.EXPECT().Search(gomock.Any(), area).Return(data.Pois{poi}, nil)
+		w := serve(router, http.MethodPost, "/v1/pois/list", area)
+		require.Equal(t, http.StatusOK, w.Code)
+		var got data.Pois
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, data.Pois{poi}, got)
+
+		// without body all pois are listed
+		pois.EXPECT().Search(gomock.Any(), data.SearchArea{}).Return(data.Pois{}, nil)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/v1/pois/list", nil).Code)
+	})
+}
+
+func TestRevisionsAndTrash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pois := handler.NewMockPoiHandler(ctrl)
+	router := newTestRouter(t, testComponents(t, pois))
+
+	pois.EXPECT().ListRevisions(gomock.Any(), data.Id("1")).Return(data.Revisions{{Revision: 1}}, nil)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/v1/pois/1/revisions", nil).Code)
+
+	pois.EXPECT().GetRevision(gomock.Any(), data.Id("1"), 5).Return(data.Revision{}, handler.RevisionNotFound)
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodGet, "/v1/pois/1/revisions/5", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/v1/pois/1/revisions/latest", nil).Code)
+
+	pois.EXPECT().Diff(gomock.Any(), data.Id("1"), 1, 2).Return(data.Changes{}, nil)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/v1/pois/1/diff?from=1&to=2", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/v1/pois/1/diff?from=1", nil).Code)
+
+	pois.EXPECT().Revert(gomock.Any(), data.Id("1"), 1).Return(nil)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/v1/pois/1/revisions/1/revert", nil).Code)
+
+	pois.EXPECT().ListDeleted(gomock.Any()).Return(data.DeletedPois{}, nil)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/v1/trash", nil).Code)
+
+	pois.EXPECT().Restore(gomock.Any(), data.Id("1")).Return(handler.PoiNotFound)
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodPost, "/v1/trash/1/restore", nil).Code)
+}
+
+func TestSourcePois(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pois := handler.NewMockPoiHandler(ctrl)
+	router := newTestRouter(t, testComponents(t, pois))
+	poi := data.Poi{Name: "Zwinger", Latitude: 51.05, Longitude: 13.73}
+	synced := poi
+	synced.Source, synced.ExternalId = "partner", "z-1"
+
+	t.Run("upsert", func(t *testing.T) {
+		pois.EXPECT().Upsert(gomock.Any(), &synced).Return("1", true, nil)
+		w := serve(router, http.MethodPut, "/v1/sources/partner/pois/z-1", poi)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `"1"`, w.Body.String())
+
+		pois.EXPECT().Upsert(gomock.Any(), &synced).Return("1", false, nil)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodPut, "/v1/sources/partner/pois/z-1", poi).Code)
+
+		pois.EXPECT().Upsert(gomock.Any(), gomock.Any()).Return("", false, handler.LatitudeOutOfRange)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPut, "/v1/sources/partner/pois/z-1", poi).Code)
+
+		pois.EXPECT().Upsert(gomock.Any(), gomock.Any()).Return("1", false, handler.PoiInTrash)
+		assert.Equal(t, http.StatusConflict, serve(router, http.MethodPut, "/v1/sources/partner/pois/z-1", poi).Code)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		pois.EXPECT().GetByExternalId(gomock.Any(), "partner", "z-1").Return("1", synced, nil)
+		w := serve(router, http.MethodGet, "/v1/sources/partner/pois/z-1", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var found sourcePoiResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+		assert.Equal(t, "1", found.Id)
+		assert.Equal(t, synced, found.Poi)
+
+		pois.EXPECT().GetByExternalId(gomock.Any(), "partner", "z-2").Return("", data.Poi{}, handler.PoiNotFound)
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodGet, "/v1/sources/partner/pois/z-2", nil).Code)
+	})
+
+	t.Run("create with taken external id", func(t *testing.T) {
+		pois.EXPECT().Create(gomock.Any(), &synced).Return("", handler.ExternalIdTaken)
+		assert.Equal(t, http.StatusConflict, serve(router, http.MethodPost, "/v1/pois", synced).Code)
+		pois.EXPECT().Create(gomock.Any(), gomock.Any()).Return("", handler.InvalidExternalId)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodPost, "/v1/pois", data.Poi{Source: "partner"}).Code)
+	})
+}
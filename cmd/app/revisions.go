@@ -0,0 +1,98 @@
+package app
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"strconv"
+)
+
+func (a *App) listRevisions(rw http.ResponseWriter, r *http.Request) {
+	resp, err := a.pois.ListRevisions(r.Context(), data.Id(mux.Vars(r)["id"]))
+	if errors.Is(err, handler.PoiNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("listRevisions failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &resp)
+}
+
+func (a *App) getRevision(rw http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	revision, err := strconv.Atoi(params["revision"])
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := a.pois.GetRevision(r.Context(), data.Id(params["id"]), revision)
+	if errors.Is(err, handler.RevisionNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("getRevision failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &resp)
+}
+
+// diffRevisions compares the revisions given by the query parameters from and to.
+func (a *App) diffRevisions(rw http.ResponseWriter, r *http.Request) {
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := a.pois.Diff(r.Context(), data.Id(mux.Vars(r)["id"]), from, to)
+	if errors.Is(err, handler.RevisionNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("diffRevisions failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &resp)
+}
+
+func (a *App) revertPoi(rw http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	revision, err := strconv.Atoi(params["revision"])
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = a.pois.Revert(r.Context(), data.Id(params["id"]), revision)
+	if errors.Is(err, handler.RevisionNotFound) || errors.Is(err, handler.PoiNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("subject", auth.SubjectFromContext(r.Context())).Msg("revertPoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
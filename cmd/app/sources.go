@@ -0,0 +1,70 @@
+package app
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+)
+
+// sourcePoiResponse is a poi found by its external id, it contains the id of the service as well.
+type sourcePoiResponse struct {
+	Id string `json:"id"`
+	data.Poi
+}
+
+func (a *App) getSourcePoi(rw http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	id, poi, err := a.pois.GetByExternalId(r.Context(), params["source"], params["externalId"])
+	if errors.Is(err, handler.PoiNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("getSourcePoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &sourcePoiResponse{Id: id, Poi: poi})
+}
+
+// upsertSourcePoi creates the poi with the external id of the source or updates it, so syncs of partners can be
+// repeated safely. It responds with the id of the poi and 201 if it was created.
+func (a *App) upsertSourcePoi(rw http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	var poi data.Poi
+	if err := decode(r, &poi); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// the path identifies the poi, values in the body are ignored
+	poi.Source = params["source"]
+	poi.ExternalId = params["externalId"]
+
+	id, created, err := a.pois.Upsert(r.Context(), &poi)
+	var invalid handler.InvalidPoiError
+	if errors.As(err, &invalid) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, handler.PoiInTrash) {
+		http.Error(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("subject", auth.SubjectFromContext(r.Context())).Msg("upsertSourcePoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if created {
+		rw.WriteHeader(http.StatusCreated)
+	}
+	encode(rw, &id)
+}
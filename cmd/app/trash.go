@@ -0,0 +1,39 @@
+package app
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+)
+
+func (a *App) listTrash(rw http.ResponseWriter, r *http.Request) {
+	resp, err := a.pois.ListDeleted(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("listTrash failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &resp)
+}
+
+func (a *App) restorePoi(rw http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := a.pois.Restore(r.Context(), data.Id(id))
+	if errors.Is(err, handler.PoiNotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("subject", auth.SubjectFromContext(r.Context())).Msg("restorePoi failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
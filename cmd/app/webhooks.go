@@ -0,0 +1,109 @@
+package app
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"net/url"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/events"
+)
+
+type webhookRequest struct {
+	Url    string        `json:"url"`
+	Filter events.Filter `json:"filter"`
+}
+
+type webhookResponse struct {
+	events.Subscription
+	// Secret is used to sign the payloads, it is only returned once.
+	Secret string `json:"secret"`
+}
+
+func (a *App) createWebhook(rw http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := decode(r, &req); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	target, err := url.Parse(req.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := events.NewSubscription(req.Url, req.Filter)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("creating webhook failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.webhooks.AddSubscription(r.Context(), subscription); err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("storing webhook failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("id", subscription.Id).Str("url", subscription.Url).Str("createdBy", auth.SubjectFromContext(r.Context())).Msg("webhook created")
+
+	rw.WriteHeader(http.StatusCreated)
+	encode(rw, &webhookResponse{Subscription: subscription, Secret: subscription.Secret})
+}
+
+func (a *App) listWebhooks(rw http.ResponseWriter, r *http.Request) {
+	subscriptions, err := a.webhooks.ListSubscriptions(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("listing webhooks failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &subscriptions)
+}
+
+func (a *App) deleteWebhook(rw http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := a.webhooks.DeleteSubscription(r.Context(), id)
+	if errors.Is(err, events.NotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("deleting webhook failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("id", id).Str("deletedBy", auth.SubjectFromContext(r.Context())).Msg("webhook deleted")
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (a *App) listDeadLetters(rw http.ResponseWriter, r *http.Request) {
+	letters, err := a.webhooks.ListDeadLetters(r.Context())
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("listing dead letters failed")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	encode(rw, &letters)
+}
+
+func (a *App) redeliverDeadLetter(rw http.ResponseWriter, r *http.Request) {
+	err := a.dispatcher.Redeliver(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, events.NotFound) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("redelivering dead letter failed")
+		rw.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/requestid"
+	"sync"
+	"time"
+)
+
+// ChainBroken is given by Verify if records were changed, removed or inserted.
+const ChainBroken = VerificationError("audit chain broken")
+
+type VerificationError string
+
+func (e VerificationError) Error() string { return string(e) }
+
+// maxAppendRetries limits the retries if other instances append records at the same time.
+const maxAppendRetries = 5
+
+// Log appends records of changes to the Store and verifies the chain of records.
+type Log struct {
+	store Store
+	// signingKey is used to sign the record hashes, if nil records are only chained.
+	signingKey []byte
+	// mu serializes appends of this instance, conflicts with other instances are resolved by retries.
+	mu sync.Mutex
+}
+
+// NewLog creates a Log that writes to store. If signingKey is given every record is signed with it, so the chain can
+// not be rebuilt by someone with write access to the store only.
+func NewLog(store Store, signingKey []byte) *Log {
+	return &Log{store: store, signingKey: signingKey}
+}
+
+// Record appends a record for the change of the POI with poiId. The caller is taken from ctx.
+func (l *Log) Record(ctx context.Context, action Action, poiId string, before, after *data.Poi) (Record, error) {
+	record := Record{
+		// the store may keep only milliseconds, the hash must be reproducible from the stored time
+		Time:      time.Now().UTC().Truncate(time.Millisecond),
+		Action:    action,
+		PoiId:     poiId,
+		RequestId: requestid.FromContext(ctx),
+		Before:    before,
+		After:     after,
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		record.Subject = principal.Id()
+		record.ClientId = principal.ClientId
+		record.Method = principal.Method
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := 0; i < maxAppendRetries; i++ {
+		last, ok, err := l.store.Last(ctx)
+		if err != nil {
+			return Record{}, err
+		}
+		record.Seq = 1
+		record.PrevHash = ""
+		if ok {
+			record.Seq = last.Seq + 1
+			record.PrevHash = last.Hash
+		}
+		record.Hash = record.computeHash()
+		if l.signingKey != nil {
+			record.Signature = sign(record.Hash, l.signingKey)
+		}
+
+		err = l.store.Append(ctx, record)
+		if !errors.Is(err, SequenceConflict) {
+			return record, err
+		}
+	}
+	return Record{}, fmt.Errorf("%w after %d retries", SequenceConflict, maxAppendRetries)
+}
+
+// Query returns the records matching filter.
+func (l *Log) Query(ctx context.Context, filter Filter) ([]Record, error) {
+	return l.store.Query(ctx, filter)
+}
+
+// Verify checks the complete chain of records and returns the number of verified records. ChainBroken is returned
+// for the first record whose hash, predecessor or signature does not match.
+func (l *Log) Verify(ctx context.Context) (verified uint64, err error) {
+	prevHash := ""
+	for {
+		records, err := l.store.Query(ctx, Filter{AfterSeq: verified, Limit: MaxLimit})
+		if err != nil {
+			return verified, err
+		}
+		if len(records) == 0 {
+			return verified, nil
+		}
+
+		for i := range records {
+			record := &records[i]
+			if record.Seq != verified+1 {
+				return verified, fmt.Errorf("%w: record %d missing", ChainBroken, verified+1)
+			}
+			if record.PrevHash != prevHash {
+				return verified, fmt.Errorf("%w: record %d does not follow its predecessor", ChainBroken, record.Seq)
+			}
+			if record.computeHash() != record.Hash {
+				return verified, fmt.Errorf("%w: record %d was changed", ChainBroken, record.Seq)
+			}
+			if l.signingKey != nil && !hmac.Equal([]byte(sign(record.Hash, l.signingKey)), []byte(record.Signature)) {
+				return verified, fmt.Errorf("%w: signature of record %d invalid", ChainBroken, record.Seq)
+			}
+			prevHash = record.Hash
+			verified = record.Seq
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"context"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/requestid"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conflictStore simulates another instance that appends a record right before this one.
+type conflictStore struct {
+	Store
+	conflicts int
+}
+
+func (c *conflictStore) Append(ctx context.Context, record Record) error {
+	if c.conflicts > 0 {
+		c.conflicts--
+		return SequenceConflict
+	}
+	return c.Store.Append(ctx, record)
+}
+
+func testContext() context.Context {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "user", ClientId: "client", Method: auth.MethodJwt})
+	return requestid.WithId(ctx, "request")
+}
+
+func TestLog_Record(t *testing.T) {
+	store := NewMemoryStore()
+	auditLog := NewLog(store, []byte("secret"))
+	ctx := testContext()
+
+	first, err := auditLog.Record(ctx, ActionCreate, "poi", nil, &data.Poi{Name: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.Empty(t, first.PrevHash)
+	assert.Equal(t, "user", first.Subject)
+	assert.Equal(t, "client", first.ClientId)
+	assert.Equal(t, auth.MethodJwt, first.Method)
+	assert.Equal(t, "request", first.RequestId)
+	assert.NotEmpty(t, first.Signature)
+
+	second, err := auditLog.Record(ctx, ActionUpdate, "poi", &data.Poi{Name: "a"}, &data.Poi{Name: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), second.Seq)
+	assert.Equal(t, first.Hash, second.PrevHash)
+
+	verified, err := auditLog.Verify(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), verified)
+
+	records, err := auditLog.Query(ctx, Filter{Action: ActionUpdate})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].After.Name)
+}
+
+func TestLog_RecordConflict(t *testing.T) {
+	store := &conflictStore{Store: NewMemoryStore(), conflicts: 2}
+	_, err := NewLog(store, nil).Record(context.Background(), ActionDelete, "poi", nil, nil)
+	assert.NoError(t, err)
+
+	store.conflicts = maxAppendRetries
+	_, err = NewLog(store, nil).Record(context.Background(), ActionDelete, "poi", nil, nil)
+	assert.ErrorIs(t, err, SequenceConflict)
+}
+
+func TestLog_Verify(t *testing.T) {
+	// newLog creates a log with three records whose store can be manipulated
+	newLog := func(t *testing.T) (*Log, *memoryStore) {
+		store := &memoryStore{}
+		auditLog := NewLog(store, []byte("secret"))
+		for _, name := range []string{"a", "b", "c"} {
+			_, err := auditLog.Record(testContext(), ActionCreate, name, nil, &data.Poi{Name: name})
+			require.NoError(t, err)
+		}
+		return auditLog, store
+	}
+
+	t.Run("changed record", func(t *testing.T) {
+		auditLog, store := newLog(t)
+		store.records[1].After.Name = "x"
+		verified, err := auditLog.Verify(context.Background())
+		assert.ErrorIs(t, err, ChainBroken)
+		assert.Equal(t, uint64(1), verified)
+	})
+
+	t.Run("removed record", func(t *testing.T) {
+		auditLog, store := newLog(t)
+		store.records = append(store.records[:1], store.records[2:]...)
+		_, err := auditLog.Verify(context.Background())
+		assert.ErrorIs(t, err, ChainBroken)
+	})
+
+	t.Run("rebuilt chain without key", func(t *testing.T) {
+		auditLog, store := newLog(t)
+		// someone with write access changes a record and recalculates all hashes
+		store.records[0].Time = store.records[0].Time.Add(-time.Hour)
+		prevHash := ""
+		for i := range store.records {
+			store.records[i].PrevHash = prevHash
+			store.records[i].Hash = store.records[i].computeHash()
+			prevHash = store.records[i].Hash
+		}
+
+		_, err := auditLog.Verify(context.Background())
+		assert.ErrorIs(t, err, ChainBroken, "signatures must not match")
+
+		_, err = NewLog(store, nil).Verify(context.Background())
+		assert.NoError(t, err, "without key only the chain is checked")
+	})
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Now()
+	record := &Record{Seq: 5, PoiId: "poi", Subject: "user", Action: ActionCreate, Time: now}
+
+	assert.True(t, Filter{}.matches(record))
+	assert.True(t, Filter{PoiId: "poi", Subject: "user", From: now, To: now.Add(time.Second)}.matches(record))
+	assert.False(t, Filter{PoiId: "other"}.matches(record))
+	assert.False(t, Filter{Action: ActionDelete}.matches(record))
+	assert.False(t, Filter{To: now}.matches(record))
+	assert.False(t, Filter{AfterSeq: 5}.matches(record))
+
+	assert.Equal(t, DefaultLimit, Filter{}.limit())
+	assert.Equal(t, MaxLimit, Filter{Limit: MaxLimit + 1}.limit())
+}
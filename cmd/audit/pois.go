@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"time"
+)
+
+// NewPoiHandler decorates next, so every successful Create, Update, Delete, Restore, Revert, Upsert and Merge is
+// recorded in auditLog. The states before and after a change are both read from next. A change that could not be recorded is
+// logged as error but not reported to the caller, because it is already applied.
+func NewPoiHandler(next handler.PoiHandler, auditLog *Log) handler.PoiHandler {
+	if next == nil || auditLog == nil {
+		return nil
+	}
+	return &poiHandler{next: next, log: auditLog}
+}
+
+// poiHandler implements interface handler.PoiHandler
+type poiHandler struct {
+	next handler.PoiHandler
+	log  *Log
+}
+
+func (p *poiHandler) Create(ctx context.Context, poi *data.Poi) (string, error) {
+	id, err := p.next.Create(ctx, poi)
+	if err != nil {
+		return id, err
+	}
+	p.record(ctx, ActionCreate, id, nil, p.stored(ctx, data.Id(id), poi))
+	return id, nil
+}
+
+func (p *poiHandler) Update(ctx context.Context, idToUpdate data.Id, updatedPoi *data.Poi) error {
+	before := p.current(ctx, idToUpdate)
+	if err := p.next.Update(ctx, idToUpdate, updatedPoi); err != nil {
+		return err
+	}
+	p.record(ctx, ActionUpdate, string(idToUpdate), before, p.stored(ctx, idToUpdate, updatedPoi))
+	return nil
+}
+
+func (p *poiHandler) Get(ctx context.Context, id data.Id) (data.Poi, error) {
+	return p.next.Get(ctx, id)
+}
+
+func (p *poiHandler) Delete(ctx context.Context, id data.Id) error {
+	before := p.current(ctx, id)
+	if err := p.next.Delete(ctx, id); err != nil {
+		return err
+	}
+	p.record(ctx, ActionDelete, string(id), before, nil)
+	return nil
+}
+
+func (p *poiHandler) Search(ctx context.Context, pos data.SearchArea) (data.Pois, error) {
+	return p.next.Search(ctx, pos)
+}
+
+func (p *poiHandler) ListDeleted(ctx context.Context) (data.DeletedPois, error) {
+	return p.next.ListDeleted(ctx)
+}
+
+func (p *poiHandler) Restore(ctx context.Context, id data.Id) error {
+	if err := p.next.Restore(ctx, id); err != nil {
+		return err
+	}
+	p.record(ctx, ActionRestore, string(id), nil, p.current(ctx, id))
+	return nil
+}
+
+func (p *poiHandler) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	return p.next.PurgeDeleted(ctx, retention)
+}
+
+func (p *poiHandler) GetAsOf(ctx context.Context, id data.Id, asOf time.Time) (data.Poi, error) {
+	return p.next.GetAsOf(ctx, id, asOf)
+}
+
+func (p *poiHandler) ListRevisions(ctx context.Context, id data.Id) (data.Revisions, error) {
+	return p.next.ListRevisions(ctx, id)
+}
+
+func (p *poiHandler) GetRevision(ctx context.Context, id data.Id, revision int) (data.Revision, error) {
+	return p.next.GetRevision(ctx, id, revision)
+}
+
+func (p *poiHandler) Diff(ctx context.Context, id data.Id, from, to int) (data.Changes, error) {
+	return p.next.Diff(ctx, id, from, to)
+}
+
+func (p *poiHandler) Revert(ctx context.Context, id data.Id, revision int) error {
+	before := p.current(ctx, id)
+	if err := p.next.Revert(ctx, id, revision); err != nil {
+		return err
+	}
+	p.record(ctx, ActionUpdate, string(id), before, p.current(ctx, id))
+	return nil
+}
+
+func (p *poiHandler) GetByExternalId(ctx context.Context, source, externalId string) (string, data.Poi, error) {
+	return p.next.GetByExternalId(ctx, source, externalId)
+}
+
+func (p *poiHandler) Upsert(ctx context.Context, poi *data.Poi) (string, bool, error) {
+	var before *data.Poi
+	if _, existing, err := p.next.GetByExternalId(ctx, poi.Source, poi.ExternalId); err == nil {
+		before = &existing
+	}
+	id, created, err := p.next.Upsert(ctx, poi)
+	if err != nil {
+		return id, created, err
+	}
+	after := p.stored(ctx, data.Id(id), poi)
+	if created {
+		p.record(ctx, ActionCreate, id, nil, after)
+	} else {
+		p.record(ctx, ActionUpdate, id, before, after)
+	}
+	return id, created, nil
+}
+
+// Merge records the deletion of merge and the update of keep if it took over the external id. A repeated merge
+// changes nothing, so nothing is recorded.
+func (p *poiHandler) Merge(ctx context.Context, keep, merge data.Id) error {
+	merged, kept := p.current(ctx, merge), p.current(ctx, keep)
+	if err := p.next.Merge(ctx, keep, merge); err != nil {
+		return err
+	}
+	if merged == nil {
+		return nil
+	}
+	p.record(ctx, ActionDelete, string(merge), merged, nil)
+	if after := p.current(ctx, keep); after != nil && kept != nil && *after != *kept {
+		p.record(ctx, ActionUpdate, string(keep), kept, after)
+	}
+	return nil
+}
+
+// current returns the state of the poi or nil if it is not available.
+func (p *poiHandler) current(ctx context.Context, id data.Id) *data.Poi {
+	poi, err := p.next.Get(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return &poi
+}
+
+// stored returns the state of the poi after a change, so it is read the same way as the state before. written is
+// used if the poi is not available.
+func (p *poiHandler) stored(ctx context.Context, id data.Id, written *data.Poi) *data.Poi {
+	if poi := p.current(ctx, id); poi != nil {
+		return poi
+	}
+	poi := *written
+	return &poi
+}
+
+func (p *poiHandler) record(ctx context.Context, action Action, poiId string, before, after *data.Poi) {
+	if _, err := p.log.Record(ctx, action, poiId, before, after); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("action", string(action)).Str("poi id", poiId).Msg("writing audit record failed")
+	}
+}
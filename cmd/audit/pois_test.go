@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_poiHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	auditLog := NewLog(NewMemoryStore(), nil)
+	handlerToTest := NewPoiHandler(next, auditLog)
+	ctx := testContext()
+
+	assert.Nil(t, NewPoiHandler(nil, auditLog))
+
+	old := data.Poi{Name: "old"}
+	poi := &data.Poi{Name: "new"}
+
+	next.EXPECT().Create(ctx, poi).Return("id", nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(*poi, nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(old, nil)
+	next.EXPECT().Update(ctx, data.Id("id"), poi).Return(nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(*poi, nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(*poi, nil)
+	next.EXPECT().Delete(ctx, data.Id("id")).Return(nil)
+	next.EXPECT().Restore(ctx, data.Id("id")).Return(nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(*poi, nil)
+
+	id, err := handlerToTest.Create(ctx, poi)
+	require.NoError(t, err)
+	assert.Equal(t, "id", id)
+	require.NoError(t, handlerToTest.Update(ctx, "id", poi))
+	require.NoError(t, handlerToTest.Delete(ctx, "id"))
+	require.NoError(t, handlerToTest.Restore(ctx, "id"))
+
+	// failed changes are not recorded
+	next.EXPECT().Get(ctx, data.Id("other")).Return(data.Poi{}, errors.New("not found"))
+	next.EXPECT().Delete(ctx, data.Id("other")).Return(errors.New("not found"))
+	assert.Error(t, handlerToTest.Delete(ctx, "other"))
+
+	records, err := auditLog.Query(context.Background(), Filter{PoiId: "id"})
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	assert.Equal(t, ActionCreate, records[0].Action)
+	assert.Nil(t, records[0].Before)
+	assert.Equal(t, "new", records[0].After.Name)
+
+	assert.Equal(t, ActionUpdate, records[1].Action)
+	assert.Equal(t, "old", records[1].Before.Name)
+	assert.Equal(t, "new", records[1].After.Name)
+
+	assert.Equal(t, ActionDelete, records[2].Action)
+	assert.Equal(t, "new", records[2].Before.Name)
+	assert.Nil(t, records[2].After)
+	assert.Equal(t, "user", records[2].Subject)
+
+	assert.Equal(t, ActionRestore, records[3].Action)
+	assert.Equal(t, "new", records[3].After.Name)
+}
+
+func Test_poiHandler_Upsert(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	auditLog := NewLog(NewMemoryStore(), nil)
+	handlerToTest := NewPoiHandler(next, auditLog)
+	ctx := testContext()
+
+	poi := &data.Poi{Name: "new", Source: "partner", ExternalId: "p-1"}
+	next.EXPECT().GetByExternalId(ctx, "partner", "p-1").Return("", data.Poi{}, handler.PoiNotFound)
+	next.EXPECT().Upsert(ctx, poi).Return("id", true, nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(*poi, nil)
+	next.EXPECT().GetByExternalId(ctx, "partner", "p-1").Return("id", data.Poi{Name: "old"}, nil)
+	next.EXPECT().Upsert(ctx, poi).Return("id", false, nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(*poi, nil)
+
+	_, created, err := handlerToTest.Upsert(ctx, poi)
+	require.NoError(t, err)
+	assert.True(t, created)
+	_, _, err = handlerToTest.Upsert(ctx, poi)
+	require.NoError(t, err)
+
+	records, err := auditLog.Query(context.Background(), Filter{PoiId: "id"})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, ActionCreate, records[0].Action)
+	assert.Equal(t, ActionUpdate, records[1].Action)
+	assert.Equal(t, "old", records[1].Before.Name)
+	assert.Equal(t, "p-1", records[1].After.ExternalId)
+}
+
+func Test_poiHandler_Merge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	auditLog := NewLog(NewMemoryStore(), nil)
+	handlerToTest := NewPoiHandler(next, auditLog)
+	ctx := testContext()
+
+	kept := data.Poi{Name: "Frauenkirche"}
+	merged := data.Poi{Name: "Frauenkirche", Source: "partner", ExternalId: "p-1"}
+	gomock.InOrder(
+		next.EXPECT().Get(ctx, data.Id("b")).Return(merged, nil),
+		next.EXPECT().Get(ctx, data.Id("a")).Return(kept, nil),
+		next.EXPECT().Merge(ctx, data.Id("a"), data.Id("b")).Return(nil),
+		next.EXPECT().Get(ctx, data.Id("a")).Return(data.Poi{Name: "Frauenkirche", Source: "partner", ExternalId: "p-1"}, nil),
+	)
+	require.NoError(t, handlerToTest.Merge(ctx, "a", "b"))
+
+	// a repeated merge changes nothing
+	next.EXPECT().Get(ctx, data.Id("b")).Return(data.Poi{}, handler.PoiNotFound)
+	next.EXPECT().Get(ctx, data.Id("a")).Return(merged, nil)
+	next.EXPECT().Merge(ctx, data.Id("a"), data.Id("b")).Return(nil)
+	require.NoError(t, handlerToTest.Merge(ctx, "a", "b"))
+
+	records, err := auditLog.Query(context.Background(), Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, ActionDelete, records[0].Action)
+	assert.Equal(t, "b", records[0].PoiId)
+	assert.Equal(t, ActionUpdate, records[1].Action)
+	assert.Equal(t, "a", records[1].PoiId)
+	assert.Equal(t, "p-1", records[1].After.ExternalId)
+}
+
+// failingStore implements interface Store
+type failingStore struct{}
+
+func (failingStore) Append(context.Context, Record) error { return errors.New("unavailable") }
+
+func (failingStore) Last(context.Context) (Record, bool, error) { return Record{}, false, nil }
+
+func (failingStore) Query(context.Context, Filter) ([]Record, error) { return nil, nil }
+
+func Test_poiHandler_recordFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	handlerToTest := NewPoiHandler(next, NewLog(failingStore{}, nil))
+	ctx := testContext()
+
+	poi := &data.Poi{Name: "new"}
+	next.EXPECT().Get(ctx, data.Id("id")).Return(data.Poi{Name: "old"}, nil)
+	next.EXPECT().Update(ctx, data.Id("id"), poi).Return(nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(*poi, nil)
+
+	assert.NoError(t, handlerToTest.Update(ctx, "id", poi), "the change is applied")
+}
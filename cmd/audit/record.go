@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"poi-service/cmd/data"
+	"time"
+)
+
+// Action is the kind of change that is recorded.
+type Action string
+
+// Recorded changes of POIs.
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	// ActionRestore moves a deleted POI out of the trash.
+	ActionRestore Action = "restore"
+)
+
+// Record describes a single change of a POI. Records are chained by their hashes: every record contains the hash of
+// its predecessor, so changing or removing a record breaks the chain.
+type Record struct {
+	// Seq is the position of the record in the log, starting with 1.
+	Seq       uint64    `json:"seq" bson:"_id"`
+	Time      time.Time `json:"time" bson:"time"`
+	Action    Action    `json:"action" bson:"action"`
+	PoiId     string    `json:"poiId" bson:"poiId"`
+	Subject   string    `json:"subject" bson:"subject"`
+	ClientId  string    `json:"clientId,omitempty" bson:"clientId,omitempty"`
+	Method    string    `json:"method,omitempty" bson:"method,omitempty"`
+	RequestId string    `json:"requestId,omitempty" bson:"requestId,omitempty"`
+	Before    *data.Poi `json:"before,omitempty" bson:"before,omitempty"`
+	After     *data.Poi `json:"after,omitempty" bson:"after,omitempty"`
+	// PrevHash is the hash of the predecessor, empty for the first record.
+	PrevHash string `json:"prevHash" bson:"prevHash"`
+	// Hash is the hex encoded SHA-256 of all other fields.
+	Hash string `json:"hash" bson:"hash"`
+	// Signature is the hex encoded HMAC-SHA256 of Hash, if a signing key is configured.
+	Signature string `json:"signature,omitempty" bson:"signature,omitempty"`
+}
+
+// computeHash returns the hash of all fields except Hash and Signature. The fields are encoded in a fixed order, so
+// the hash does not depend on the store.
+func (r *Record) computeHash() string {
+	content, _ := json.Marshal(struct {
+		Seq       uint64    `json:"seq"`
+		Time      string    `json:"time"`
+		Action    Action    `json:"action"`
+		PoiId     string    `json:"poiId"`
+		Subject   string    `json:"subject"`
+		ClientId  string    `json:"clientId"`
+		Method    string    `json:"method"`
+		RequestId string    `json:"requestId"`
+		Before    *data.Poi `json:"before"`
+		After     *data.Poi `json:"after"`
+		PrevHash  string    `json:"prevHash"`
+	}{
+		Seq:       r.Seq,
+		Time:      r.Time.UTC().Format(time.RFC3339Nano),
+		Action:    r.Action,
+		PoiId:     r.PoiId,
+		Subject:   r.Subject,
+		ClientId:  r.ClientId,
+		Method:    r.Method,
+		RequestId: r.RequestId,
+		Before:    r.Before,
+		After:     r.After,
+		PrevHash:  r.PrevHash,
+	})
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// sign returns the HMAC of the hash with key.
+func sign(hash string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//------------------------------------------------------------------------------
+
+// Filter selects records of the log. Zero values match everything.
+type Filter struct {
+	PoiId   string
+	Subject string
+	Action  Action
+	From    time.Time
+	To      time.Time
+	// AfterSeq returns only records with a higher sequence number, it is used for paging.
+	AfterSeq uint64
+	// Limit is the maximum number of returned records, 0 means DefaultLimit.
+	Limit int
+}
+
+// DefaultLimit is the number of records returned by a query without limit.
+const DefaultLimit = 100
+
+// MaxLimit is the maximum number of records returned by a single query.
+const MaxLimit = 1000
+
+func (f Filter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultLimit
+	}
+	if f.Limit > MaxLimit {
+		return MaxLimit
+	}
+	return f.Limit
+}
+
+func (f Filter) matches(r *Record) bool {
+	return (f.PoiId == "" || f.PoiId == r.PoiId) &&
+		(f.Subject == "" || f.Subject == r.Subject) &&
+		(f.Action == "" || f.Action == r.Action) &&
+		(f.From.IsZero() || !r.Time.Before(f.From)) &&
+		(f.To.IsZero() || r.Time.Before(f.To)) &&
+		r.Seq > f.AfterSeq
+}
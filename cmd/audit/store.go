@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+)
+
+// SequenceConflict is given if a record with the same sequence number was already appended, e.g. by another instance
+// of the service.
+const SequenceConflict = SequenceConflictError("audit sequence number already used")
+
+type SequenceConflictError string
+
+func (e SequenceConflictError) Error() string { return string(e) }
+
+// Store persists the records append-only, there are no operations to change or delete records.
+type Store interface {
+	// Append stores record or returns SequenceConflict if its sequence number is already used.
+	Append(ctx context.Context, record Record) error
+	// Last returns the record with the highest sequence number, ok is false if the log is empty.
+	Last(ctx context.Context) (record Record, ok bool, err error)
+	// Query returns the records matching filter ordered by sequence number.
+	Query(ctx context.Context, filter Filter) ([]Record, error)
+}
+
+//------------------------------------------------------------------------------
+
+// NewMemoryStore creates a Store that keeps the records in memory only, e.g. for tests.
+func NewMemoryStore() Store {
+	return &memoryStore{}
+}
+
+// memoryStore implements interface Store
+type memoryStore struct {
+	mu      sync.RWMutex
+	records []Record
+}
+
+func (m *memoryStore) Append(_ context.Context, record Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record.Seq != uint64(len(m.records))+1 {
+		return SequenceConflict
+	}
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *memoryStore) Last(_ context.Context) (Record, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.records) == 0 {
+		return Record{}, false, nil
+	}
+	return m.records[len(m.records)-1], true, nil
+}
+
+func (m *memoryStore) Query(_ context.Context, filter Filter) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := []Record{}
+	for i := range m.records {
+		if filter.matches(&m.records[i]) {
+			result = append(result, m.records[i])
+			if len(result) == filter.limit() {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoStore creates a Store that persists the records in collection. The sequence number is used as id, so the
+// database rejects a second record with the same number.
+func NewMongoStore(collection *mongo.Collection) Store {
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "poiId", Value: 1}}},
+		{Keys: bson.D{{Key: "subject", Value: 1}}},
+		{Keys: bson.D{{Key: "time", Value: 1}}},
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("creating audit indexes failed")
+	}
+	return &mongoStore{collection: collection}
+}
+
+// mongoStore implements interface Store
+type mongoStore struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoStore) Append(ctx context.Context, record Record) error {
+	_, err := m.collection.InsertOne(ctx, record)
+	if mongo.IsDuplicateKeyError(err) {
+		return SequenceConflict
+	}
+	return err
+}
+
+func (m *mongoStore) Last(ctx context.Context) (record Record, ok bool, err error) {
+	err = m.collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"_id": -1})).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return Record{}, false, nil
+	}
+	return record, err == nil, err
+}
+
+func (m *mongoStore) Query(ctx context.Context, filter Filter) (records []Record, err error) {
+	query := bson.M{"_id": bson.M{"$gt": int64(filter.AfterSeq)}}
+	if filter.PoiId != "" {
+		query["poiId"] = filter.PoiId
+	}
+	if filter.Subject != "" {
+		query["subject"] = filter.Subject
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	timeRange := bson.M{}
+	if !filter.From.IsZero() {
+		timeRange["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		timeRange["$lt"] = filter.To
+	}
+	if len(timeRange) > 0 {
+		query["time"] = timeRange
+	}
+
+	cur, err := m.collection.Find(ctx, query, options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(filter.limit())))
+	if err != nil {
+		return
+	}
+	records = []Record{}
+	err = cur.All(ctx, &records)
+	return
+}
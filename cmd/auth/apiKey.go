@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ApiKeyHeader is the header that carries the API key. Alternatively, the Authorization header with scheme "ApiKey"
+// can be used.
+const ApiKeyHeader = "X-API-Key"
+
+// InvalidApiKey indicates that the API key is unknown or its secret is wrong.
+const InvalidApiKey = AuthenticationError("invalid api key")
+
+// ApiKey is the stored representation of an API key. Only the hash of the secret is stored, the key itself is only
+// known to the client.
+type ApiKey struct {
+	Id        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	Subject   string    `json:"subject" bson:"subject"`
+	Scopes    []string  `json:"scopes" bson:"scopes"`
+	Hash      string    `json:"-" bson:"hash"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	// ExpiresAt is the end of the lifetime of the key, the zero value means the key never expires.
+	ExpiresAt time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
+
+// IsExpired returns true if the lifetime of the key is over.
+func (k *ApiKey) IsExpired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// NewApiKey generates a new API key for subject with the given scopes. If ttl is 0 the key never expires.
+// The returned secret is the key that must be handed to the client, it can not be recovered later.
+func NewApiKey(name, subject string, scopes []string, ttl time.Duration) (key ApiKey, secret string, err error) {
+	id, err := randomString(9)
+	if err != nil {
+		return
+	}
+	random, err := randomString(32)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	key = ApiKey{
+		Id:        id,
+		Name:      name,
+		Subject:   subject,
+		Scopes:    scopes,
+		Hash:      hashApiKeySecret(random),
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		key.ExpiresAt = now.Add(ttl)
+	}
+
+	// the id is part of the key, so the stored key can be found without knowing the secret
+	return key, id + "." + random, nil
+}
+
+func randomString(length int) (string, error) {
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashApiKeySecret hashes the secret part of the key. The secret has enough entropy, so a salt or a slow hash
+// function is not needed.
+func hashApiKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+//------------------------------------------------------------------------------
+
+// ApiKeyNotFound is given if there is no API key with the requested id.
+const ApiKeyNotFound = ApiKeyNotFoundError("api key not found")
+
+type ApiKeyNotFoundError string
+
+func (e ApiKeyNotFoundError) Error() string { return string(e) }
+
+// ApiKeyStore persists API keys.
+type ApiKeyStore interface {
+	// Add stores a new key.
+	Add(ctx context.Context, key ApiKey) error
+	// Get returns the key with id or ApiKeyNotFound.
+	Get(ctx context.Context, id string) (ApiKey, error)
+	// List returns all stored keys.
+	List(ctx context.Context) ([]ApiKey, error)
+	// Delete removes the key with id or returns ApiKeyNotFound.
+	Delete(ctx context.Context, id string) error
+}
+
+//------------------------------------------------------------------------------
+
+type apiKeyAuthenticator struct {
+	store ApiKeyStore
+}
+
+// NewApiKeyAuthenticator creates an Authenticator that accepts requests with a valid API key of the store.
+func NewApiKeyAuthenticator(store ApiKeyStore) Authenticator {
+	return &apiKeyAuthenticator{store: store}
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	raw := getApiKey(r.Header)
+	if raw == "" {
+		return nil, MissingToken
+	}
+
+	if a.store == nil {
+		return nil, fmt.Errorf("%w: api key store is nil", DependencyMissing)
+	}
+
+	parts := strings.SplitN(raw, ".", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%w: malformed", InvalidApiKey)
+	}
+
+	key, err := a.store.Get(r.Context(), parts[0])
+	if err == ApiKeyNotFound {
+		return nil, fmt.Errorf("%w: unknown id %s", InvalidApiKey, parts[0])
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("reading api key failed")
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashApiKeySecret(parts[1]))) != 1 {
+		return nil, fmt.Errorf("%w: wrong secret for id %s", InvalidApiKey, key.Id)
+	}
+
+	if key.IsExpired(time.Now()) {
+		return nil, TokenExpired
+	}
+
+	return &Principal{
+		Subject:  key.Subject,
+		ClientId: key.Id,
+		Scopes:   key.Scopes,
+		Method:   MethodApiKey,
+	}, nil
+}
+
+func getApiKey(header http.Header) string {
+	if key := header.Get(ApiKeyHeader); key != "" {
+		return key
+	}
+
+	auth := header.Get("Authorization")
+	if key := strings.TrimPrefix(auth, "ApiKey "); key != auth {
+		return key
+	}
+	return ""
+}
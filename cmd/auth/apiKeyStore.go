@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sort"
+	"sync"
+)
+
+// NewMemoryApiKeyStore creates an ApiKeyStore that keeps the keys in memory only, e.g. for tests.
+func NewMemoryApiKeyStore() ApiKeyStore {
+	return &memoryApiKeyStore{keys: make(map[string]ApiKey)}
+}
+
+// memoryApiKeyStore implements interface ApiKeyStore
+type memoryApiKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]ApiKey
+}
+
+func (m *memoryApiKeyStore) Add(_ context.Context, key ApiKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key.Id] = key
+	return nil
+}
+
+func (m *memoryApiKeyStore) Get(_ context.Context, id string) (ApiKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return ApiKey{}, ApiKeyNotFound
+	}
+	return key, nil
+}
+
+func (m *memoryApiKeyStore) List(context.Context) ([]ApiKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]ApiKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (m *memoryApiKeyStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[id]; !ok {
+		return ApiKeyNotFound
+	}
+	delete(m.keys, id)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoApiKeyStore creates an ApiKeyStore that persists the keys in collection.
+func NewMongoApiKeyStore(collection *mongo.Collection) ApiKeyStore {
+	return &mongoApiKeyStore{collection: collection}
+}
+
+// mongoApiKeyStore implements interface ApiKeyStore
+type mongoApiKeyStore struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoApiKeyStore) Add(ctx context.Context, key ApiKey) error {
+	_, err := m.collection.InsertOne(ctx, key)
+	return err
+}
+
+func (m *mongoApiKeyStore) Get(ctx context.Context, id string) (key ApiKey, err error) {
+	err = m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		err = ApiKeyNotFound
+	}
+	return
+}
+
+func (m *mongoApiKeyStore) List(ctx context.Context) (keys []ApiKey, err error) {
+	cur, err := m.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return
+	}
+	keys = []ApiKey{}
+	err = cur.All(ctx, &keys)
+	return
+}
+
+func (m *mongoApiKeyStore) Delete(ctx context.Context, id string) error {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ApiKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_apiKeyAuthenticator_Authenticate(t *testing.T) {
+	store := NewMemoryApiKeyStore()
+	authenticatorToTest := NewApiKeyAuthenticator(store)
+
+	key, secret, err := NewApiKey("batch", "batch-job", []string{"poi:write"}, 0)
+	require.Nil(t, err)
+	require.NotContains(t, key.Hash, secret)
+	require.Nil(t, store.Add(context.Background(), key))
+
+	expiredKey, expiredSecret, err := NewApiKey("old", "batch-job", nil, time.Nanosecond)
+	require.Nil(t, err)
+	require.Nil(t, store.Add(context.Background(), expiredKey))
+	time.Sleep(time.Millisecond)
+
+	authenticate := func(header http.Header) (*Principal, error) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for key, values := range header {
+			r.Header.Set(key, values[0])
+		}
+		return authenticatorToTest.Authenticate(r)
+	}
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := authenticate(http.Header{"Authorization": {"Bearer abc"}})
+		assert.Equal(t, MissingToken, err)
+	})
+
+	t.Run("valid key in header", func(t *testing.T) {
+		p, err := authenticate(http.Header{ApiKeyHeader: {secret}})
+		require.Nil(t, err)
+		assert.Equal(t, "batch-job", p.Subject)
+		assert.Equal(t, key.Id, p.ClientId)
+		assert.Equal(t, MethodApiKey, p.Method)
+		assert.True(t, p.HasScope("poi:write"))
+	})
+
+	t.Run("valid key in authorization", func(t *testing.T) {
+		_, err := authenticate(http.Header{"Authorization": {"ApiKey " + secret}})
+		assert.Nil(t, err)
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, invalid := range []string{"abc", key.Id + ".wrong", "unknown." + secret} {
+			_, err := authenticate(http.Header{ApiKeyHeader: {invalid}})
+			assert.ErrorIs(t, err, InvalidApiKey)
+		}
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		_, err := authenticate(http.Header{ApiKeyHeader: {expiredSecret}})
+		assert.Equal(t, TokenExpired, err)
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		require.Nil(t, store.Delete(context.Background(), key.Id))
+		_, err := authenticate(http.Header{ApiKeyHeader: {secret}})
+		assert.ErrorIs(t, err, InvalidApiKey)
+		assert.Equal(t, ApiKeyNotFound, store.Delete(context.Background(), key.Id))
+	})
+}
+
+func TestRequireScope(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handlerToTest := RequireScope("poi:admin")(next)
+
+	serveWith := func(p *Principal) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if p != nil {
+			r = r.WithContext(WithPrincipal(r.Context(), p))
+		}
+		w := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serveWith(nil))
+	assert.Equal(t, http.StatusForbidden, serveWith(&Principal{Scopes: []string{"poi:read"}}))
+	assert.Equal(t, http.StatusTeapot, serveWith(&Principal{Scopes: []string{"poi:admin"}}))
+}
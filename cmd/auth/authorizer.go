@@ -1,120 +1,254 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
+	"poi-service/cmd/logging"
+	"poi-service/cmd/metrics"
+	"poi-service/cmd/tracing"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var tracer = otel.Tracer("poi-service/cmd/auth")
+
 type Authorizer interface {
 	Authorize(next http.Handler) http.Handler
 }
 
+// Authenticator validates the credentials of a single request.
+type Authenticator interface {
+	// Authenticate returns the Principal of the caller if the request carries valid credentials. The following errors
+	// can occur:
+	// - MissingToken: The request contains no credentials for this authenticator. Not authorized.
+	// - AuthenticationError: The credentials are not valid. Not authorized.
+	// - errors of the JwkStore.
+	// - DependencyMissing: The authenticator is not set up properly.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+//------------------------------------------------------------------------------
+
+// Reasons why a request could not be authenticated.
+const (
+	// MissingToken indicates that the request contains no credentials at all.
+	MissingToken = AuthenticationError("missing auth token")
+	// MalformedToken indicates that the provided token could not be parsed or misses mandatory fields.
+	MalformedToken = AuthenticationError("malformed token")
+	// InvalidSignature indicates that the token was not signed by the trusted backend.
+	InvalidSignature = AuthenticationError("invalid token signature")
+	// TokenExpired indicates that the lifetime of the token is over.
+	TokenExpired = AuthenticationError("token expired")
+	// InactiveToken indicates that the token introspection reported the token as inactive.
+	InactiveToken = AuthenticationError("token not active")
+)
+
+type AuthenticationError string
+
+func (e AuthenticationError) Error() string { return string(e) }
+
+//------------------------------------------------------------------------------
+
 type authorizer struct {
-	jwkStore JwkStore
+	authenticators []Authenticator
 }
 
+// NewAuthorizer creates an Authorizer that validates JWTs against the keys provided by jwkStore.
 func NewAuthorizer(jwkStore JwkStore) Authorizer {
-	return &authorizer{jwkStore: jwkStore}
+	return NewAuthorizerWith(NewJwtAuthenticator(jwkStore))
+}
+
+// NewAuthorizerWith creates an Authorizer that accepts a request as soon as one of the authenticators accepts it.
+// The authenticators are tried in the given order, e.g. JWT validation first and token introspection as fallback.
+func NewAuthorizerWith(authenticators ...Authenticator) Authorizer {
+	return &authorizer{authenticators: authenticators}
 }
 
 func (a *authorizer) Authorize(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		jwt := getBearerToken(r.Header)
+		ctx, span := tracer.Start(r.Context(), "Authorize")
+		principal, err := a.authenticate(r.WithContext(ctx))
+		span.SetAttributes(attribute.String("auth.outcome", outcome(err)))
+		tracing.End(span, err)
 
-		// check for provided jwt
-		if jwt == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode("Missing auth token")
-			return
-		}
-
-		// parse token and validate
-		token, err := NewUnverifiedToken(jwt)
+		metrics.ObserveAuth(outcome(err))
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			log.Warn().Err(err).Msg("parsing token failed")
+			reject(w, r, err)
 			return
 		}
 
-		kid := token.GetValueForHeaderKey(Kid)
-		iss := token.GetValueForClaim(Iss)
+		// make the caller available for the following handlers and their logs
+		logging.AddFields(r.Context(), func(c zerolog.Context) zerolog.Context {
+			return c.Str("principal", principal.Id()).Str("authMethod", principal.Method)
+		})
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
 
-		if kid == nil || iss == nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode("iss or kid not available in jwt")
-			return
+// authenticate returns the Principal of the first authenticator that accepts the request. Otherwise, the first error
+// that is more specific than MissingToken is returned.
+func (a *authorizer) authenticate(r *http.Request) (*Principal, error) {
+	var result error = MissingToken
+	for _, authenticator := range a.authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if err == nil {
+			return principal, nil
 		}
-
-		if a.jwkStore == nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			log.Error().Msg("jwkStore is nil")
-			return
+		if errors.Is(result, MissingToken) && !errors.Is(err, MissingToken) {
+			result = err
 		}
+	}
+	return nil, result
+}
 
-		// get JWK for JWT
-		rawJWK, err := a.jwkStore.GetJWK(*kid, *iss)
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			log.Error().Err(err).Msg("GetJwk failed")
-			return
-		}
+// outcomeReasons are the reasons of a rejection reported to the metrics, in order of precedence.
+var outcomeReasons = []struct {
+	err    error
+	reason string
+}{
+	{MissingToken, "missing_token"},
+	{MalformedToken, "malformed_token"},
+	{InvalidSignature, "invalid_signature"},
+	{TokenExpired, "expired"},
+	{InactiveToken, "inactive_token"},
+	{UntrustedIssuer, "untrusted_issuer"},
+	{NoKeyAvailable, "unknown_key"},
+	{InvalidApiKey, "invalid_api_key"},
+	{CertificateMismatch, "certificate_mismatch"},
+	{DependencyMissing, "dependency_missing"},
+}
 
-		// validate token
-		if err := token.IsValid(rawJWK); err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			log.Error().Err(err).Msg("Token not valid")
-			return
+// outcome returns the label of the metrics for the result of an authentication.
+func outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	for _, known := range outcomeReasons {
+		if errors.Is(err, known.err) {
+			return known.reason
 		}
+	}
+	return "other"
+}
 
-		expired, err := isTokenExpired(token)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+func reject(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, DependencyMissing) {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Ctx(r.Context()).Error().Err(err).Msg("authorization not possible")
+		return
+	}
 
-		if expired {
-			w.WriteHeader(http.StatusUnauthorized)
-			log.Info().Msg("JWT expired")
-			return
+	log.Ctx(r.Context()).Warn().Err(err).Msg("request not authorized")
+	w.WriteHeader(http.StatusUnauthorized)
+
+	var reason AuthenticationError
+	if errors.As(err, &reason) {
+		json.NewEncoder(w).Encode(reason.Error())
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type jwtAuthenticator struct {
+	jwkStore JwkStore
+}
+
+// NewJwtAuthenticator creates an Authenticator that validates bearer JWTs against the keys provided by jwkStore.
+func NewJwtAuthenticator(jwkStore JwkStore) Authenticator {
+	return &jwtAuthenticator{jwkStore: jwkStore}
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	jwt := getBearerToken(r)
+
+	// check for provided jwt
+	if jwt == "" {
+		return nil, MissingToken
+	}
+
+	// parse token and validate
+	token, err := NewUnverifiedToken(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", MalformedToken, err)
+	}
+
+	kid := token.GetValueForHeaderKey(Kid)
+	iss := token.GetValueForClaim(Iss)
+
+	if kid == nil || iss == nil {
+		return nil, fmt.Errorf("%w: iss or kid not available in jwt", MalformedToken)
+	}
+
+	if a.jwkStore == nil {
+		return nil, fmt.Errorf("%w: jwkStore is nil", DependencyMissing)
+	}
+
+	// get JWK for JWT
+	rawJWK, err := a.jwkStore.GetJWK(r.Context(), *kid, *iss)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate token
+	if err := token.IsValid(rawJWK); err != nil {
+		if expired, _ := isTokenExpired(r.Context(), token); expired {
+			return nil, TokenExpired
 		}
+		return nil, fmt.Errorf("%w: %v", InvalidSignature, err)
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	expired, err := isTokenExpired(r.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", MalformedToken, err)
+	}
+
+	if expired {
+		return nil, TokenExpired
+	}
+
+	if err := checkCertificateBinding(token.GetClaims(), r); err != nil {
+		return nil, err
+	}
+
+	return NewPrincipal(token.GetClaims(), MethodJwt), nil
 }
 
-func getBearerToken(header http.Header) string {
-	auth := header.Get("Authorization")
+func getBearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
 	if auth == "" {
-		log.Warn().Msg("no Authorization header")
+		log.Ctx(r.Context()).Debug().Msg("no Authorization header")
 		return ""
 	}
 
 	token := strings.TrimPrefix(auth, "Bearer ")
 	if token == auth {
-		log.Warn().Msg("Could not find bearer token in Authorization header")
+		log.Ctx(r.Context()).Warn().Msg("Could not find bearer token in Authorization header")
 		return ""
 	}
 
 	return token
 }
 
-func isTokenExpired(token Token) (expired bool, err error) {
+func isTokenExpired(ctx context.Context, token Token) (expired bool, err error) {
 	// check expiration
 	exp := token.GetValueForClaim(Exp)
 	if exp == nil {
-		log.Warn().Msg("Failed to get expiration")
+		log.Ctx(ctx).Warn().Msg("Failed to get expiration")
 		err = errors.New("failed to get exp")
 		return
 	}
 
 	i, err := strconv.ParseInt(*exp, 10, 64)
 	if err != nil {
-		log.Warn().Msg("Failed to format expiration")
+		log.Ctx(ctx).Warn().Msg("Failed to format expiration")
 		return
 	}
 	tm := time.Unix(i, 0)
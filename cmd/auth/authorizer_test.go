@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// authenticatorFunc allows to use a function as Authenticator in tests.
+type authenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (*Principal, error) { return f(r) }
+
+func serve(a Authorizer, header http.Header) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every authorized request must carry the principal
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Subject", principal.Subject)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r := httptest.NewRequest(http.MethodGet, "/v1/pois/abc", nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	a.Authorize(next).ServeHTTP(w, r)
+	return w
+}
+
+func Test_authorizer_Authorize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jwkStore := NewMockJwkStore(ctrl)
+	authorizerToTest := NewAuthorizer(jwkStore)
+
+	private, public := generateKeyPair(t, RS256)
+	bearer := func(token string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		w := serve(authorizerToTest, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), MissingToken.Error())
+	})
+
+	t.Run("malformed token", func(t *testing.T) {
+		w := serve(authorizerToTest, bearer("abc"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), MalformedToken.Error())
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		jwkStore.EXPECT().GetJWK(gomock.Any(), "testKey", "someone").Return(public, nil)
+		tok := newTestToken(t)
+		tok.SetClaims(Claims{"sub": "user"})
+		w := serve(authorizerToTest, bearer(tok.Sign(private, RS256, "testKey")))
+		assert.Equal(t, http.StatusTeapot, w.Code)
+		assert.Equal(t, "user", w.Header().Get("X-Subject"))
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		tok := newTestToken(t)
+		tok.SetClaims(Claims{"exp": time.Now().Add(-time.Minute).Unix()})
+
+		jwkStore.EXPECT().GetJWK(gomock.Any(), "testKey", "someone").Return(public, nil)
+		w := serve(authorizerToTest, bearer(tok.Sign(private, RS256, "testKey")))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), TokenExpired.Error())
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		_, otherPublic := generateKeyPair(t, RS256)
+		jwkStore.EXPECT().GetJWK(gomock.Any(), "testKey", "someone").Return(otherPublic, nil)
+		w := serve(authorizerToTest, bearer(newTestToken(t).Sign(private, RS256, "testKey")))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), InvalidSignature.Error())
+	})
+
+	t.Run("untrusted issuer", func(t *testing.T) {
+		jwkStore.EXPECT().GetJWK(gomock.Any(), "testKey", "someone").Return("", UntrustedIssuer)
+		w := serve(authorizerToTest, bearer(newTestToken(t).Sign(private, RS256, "testKey")))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("jwkStore missing", func(t *testing.T) {
+		w := serve(NewAuthorizer(nil), bearer(newTestToken(t).Sign(private, RS256, "testKey")))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func Test_authorizer_Chain(t *testing.T) {
+	missing := authenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, MissingToken })
+	malformed := authenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, MalformedToken })
+	inactive := authenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, InactiveToken })
+	accept := authenticatorFunc(func(r *http.Request) (*Principal, error) { return &Principal{Subject: "someone"}, nil })
+
+	t.Run("fallback accepts", func(t *testing.T) {
+		w := serve(NewAuthorizerWith(malformed, accept), nil)
+		assert.Equal(t, http.StatusTeapot, w.Code)
+	})
+
+	t.Run("first specific error is reported", func(t *testing.T) {
+		w := serve(NewAuthorizerWith(missing, malformed, inactive), nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), MalformedToken.Error())
+	})
+
+	t.Run("no authenticator", func(t *testing.T) {
+		w := serve(NewAuthorizerWith(), nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("dependency missing", func(t *testing.T) {
+		broken := authenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, DependencyMissing })
+		w := serve(NewAuthorizerWith(broken), nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("wrapped errors", func(t *testing.T) {
+		a := &authorizer{authenticators: []Authenticator{missing, malformed}}
+		principal, err := a.authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Nil(t, principal)
+		require.True(t, errors.Is(err, MalformedToken))
+	})
+}
+
+func Test_outcome(t *testing.T) {
+	assert.Equal(t, "ok", outcome(nil))
+	assert.Equal(t, "expired", outcome(TokenExpired))
+	assert.Equal(t, "invalid_api_key", outcome(fmt.Errorf("%w: unknown id", InvalidApiKey)))
+	assert.Equal(t, "untrusted_issuer", outcome(UntrustedIssuer))
+	assert.Equal(t, "other", outcome(errors.New("failed")))
+}
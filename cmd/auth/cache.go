@@ -3,44 +3,108 @@ package auth
 import (
 	"errors"
 	"github.com/rs/zerolog/log"
+	"sync"
 	"time"
 )
 
-// JwkCache will take care to store, retrieve and flush downloaded jwk
+// DefaultJwkTTL is used for cached jwk if the JWKS response did not contain any caching directive.
+const DefaultJwkTTL = time.Hour
+
+// JwkCache will take care to store, retrieve and flush downloaded jwk. It is safe for concurrent use.
 type JwkCache struct {
+	mu    sync.RWMutex
 	cache map[string]entry
+	ttl   time.Duration
 }
 
 type entry struct {
 	jwk       Jwk
 	timestamp time.Time
+	expires   time.Time
 }
 
+// Init must be called once before the cache is used.
 func (c *JwkCache) Init() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.cache = make(map[string]entry)
+	if c.ttl == 0 {
+		c.ttl = DefaultJwkTTL
+	}
+}
+
+// SetDefaultTTL sets the time to live for jwk that are added without an explicit expiry.
+func (c *JwkCache) SetDefaultTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
 }
 
+// Add stores the jwk with the default time to live.
 func (c *JwkCache) Add(jwk Jwk) {
+	c.mu.RLock()
+	ttl := c.ttl
+	c.mu.RUnlock()
+	c.AddWithExpiry(jwk, time.Now().Add(ttl))
+}
+
+// AddWithExpiry stores the jwk until the given point in time. An already existing entry is replaced.
+func (c *JwkCache) AddWithExpiry(jwk Jwk, expires time.Time) {
 	if len(jwk.Kid) == 0 {
 		return
 	}
 
-	c.cache[jwk.Kid+jwk.Iss] = entry{jwk, time.Now()}
-	log.Info().Str("kid+iss", jwk.Kid+jwk.Iss).Msg("added to cache")
+	c.mu.Lock()
+	c.cache[jwk.Kid+jwk.Iss] = entry{jwk: jwk, timestamp: time.Now(), expires: expires}
+	c.mu.Unlock()
+	log.Info().Str("kid+iss", jwk.Kid+jwk.Iss).Time("expires", expires).Msg("added to cache")
 }
 
+// Get returns the jwk for kid and iss. Expired entries are treated as not available.
 func (c *JwkCache) Get(kid, iss string) (Jwk, error) {
-	if val, ok := c.cache[kid+iss]; ok {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if val, ok := c.cache[kid+iss]; ok && time.Now().Before(val.expires) {
 		return val.jwk, nil
 	}
 	return Jwk{}, errors.New("not found")
 }
 
-func (c *JwkCache) Flush(age time.Duration) {
+// Count returns the number of valid entries of iss.
+func (c *JwkCache) Count(iss string) (count int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := time.Now()
+	for _, val := range c.cache {
+		if val.jwk.Iss == iss && now.Before(val.expires) {
+			count++
+		}
+	}
+	return
+}
+
+// NextExpiry returns the earliest expiry of all cached entries. If the cache is empty ok is false.
+func (c *JwkCache) NextExpiry() (next time.Time, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, val := range c.cache {
+		if !ok || val.expires.Before(next) {
+			next = val.expires
+			ok = true
+		}
+	}
+	return
+}
+
+// Flush removes all expired entries.
+func (c *JwkCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
 	// yes, for golang it is possible to delete while iterating
 	for key, val := range c.cache {
-		if time.Now().Sub(val.timestamp) > age {
-			log.Info().Str("kid+iss", key).Msg("flushing due to age")
+		if now.After(val.expires) {
+			log.Info().Str("kid+iss", key).Dur("age", now.Sub(val.timestamp)).Msg("flushing due to expiry")
 			delete(c.cache, key)
 		}
 	}
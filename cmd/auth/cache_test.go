@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJwkCache(t *testing.T) {
+	t.Run("expired entries are not returned and flushed", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+
+		cache.AddWithExpiry(Jwk{Kid: "old", Iss: "iss"}, time.Now().Add(-time.Second))
+		cache.AddWithExpiry(Jwk{Kid: "new", Iss: "iss"}, time.Now().Add(time.Hour))
+
+		_, err := cache.Get("old", "iss")
+		assert.NotNil(t, err)
+		_, err = cache.Get("new", "iss")
+		assert.Nil(t, err)
+
+		next, ok := cache.NextExpiry()
+		assert.True(t, ok)
+		assert.True(t, next.Before(time.Now()))
+
+		cache.Flush()
+		next, ok = cache.NextExpiry()
+		assert.True(t, ok)
+		assert.True(t, next.After(time.Now()))
+	})
+
+	t.Run("default ttl", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+		cache.SetDefaultTTL(time.Minute)
+
+		cache.Add(Jwk{Kid: "kid", Iss: "iss"})
+		next, ok := cache.NextExpiry()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), next, time.Second)
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				kid := strconv.Itoa(i)
+				for j := 0; j < 20; j++ {
+					cache.Add(Jwk{Kid: kid, Iss: "iss"})
+					_, _ = cache.Get(kid, "iss")
+					cache.Flush()
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < 10; i++ {
+			_, err := cache.Get(strconv.Itoa(i), "iss")
+			assert.Nil(t, err)
+		}
+	})
+}
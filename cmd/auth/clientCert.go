@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// CertificateMismatch indicates that a certificate-bound token (RFC 8705) was not presented with its certificate.
+const CertificateMismatch = AuthenticationError("token not bound to client certificate")
+
+type clientCertAuthenticator struct{}
+
+// NewClientCertAuthenticator creates an Authenticator that accepts requests with a client certificate that was
+// verified during the TLS handshake (mTLS). The Principal is derived from the subject alternative names or the subject
+// of the certificate.
+func NewClientCertAuthenticator() Authenticator {
+	return &clientCertAuthenticator{}
+}
+
+func (a *clientCertAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	// only certificates verified against the client CAs are accepted
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, MissingToken
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	return &Principal{
+		Subject:  certificateSubject(cert),
+		ClientId: cert.Subject.CommonName,
+		Method:   MethodMtls,
+		Claims: Claims{
+			"sub":      certificateSubject(cert),
+			"dn":       cert.Subject.String(),
+			"x5t#S256": CertificateThumbprint(cert),
+		},
+	}, nil
+}
+
+// certificateSubject returns the identity of the certificate in the order URI SAN (e.g. SPIFFE id), DNS SAN,
+// email SAN and common name.
+func certificateSubject(cert *x509.Certificate) string {
+	switch {
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	default:
+		return cert.Subject.CommonName
+	}
+}
+
+// CertificateThumbprint returns the base64url encoded SHA-256 thumbprint of the certificate as used in the
+// "x5t#S256" confirmation method (RFC 8705).
+func CertificateThumbprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// checkCertificateBinding validates certificate-bound access tokens (RFC 8705). If the claims contain a
+// "cnf" claim with "x5t#S256" the request must have been made with exactly this client certificate. Tokens without
+// binding are always accepted.
+func checkCertificateBinding(claims Claims, r *http.Request) error {
+	cnf, ok := claims["cnf"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	thumbprint, ok := cnf["x5t#S256"].(string)
+	if !ok {
+		return nil
+	}
+
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return fmt.Errorf("%w: no client certificate presented", CertificateMismatch)
+	}
+	if CertificateThumbprint(r.TLS.PeerCertificates[0]) != thumbprint {
+		return fmt.Errorf("%w: thumbprint differs", CertificateMismatch)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCertificate(t *testing.T, template *x509.Certificate) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(1)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func Test_clientCertAuthenticator_Authenticate(t *testing.T) {
+	a := NewClientCertAuthenticator()
+
+	t.Run("no tls", func(t *testing.T) {
+		_, err := a.Authenticate(httptest.NewRequest("GET", "/", nil))
+		assert.ErrorIs(t, err, MissingToken)
+	})
+
+	t.Run("certificate not verified", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		cert := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "client"}})
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		_, err := a.Authenticate(r)
+		assert.ErrorIs(t, err, MissingToken)
+	})
+
+	t.Run("subject from uri", func(t *testing.T) {
+		spiffe, _ := url.Parse("spiffe://example.org/poi-importer")
+		cert := newTestCertificate(t, &x509.Certificate{
+			Subject:  pkix.Name{CommonName: "client"},
+			DNSNames: []string{"client.example.org"},
+			URIs:     []*url.URL{spiffe},
+		})
+		r := httptest.NewRequest("GET", "/", nil)
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+		p, err := a.Authenticate(r)
+		require.NoError(t, err)
+		assert.Equal(t, "spiffe://example.org/poi-importer", p.Subject)
+		assert.Equal(t, "client", p.ClientId)
+		assert.Equal(t, MethodMtls, p.Method)
+		assert.Equal(t, CertificateThumbprint(cert), p.Claims["x5t#S256"])
+	})
+
+	t.Run("subject from common name", func(t *testing.T) {
+		cert := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "client"}})
+		r := httptest.NewRequest("GET", "/", nil)
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+		p, err := a.Authenticate(r)
+		require.NoError(t, err)
+		assert.Equal(t, "client", p.Subject)
+	})
+}
+
+func Test_checkCertificateBinding(t *testing.T) {
+	cert := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "client"}})
+	other := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "other"}})
+	bound := Claims{"cnf": map[string]interface{}{"x5t#S256": CertificateThumbprint(cert)}}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	assert.NoError(t, checkCertificateBinding(Claims{"sub": "user"}, r), "unbound token")
+	assert.ErrorIs(t, checkCertificateBinding(bound, r), CertificateMismatch, "no certificate")
+
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}}
+	assert.ErrorIs(t, checkCertificateBinding(bound, r), CertificateMismatch, "other certificate")
+
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	assert.NoError(t, checkCertificateBinding(bound, r), "bound certificate")
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultDevTokenTTL is the lifetime of tokens issued by the DevIssuer if no other lifetime is requested.
+const DefaultDevTokenTTL = time.Hour
+
+// DevIssuer mints tokens with a key pair generated at startup. It replaces the OAuth server during development and
+// tests and must never be used in production, everyone that can reach it gets a token with arbitrary scopes.
+type DevIssuer struct {
+	issuer     string
+	kid        string
+	privatePem string
+	jwk        Jwk
+}
+
+// NewDevIssuer generates a new ES256 key pair for issuer. Issuer must be the base url of the service, the JWKS is
+// expected at issuer + ".well-known/jwks.json".
+func NewDevIssuer(issuer string) (*DevIssuer, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := randomString(12)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := jwk.New(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.Set(jwk.KeyIDKey, kid)
+	key.Set(jwk.AlgorithmKey, string(ES256))
+	key.Set(jwk.KeyUsageKey, "sig")
+
+	raw, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	var public Jwk
+	if err := json.Unmarshal(raw, &public); err != nil {
+		return nil, err
+	}
+
+	return &DevIssuer{
+		issuer:     issuer,
+		kid:        kid,
+		privatePem: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		jwk:        public,
+	}, nil
+}
+
+// Issuer returns the iss of the issued tokens.
+func (d *DevIssuer) Issuer() string {
+	return d.issuer
+}
+
+// Jwks returns the public key of the issuer.
+func (d *DevIssuer) Jwks() Jwks {
+	return Jwks{Keys: []Jwk{d.jwk}}
+}
+
+// Issue creates a signed JWT for subject with the given scopes. Additional claims overwrite the generated ones,
+// except iss. If ttl is 0 DefaultDevTokenTTL is used.
+func (d *DevIssuer) Issue(subject string, scopes []string, claims Claims, ttl time.Duration) (string, error) {
+	if subject == "" {
+		return "", errors.New("subject missing")
+	}
+	if ttl <= 0 {
+		ttl = DefaultDevTokenTTL
+	}
+
+	now := time.Now()
+	jti, err := randomString(12)
+	if err != nil {
+		return "", err
+	}
+
+	token := NewToken(Claims{
+		"sub": subject,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+		"jti": jti,
+		"scp": scopes,
+	})
+	token.SetClaims(claims)
+	token.SetClaims(Claims{Iss: d.issuer})
+
+	signed := token.Sign(d.privatePem, ES256, d.kid)
+	if signed == "" {
+		return "", errors.New("signing token failed")
+	}
+	return signed, nil
+}
+
+// JwksHandler serves the JWKS of the issuer.
+func (d *DevIssuer) JwksHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=3600")
+	json.NewEncoder(w).Encode(d.Jwks())
+}
+
+// DevTokenRequest is the body of a request to the TokenHandler.
+type DevTokenRequest struct {
+	Subject string   `json:"sub"`
+	Scopes  []string `json:"scopes"`
+	Claims  Claims   `json:"claims"`
+	// ExpiresIn is the lifetime of the token in seconds.
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+// DevTokenResponse is the answer of the TokenHandler, it uses the names of an OAuth2 token response.
+type DevTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// TokenHandler issues a token for the DevTokenRequest in the body.
+func (d *DevIssuer) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req DevTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresIn) * time.Second
+	if ttl <= 0 {
+		ttl = DefaultDevTokenTTL
+	}
+
+	signed, err := d.Issue(req.Subject, req.Scopes, req.Claims, ttl)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("issuing dev token failed")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(err.Error())
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("subject", req.Subject).Strs("scopes", req.Scopes).Msg("dev token issued")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(DevTokenResponse{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl / time.Second),
+		Scope:       strings.Join(req.Scopes, " "),
+	})
+}
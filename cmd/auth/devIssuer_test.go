@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/download"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDevIssuer_Issue(t *testing.T) {
+	issuer, err := NewDevIssuer("http://127.0.0.1:8000/")
+	require.NoError(t, err)
+
+	_, err = issuer.Issue("", nil, nil, 0)
+	assert.Error(t, err, "subject is mandatory")
+
+	signed, err := issuer.Issue("dev", []string{"poi:read"}, Claims{"tnt": "me", Iss: "http://other/"}, time.Minute)
+	require.NoError(t, err)
+
+	tok, err := NewUnverifiedToken(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8000/", *tok.GetValueForClaim(Iss), "iss can not be overwritten")
+	assert.Equal(t, string(ES256), *tok.GetValueForHeaderKey("alg"))
+
+	// the token must be accepted by the jwt validation with the served keys
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	httpClient := download.NewMockHttpRequester(ctrl)
+	jwks := issuer.Jwks()
+	httpClient.EXPECT().GetContentWithHeader(gomock.Any(), "http://127.0.0.1:8000/.well-known/jwks.json").Return(jwks.String(), http.Header{}, nil)
+
+	cache := JwkCache{}
+	cache.Init()
+	authorizer := NewAuthorizer(NewJwkStore(issuer.Issuer(), httpClient, &cache))
+
+	rsp := serve(authorizer, http.Header{"Authorization": {"Bearer " + signed}})
+	assert.Equal(t, http.StatusTeapot, rsp.Code)
+	assert.Equal(t, "dev", rsp.Header().Get("X-Subject"))
+}
+
+func TestDevIssuer_Handlers(t *testing.T) {
+	issuer, err := NewDevIssuer("http://127.0.0.1:8000/")
+	require.NoError(t, err)
+
+	t.Run("jwks", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		issuer.JwksHandler(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+		var jwks Jwks
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&jwks))
+		require.Len(t, jwks.Keys, 1)
+		assert.Equal(t, "EC", jwks.Keys[0].Kty)
+		assert.Empty(t, jwks.Keys[0].D, "private key must not be published")
+	})
+
+	t.Run("token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := `{"sub": "dev", "scopes": ["poi:read", "poi:write"], "expiresIn": 60}`
+		issuer.TokenHandler(w, httptest.NewRequest(http.MethodPost, "/dev/token", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var rsp DevTokenResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&rsp))
+		assert.Equal(t, "Bearer", rsp.TokenType)
+		assert.Equal(t, int64(60), rsp.ExpiresIn)
+
+		tok, err := NewUnverifiedToken(rsp.AccessToken)
+		require.NoError(t, err)
+		p := NewPrincipal(tok.GetClaims(), MethodJwt)
+		assert.Equal(t, "dev", p.Subject)
+		assert.True(t, p.HasScope("poi:write"))
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		issuer.TokenHandler(w, httptest.NewRequest(http.MethodPost, "/dev/token", strings.NewReader(`{}`)))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
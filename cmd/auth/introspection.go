@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+	"net/http"
+	"net/url"
+	"poi-service/cmd/download"
+	"poi-service/cmd/tracing"
+	"sync"
+	"time"
+)
+
+// Defaults for the caching of introspection results.
+const (
+	// DefaultInactiveTTL is the time an inactive token is remembered, so it is not introspected on every request.
+	DefaultInactiveTTL = time.Minute
+	// DefaultActiveTTL is used for active tokens whose introspection result contains no exp.
+	DefaultActiveTTL = time.Minute
+	// maxIntrospectionResults limits the memory used to cache introspection results.
+	maxIntrospectionResults = 10000
+)
+
+// IntrospectionOption configures optional behaviour of the introspection Authenticator.
+type IntrospectionOption func(i *introspectionAuthenticator)
+
+// WithInactiveTTL sets the time an inactive token is remembered.
+func WithInactiveTTL(ttl time.Duration) IntrospectionOption {
+	return func(i *introspectionAuthenticator) { i.inactiveTTL = ttl }
+}
+
+// NewIntrospectionAuthorizer creates an Authorizer that validates opaque access tokens with an OAuth2 token
+// introspection endpoint (RFC 7662).
+func NewIntrospectionAuthorizer(endpoint, clientId, clientSecret string, client download.HttpRequester, opts ...IntrospectionOption) Authorizer {
+	return NewAuthorizerWith(NewIntrospectionAuthenticator(endpoint, clientId, clientSecret, client, opts...))
+}
+
+// NewIntrospectionAuthenticator creates an Authenticator that validates bearer tokens with an OAuth2 token
+// introspection endpoint (RFC 7662). The service authenticates itself with clientId and clientSecret at the endpoint.
+// Results are cached until the token expires.
+func NewIntrospectionAuthenticator(endpoint, clientId, clientSecret string, client download.HttpRequester, opts ...IntrospectionOption) Authenticator {
+	i := &introspectionAuthenticator{
+		endpoint:     endpoint,
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		client:       client,
+		inactiveTTL:  DefaultInactiveTTL,
+		results:      make(map[string]introspectionResult),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// introspectionAuthenticator implements interface Authenticator
+type introspectionAuthenticator struct {
+	endpoint     string
+	clientId     string
+	clientSecret string
+	client       download.HttpRequester
+	inactiveTTL  time.Duration
+
+	// requests de-duplicates concurrent introspection of the same token
+	requests singleflight.Group
+
+	mu      sync.RWMutex
+	results map[string]introspectionResult
+}
+
+type introspectionResult struct {
+	active  bool
+	claims  Claims
+	expires time.Time
+}
+
+func (i *introspectionAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := getBearerToken(r)
+	if token == "" {
+		return nil, MissingToken
+	}
+
+	if i.client == nil {
+		return nil, fmt.Errorf("%w: http client is nil", DependencyMissing)
+	}
+
+	// never keep the token itself in memory longer than needed
+	hash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(hash[:])
+
+	result, ok := i.getCached(key)
+	if !ok {
+		value, err, _ := i.requests.Do(key, func() (interface{}, error) {
+			// the request is shared, so it must not be cancelled with the first caller
+			return i.introspect(tracing.Detach(r.Context()), token)
+		})
+		if err != nil {
+			return nil, err
+		}
+		result = value.(introspectionResult)
+		i.addCached(key, result)
+	}
+
+	if !result.active {
+		return nil, InactiveToken
+	}
+
+	if err := checkCertificateBinding(result.claims, r); err != nil {
+		return nil, err
+	}
+
+	return NewPrincipal(result.claims, MethodIntrospection), nil
+}
+
+// introspect asks the introspection endpoint for the state of token.
+func (i *introspectionAuthenticator) introspect(ctx context.Context, token string) (result introspectionResult, err error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	// client credentials are form encoded before they are used for basic auth (RFC 6749 section 2.3.1)
+	credentials := url.QueryEscape(i.clientId) + ":" + url.QueryEscape(i.clientSecret)
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+
+	content, err := i.client.PostContent(ctx, i.endpoint, "application/x-www-form-urlencoded", []byte(form.Encode()), header)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("token introspection failed")
+		return
+	}
+
+	claims := Claims{}
+	if err = json.Unmarshal([]byte(content), &claims); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("token introspection response invalid")
+		return
+	}
+
+	now := time.Now()
+	active, _ := claims["active"].(bool)
+	result = introspectionResult{active: active, claims: claims, expires: now.Add(i.inactiveTTL)}
+
+	if active {
+		result.expires = now.Add(DefaultActiveTTL)
+		if exp, ok := claims[Exp].(float64); ok {
+			result.expires = time.Unix(int64(exp), 0)
+		}
+		// an active token whose lifetime is over must not be accepted
+		if !result.expires.After(now) {
+			result.active = false
+			result.expires = now.Add(i.inactiveTTL)
+		}
+	}
+
+	return result, nil
+}
+
+func (i *introspectionAuthenticator) getCached(key string) (introspectionResult, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	result, ok := i.results[key]
+	if !ok || time.Now().After(result.expires) {
+		return introspectionResult{}, false
+	}
+	return result, true
+}
+
+func (i *introspectionAuthenticator) addCached(key string, result introspectionResult) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := time.Now()
+	if len(i.results) >= maxIntrospectionResults {
+		for k, val := range i.results {
+			if now.After(val.expires) {
+				delete(i.results, k)
+			}
+		}
+	}
+	// if still full the token is introspected again on the next request
+	if len(i.results) < maxIntrospectionResults {
+		i.results[key] = result
+	}
+}
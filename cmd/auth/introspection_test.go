@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"poi-service/cmd/download"
+	"testing"
+	"time"
+)
+
+func Test_introspectionAuthenticator_Authenticate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	endpoint := "http://127.0.0.1:4445/oauth2/introspect"
+	request := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+
+	authenticate := func(a Authenticator, token string) error {
+		_, err := a.Authenticate(request(token))
+		return err
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		authenticatorToTest := NewIntrospectionAuthenticator(endpoint, "id", "secret", download.NewMockHttpRequester(ctrl))
+		_, err := authenticatorToTest.Authenticate(request(""))
+		assert.Equal(t, MissingToken, err)
+	})
+
+	t.Run("active token is cached", func(t *testing.T) {
+		httpClient := download.NewMockHttpRequester(ctrl)
+		authenticatorToTest := NewIntrospectionAuthenticator(endpoint, "my id", "secret", httpClient)
+
+		exp := time.Now().Add(time.Hour).Unix()
+		httpClient.EXPECT().PostContent(gomock.Any(), endpoint, "application/x-www-form-urlencoded", gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+			func(_ context.Context, _ string, _ string, body []byte, header http.Header) (string, error) {
+				form, err := url.ParseQuery(string(body))
+				require.Nil(t, err)
+				assert.Equal(t, "opaque", form.Get("token"))
+
+				r := http.Request{Header: header}
+				id, secret, ok := r.BasicAuth()
+				assert.True(t, ok)
+				assert.Equal(t, "my+id", id)
+				assert.Equal(t, "secret", secret)
+				return fmt.Sprintf(`{"active":true,"sub":"someone","exp":%d}`, exp), nil
+			})
+
+		for i := 0; i < 2; i++ {
+			principal, err := authenticatorToTest.Authenticate(request("opaque"))
+			assert.Nil(t, err)
+			assert.Equal(t, "someone", principal.Subject)
+			assert.Equal(t, MethodIntrospection, principal.Method)
+		}
+	})
+
+	t.Run("inactive token is cached", func(t *testing.T) {
+		httpClient := download.NewMockHttpRequester(ctrl)
+		authenticatorToTest := NewIntrospectionAuthenticator(endpoint, "id", "secret", httpClient)
+
+		httpClient.EXPECT().PostContent(gomock.Any(), endpoint, gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(`{"active":false}`, nil)
+
+		assert.Equal(t, InactiveToken, authenticate(authenticatorToTest, "opaque"))
+		assert.Equal(t, InactiveToken, authenticate(authenticatorToTest, "opaque"))
+	})
+
+	t.Run("expired active token", func(t *testing.T) {
+		httpClient := download.NewMockHttpRequester(ctrl)
+		authenticatorToTest := NewIntrospectionAuthenticator(endpoint, "id", "secret", httpClient)
+
+		exp := time.Now().Add(-time.Hour).Unix()
+		httpClient.EXPECT().PostContent(gomock.Any(), endpoint, gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Sprintf(`{"active":true,"exp":%d}`, exp), nil)
+
+		assert.Equal(t, InactiveToken, authenticate(authenticatorToTest, "opaque"))
+	})
+
+	t.Run("inactive ttl", func(t *testing.T) {
+		httpClient := download.NewMockHttpRequester(ctrl)
+		authenticatorToTest := NewIntrospectionAuthenticator(endpoint, "id", "secret", httpClient, WithInactiveTTL(0))
+
+		httpClient.EXPECT().PostContent(gomock.Any(), endpoint, gomock.Any(), gomock.Any(), gomock.Any()).Times(2).Return(`{"active":false}`, nil)
+
+		assert.Equal(t, InactiveToken, authenticate(authenticatorToTest, "opaque"))
+		assert.Equal(t, InactiveToken, authenticate(authenticatorToTest, "opaque"))
+	})
+
+	t.Run("endpoint not reachable", func(t *testing.T) {
+		httpClient := download.NewMockHttpRequester(ctrl)
+		authenticatorToTest := NewIntrospectionAuthenticator(endpoint, "id", "secret", httpClient)
+
+		httpClient.EXPECT().PostContent(gomock.Any(), endpoint, gomock.Any(), gomock.Any(), gomock.Any()).Return("", errors.New("some error"))
+		assert.NotNil(t, authenticate(authenticatorToTest, "opaque"))
+	})
+
+	t.Run("fallback from jwt", func(t *testing.T) {
+		httpClient := download.NewMockHttpRequester(ctrl)
+		jwkStore := NewMockJwkStore(ctrl)
+		authorizerToTest := NewAuthorizerWith(
+			NewJwtAuthenticator(jwkStore),
+			NewIntrospectionAuthenticator(endpoint, "id", "secret", httpClient))
+
+		httpClient.EXPECT().PostContent(gomock.Any(), endpoint, gomock.Any(), gomock.Any(), gomock.Any()).Return(`{"active":true}`, nil)
+
+		w := serve(authorizerToTest, http.Header{"Authorization": {"Bearer opaque"}})
+		assert.Equal(t, http.StatusTeapot, w.Code)
+	})
+}
@@ -1,9 +1,17 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 	"poi-service/cmd/download"
+	"poi-service/cmd/metrics"
+	"poi-service/cmd/tracing"
+	"sync"
+	"time"
 )
 
 // JwkStore will take care to synchronize memory and remote backends to provide JWKs.
@@ -12,8 +20,18 @@ type JwkStore interface {
 	// can occur:
 	// - InvalidParameter: The given parameters are invalid. Not authorized.
 	// - NoKeyAvailable: The rawJWK was retrieved successfully but contains not the requested JWK. Not authorized.
+	// - UntrustedIssuer: The issuer is not the trusted backend. Not authorized.
 	// - other errors: Something goes wrong. Check the error/logs for details. Retry needed.
-	GetJWK(kid, iss string) (rawJWK string, err error)
+	GetJWK(ctx context.Context, kid, iss string) (rawJWK string, err error)
+	// Refresh downloads the JWKS of the trusted backend and updates the cache.
+	Refresh() error
+	// Ready checks the cached keys without downloading them. It returns NoKeyAvailable if there are no valid keys of
+	// the trusted backend and KeysOutdated if the last successful download is older than maxAge. Keys added
+	// WithStaticKeys are never outdated.
+	Ready(maxAge time.Duration) error
+	// RunRefresh proactively refreshes the cached keys shortly before they expire, so key rotation is picked up
+	// without blocking requests. It blocks until ctx is done.
+	RunRefresh(ctx context.Context)
 }
 
 //------------------------------------------------------------------------------
@@ -36,6 +54,13 @@ func (e InvalidParameterError) Error() string { return string(e) }
 
 //------------------------------------------------------------------------------
 
+// UntrustedIssuer is given if the requested issuer is not the trusted backend.
+const UntrustedIssuer = UntrustedIssuerError("issuer not trusted")
+
+type UntrustedIssuerError string
+
+func (e UntrustedIssuerError) Error() string { return string(e) }
+
 //------------------------------------------------------------------------------
 
 // DependencyMissing indicates that there is no issue but also no JWK available at all.
@@ -47,11 +72,92 @@ func (e DependencyMissingError) Error() string { return string(e) }
 
 //------------------------------------------------------------------------------
 
+// KeysOutdated indicates that the JWKS was not downloaded successfully for a while, rotated keys may be missing.
+const KeysOutdated = KeysOutdatedError("JWKs outdated")
+
+type KeysOutdatedError string
+
+func (e KeysOutdatedError) Error() string { return string(e) }
+
+//------------------------------------------------------------------------------
+
+// Defaults for the background refresh of the JwkStore.
+const (
+	// DefaultRefreshMargin is the time before expiry at which cached keys are refreshed.
+	DefaultRefreshMargin = time.Minute
+	// DefaultMinRefreshWait is the minimum wait time between two background refreshes, e.g. if the backend
+	// responds with very short cache lifetimes or a refresh failed.
+	DefaultMinRefreshWait = 10 * time.Second
+	// DefaultMaxRefreshWait is the maximum wait time between two background refreshes.
+	DefaultMaxRefreshWait = 15 * time.Minute
+	// DefaultMinFetchInterval is the minimum time between two downloads triggered by requests for unknown keys.
+	DefaultMinFetchInterval = 30 * time.Second
+	// DefaultNegativeCacheTTL is the time a kid that could not be found in the JWKS is remembered as unknown.
+	DefaultNegativeCacheTTL = 5 * time.Minute
+	// maxUnknownKids limits the memory used for negative caching, since kids are chosen by the caller.
+	maxUnknownKids = 10000
+	// staticKeyLifetime is the expiry of keys added WithStaticKeys, long enough for every process.
+	staticKeyLifetime = 100 * 365 * 24 * time.Hour
+)
+
+// JwkStoreOption configures optional behaviour of the JwkStore.
+type JwkStoreOption func(store *jwkStore)
+
+// WithRefreshMargin sets the time before expiry at which cached keys are refreshed in the background.
+func WithRefreshMargin(margin time.Duration) JwkStoreOption {
+	return func(store *jwkStore) { store.refreshMargin = margin }
+}
+
+// WithRefreshWait limits the wait time between two background refreshes.
+func WithRefreshWait(min, max time.Duration) JwkStoreOption {
+	return func(store *jwkStore) {
+		store.minRefreshWait = min
+		store.maxRefreshWait = max
+	}
+}
+
+// WithMinFetchInterval sets the minimum time between two downloads that are triggered by requests for unknown keys.
+func WithMinFetchInterval(interval time.Duration) JwkStoreOption {
+	return func(store *jwkStore) { store.minFetchInterval = interval }
+}
+
+// WithNegativeCacheTTL sets the time a kid that is not part of the JWKS is remembered as unknown.
+func WithNegativeCacheTTL(ttl time.Duration) JwkStoreOption {
+	return func(store *jwkStore) { store.negativeCacheTTL = ttl }
+}
+
+// WithStaticKeys adds keys of the trusted backend that are known without download, e.g. of the DevIssuer. They never
+// expire, so the store is ready without refresh.
+func WithStaticKeys(jwks Jwks) JwkStoreOption {
+	return func(store *jwkStore) {
+		for _, key := range jwks.Keys {
+			key.Iss = store.trustedBackendUrl
+			store.cache.AddWithExpiry(key, time.Now().Add(staticKeyLifetime))
+		}
+		store.static = true
+		store.lastRefresh = time.Now()
+	}
+}
+
 // NewJwkStore creates a new cache instance.
 // trustedBackendUrl: url that is trusted as iss
 // client: http download client
-func NewJwkStore(trustedBackendUrl string, client download.HttpRequester, cache JwkCache) JwkStore {
-	store := jwkStore{trustedBackendUrl: trustedBackendUrl, client: client, cache: cache}
+// cache: initialized cache that holds the downloaded keys
+func NewJwkStore(trustedBackendUrl string, client download.HttpRequester, cache *JwkCache, opts ...JwkStoreOption) JwkStore {
+	store := jwkStore{
+		trustedBackendUrl: trustedBackendUrl,
+		client:            client,
+		cache:             cache,
+		refreshMargin:     DefaultRefreshMargin,
+		minRefreshWait:    DefaultMinRefreshWait,
+		maxRefreshWait:    DefaultMaxRefreshWait,
+		minFetchInterval:  DefaultMinFetchInterval,
+		negativeCacheTTL:  DefaultNegativeCacheTTL,
+		unknownKids:       make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(&store)
+	}
 	return &store
 }
 
@@ -59,56 +165,155 @@ func NewJwkStore(trustedBackendUrl string, client download.HttpRequester, cache
 type jwkStore struct {
 	trustedBackendUrl string
 	client            download.HttpRequester
-	cache             JwkCache
+	cache             *JwkCache
+	refreshMargin     time.Duration
+	minRefreshWait    time.Duration
+	maxRefreshWait    time.Duration
+	minFetchInterval  time.Duration
+	negativeCacheTTL  time.Duration
+
+	// fetches de-duplicates concurrent downloads of the same JWKS
+	fetches singleflight.Group
+
+	mu          sync.Mutex
+	lastFetch   time.Time
+	lastRefresh time.Time
+	// static is true if the keys are added WithStaticKeys, they do not become outdated
+	static      bool
+	unknownKids map[string]time.Time
 }
 
-func (j *jwkStore) GetJWK(kid, iss string) (rawJWKs string, err error) {
+func (j *jwkStore) GetJWK(ctx context.Context, kid, iss string) (rawJWKs string, err error) {
+	ctx, span := tracer.Start(ctx, "JwkStore.GetJWK", trace.WithAttributes(attribute.String("kid", kid)))
+	defer func() { tracing.End(span, err) }()
+
 	// First try to get the jwk from cache -> we might downloaded it already
 	rawJWKs, err = j.getFromCache(kid, iss)
+	metrics.ObserveJwksCache(err == nil)
 	if err == nil {
 		return
 	}
 
+	// the kid was requested recently but was not part of the JWKS -> do not bother the backend again
+	if j.isUnknown(kid, iss) {
+		return "", NoKeyAvailable
+	}
+
 	// not available we must download new jwks -> stores a found jwk to cache
-	if err := j.fetchFromBackend(kid, iss); err != nil {
+	fetched, err := j.fetchFromBackend(ctx, kid, iss)
+	if err != nil {
 		return "", err
 	}
 
 	// Now get the downloaded jwk from cache
 	rawJWKs, err = j.getFromCache(kid, iss)
+	if err != nil && fetched {
+		// only a download that lacks the kid proves it unknown, a skipped download may miss a rotated key
+		j.rememberUnknown(kid, iss)
+	}
 	return
 }
 
-func (j *jwkStore) fetchFromBackend(kid, iss string) (err error) {
-	log.Info().Msg("Downloading JWKs")
+// fetchFromBackend downloads the JWKS of iss, fetched is false if the download was skipped because of the minimum
+// fetch interval.
+func (j *jwkStore) fetchFromBackend(ctx context.Context, kid, iss string) (fetched bool, err error) {
+	log.Ctx(ctx).Info().Msg("Downloading JWKs")
 
 	if iss == "" || kid == "" {
 		err = InvalidParameter
-		log.Warn().Err(err).Str("kid", kid).Str("issuer", iss).Msg("GetJWK request failed")
+		log.Ctx(ctx).Warn().Err(err).Str("kid", kid).Str("issuer", iss).Msg("GetJWK request failed")
 		return
 	}
 
 	if j.client == nil {
 		err = DependencyMissing
-		log.Warn().Err(err).Msg("http client")
+		log.Ctx(ctx).Warn().Err(err).Msg("http client")
 		return
 	}
 
 	// check if the backend is a trusted one -> otherwise someone can just its own server
 	if iss != j.trustedBackendUrl {
-		log.Warn().Err(err).Msg("iss not a trusted backend")
+		err = UntrustedIssuer
+		log.Ctx(ctx).Warn().Err(err).Str("issuer", iss).Msg("iss not a trusted backend")
 		return
 	}
 
-	rawData, err := j.client.GetContent(iss + ".well-known/jwks.json")
+	// concurrent requests for unknown keys share a single download, which is additionally rate limited. The download
+	// must not be cancelled with the first request.
+	result, err, _ := j.fetches.Do(iss, func() (interface{}, error) {
+		if !j.fetchAllowed(time.Now()) {
+			log.Ctx(ctx).Info().Str("issuer", iss).Msg("JWKs downloaded recently, skipping download")
+			return false, nil
+		}
+		return true, j.download(tracing.Detach(ctx), iss)
+	})
+	fetched, _ = result.(bool)
+	return
+}
+
+// fetchAllowed returns true and records the fetch if the last download is at least minFetchInterval ago.
+func (j *jwkStore) fetchAllowed(now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if now.Sub(j.lastFetch) < j.minFetchInterval {
+		return false
+	}
+	j.lastFetch = now
+	return true
+}
+
+func (j *jwkStore) isUnknown(kid, iss string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	expires, ok := j.unknownKids[kid+iss]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(j.unknownKids, kid+iss)
+		return false
+	}
+	return true
+}
+
+func (j *jwkStore) rememberUnknown(kid, iss string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	if len(j.unknownKids) >= maxUnknownKids {
+		for key, expires := range j.unknownKids {
+			if now.After(expires) {
+				delete(j.unknownKids, key)
+			}
+		}
+	}
+	// if still full the rate limit of downloads protects the backend
+	if len(j.unknownKids) < maxUnknownKids {
+		j.unknownKids[kid+iss] = now.Add(j.negativeCacheTTL)
+	}
+}
+
+// download fetches the JWKS of iss and stores all contained keys in the cache. The lifetime of the keys is taken
+// from the caching directives of the response.
+func (j *jwkStore) download(ctx context.Context, iss string) (err error) {
+	defer func() { metrics.ObserveJwksFetch(err) }()
+
+	rawData, header, err := j.client.GetContentWithHeader(ctx, iss+".well-known/jwks.json")
 	if err != nil {
 		return
 	}
 
+	now := time.Now()
+	expires, hasExpiry := download.ExpiresAt(header, now)
+	if hasExpiry && expires.Before(now.Add(j.minRefreshWait)) {
+		// keys that expire immediately (e.g. no-store) could never be used, keep them at least until the next refresh
+		expires = now.Add(j.minRefreshWait)
+	}
+
 	var jwks Jwks
 	err = json.Unmarshal([]byte(rawData), &jwks)
 	if err != nil {
-		log.Warn().Err(err).Msg("during unmarshal")
+		log.Ctx(ctx).Warn().Err(err).Msg("during unmarshal")
 		return
 	}
 
@@ -118,10 +323,18 @@ func (j *jwkStore) fetchFromBackend(kid, iss string) (err error) {
 			// add iss since it is needed as key in cache
 			val.Iss = iss
 		}
-		j.cache.Add(val)
+		if hasExpiry {
+			j.cache.AddWithExpiry(val, expires)
+		} else {
+			j.cache.Add(val)
+		}
 	}
 
-	log.Info().Msg("Downloading JWKs done")
+	j.mu.Lock()
+	j.lastRefresh = time.Now()
+	j.mu.Unlock()
+
+	log.Ctx(ctx).Info().Msg("Downloading JWKs done")
 	return nil
 }
 
@@ -133,3 +346,62 @@ func (j *jwkStore) getFromCache(kid, iss string) (rawJWKs string, err error) {
 
 	return jwk.String(), nil
 }
+
+func (j *jwkStore) Refresh() error {
+	if j.client == nil {
+		return DependencyMissing
+	}
+	log.Info().Str("issuer", j.trustedBackendUrl).Msg("Refreshing JWKs")
+	_, err, _ := j.fetches.Do(j.trustedBackendUrl, func() (interface{}, error) {
+		j.mu.Lock()
+		j.lastFetch = time.Now()
+		j.mu.Unlock()
+		return nil, j.download(context.Background(), j.trustedBackendUrl)
+	})
+	return err
+}
+
+func (j *jwkStore) Ready(maxAge time.Duration) error {
+	if j.cache.Count(j.trustedBackendUrl) == 0 {
+		return NoKeyAvailable
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.static && time.Since(j.lastRefresh) > maxAge {
+		return KeysOutdated
+	}
+	return nil
+}
+
+func (j *jwkStore) RunRefresh(ctx context.Context) {
+	wait := time.Duration(0)
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := j.Refresh(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("background refresh of JWKs failed")
+		}
+		j.cache.Flush()
+		wait = j.nextRefreshWait(time.Now())
+	}
+}
+
+// nextRefreshWait calculates the time until the next background refresh is due.
+func (j *jwkStore) nextRefreshWait(now time.Time) time.Duration {
+	wait := j.maxRefreshWait
+	if next, ok := j.cache.NextExpiry(); ok {
+		if untilExpiry := next.Sub(now) - j.refreshMargin; untilExpiry < wait {
+			wait = untilExpiry
+		}
+	}
+	if wait < j.minRefreshWait {
+		wait = j.minRefreshWait
+	}
+	return wait
+}
@@ -5,7 +5,9 @@
 package auth
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,16 +36,56 @@ func (m *MockJwkStore) EXPECT() *MockJwkStoreMockRecorder {
 }
 
 // GetJWK mocks base method.
-func (m *MockJwkStore) GetJWK(kid, iss string) (string, error) {
+func (m *MockJwkStore) GetJWK(ctx context.Context, kid, iss string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetJWK", kid, iss)
+	ret := m.ctrl.Call(m, "GetJWK", ctx, kid, iss)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetJWK indicates an expected call of GetJWK.
-func (mr *MockJwkStoreMockRecorder) GetJWK(kid, iss interface{}) *gomock.Call {
+func (mr *MockJwkStoreMockRecorder) GetJWK(ctx, kid, iss interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJWK", reflect.TypeOf((*MockJwkStore)(nil).GetJWK), kid, iss)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJWK", reflect.TypeOf((*MockJwkStore)(nil).GetJWK), ctx, kid, iss)
+}
+
+// Ready mocks base method.
+func (m *MockJwkStore) Ready(maxAge time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ready", maxAge)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ready indicates an expected call of Ready.
+func (mr *MockJwkStoreMockRecorder) Ready(maxAge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ready", reflect.TypeOf((*MockJwkStore)(nil).Ready), maxAge)
+}
+
+// Refresh mocks base method.
+func (m *MockJwkStore) Refresh() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockJwkStoreMockRecorder) Refresh() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockJwkStore)(nil).Refresh))
+}
+
+// RunRefresh mocks base method.
+func (m *MockJwkStore) RunRefresh(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RunRefresh", ctx)
+}
+
+// RunRefresh indicates an expected call of RunRefresh.
+func (mr *MockJwkStoreMockRecorder) RunRefresh(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunRefresh", reflect.TypeOf((*MockJwkStore)(nil).RunRefresh), ctx)
 }
@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"context"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"poi-service/cmd/download"
+	"sync"
 	"testing"
+	"time"
 )
 
 func Test_jwkStore_GetJWK(t *testing.T) {
@@ -22,9 +26,9 @@ func Test_jwkStore_GetJWK(t *testing.T) {
 		cache.Init()
 		cache.Add(jwkToTest)
 
-		storeToTest := NewJwkStore("", httpClient, cache)
+		storeToTest := NewJwkStore("", httpClient, &cache)
 
-		jwk, err := storeToTest.GetJWK(kid, iss)
+		jwk, err := storeToTest.GetJWK(context.Background(), kid, iss)
 		assert.Nil(t, err)
 		assert.Equal(t, jwkToTest.String(), jwk)
 	})
@@ -33,9 +37,9 @@ func Test_jwkStore_GetJWK(t *testing.T) {
 		cache := JwkCache{}
 		cache.Init()
 
-		storeToTest := NewJwkStore("", httpClient, cache)
+		storeToTest := NewJwkStore("", httpClient, &cache)
 
-		_, err := storeToTest.GetJWK(kid, "")
+		_, err := storeToTest.GetJWK(context.Background(), kid, "")
 		assert.NotNil(t, err)
 		assert.Equal(t, err, InvalidParameter)
 	})
@@ -44,10 +48,10 @@ func Test_jwkStore_GetJWK(t *testing.T) {
 		cache := JwkCache{}
 		cache.Init()
 
-		storeToTest := NewJwkStore("abc", httpClient, cache)
+		storeToTest := NewJwkStore("abc", httpClient, &cache)
 
-		_, err := storeToTest.GetJWK(kid, "def")
-		assert.NotNil(t, err)
+		_, err := storeToTest.GetJWK(context.Background(), kid, "def")
+		assert.Equal(t, UntrustedIssuer, err)
 	})
 
 	t.Run("download: success", func(t *testing.T) {
@@ -56,11 +60,207 @@ func Test_jwkStore_GetJWK(t *testing.T) {
 		jwks := Jwks{}
 		jwks.Keys = append(jwks.Keys, jwkToTest)
 
-		httpClient.EXPECT().GetContent(iss+".well-known/jwks.json").Times(1).Return(jwks.String(), nil)
+		httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Times(1).Return(jwks.String(), nil, nil)
 
-		storeToTest := NewJwkStore(iss, httpClient, cache)
-		jwk, err := storeToTest.GetJWK(kid, iss)
+		storeToTest := NewJwkStore(iss, httpClient, &cache)
+		jwk, err := storeToTest.GetJWK(context.Background(), kid, iss)
 		assert.Nil(t, err)
 		assert.Equal(t, jwkToTest.String(), jwk)
 	})
 }
+
+func Test_jwkStore_UnknownKid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	httpClient := download.NewMockHttpRequester(ctrl)
+
+	iss := "http://test.de"
+	jwks := Jwks{Keys: []Jwk{{Kid: "known"}}}
+
+	t.Run("negative caching", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+
+		httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Times(1).Return(jwks.String(), nil, nil)
+
+		storeToTest := NewJwkStore(iss, httpClient, &cache, WithMinFetchInterval(0))
+		for i := 0; i < 3; i++ {
+			_, err := storeToTest.GetJWK(context.Background(), "unknown", iss)
+			assert.Equal(t, NoKeyAvailable, err)
+		}
+	})
+
+	t.Run("minimum fetch interval", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+
+		httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Times(1).Return(jwks.String(), nil, nil)
+
+		storeToTest := NewJwkStore(iss, httpClient, &cache, WithNegativeCacheTTL(0))
+		for _, kid := range []string{"a", "b", "c"} {
+			_, err := storeToTest.GetJWK(context.Background(), kid, iss)
+			assert.Equal(t, NoKeyAvailable, err)
+		}
+	})
+
+	t.Run("kid rotated within the minimum fetch interval", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+		rotated := Jwks{Keys: []Jwk{{Kid: "known"}, {Kid: "rotated"}}}
+
+		gomock.InOrder(
+			httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Return(jwks.String(), nil, nil),
+			httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Return(rotated.String(), nil, nil),
+		)
+
+		storeToTest := NewJwkStore(iss, httpClient, &cache, WithMinFetchInterval(50*time.Millisecond))
+		_, err := storeToTest.GetJWK(context.Background(), "other", iss)
+		assert.Equal(t, NoKeyAvailable, err)
+		// the download is skipped, so the kid is not remembered as unknown
+		_, err = storeToTest.GetJWK(context.Background(), "rotated", iss)
+		assert.Equal(t, NoKeyAvailable, err)
+
+		time.Sleep(60 * time.Millisecond)
+		_, err = storeToTest.GetJWK(context.Background(), "rotated", iss)
+		assert.Nil(t, err)
+	})
+
+	t.Run("concurrent fetches are de-duplicated", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+
+		release := make(chan struct{})
+		httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Times(1).DoAndReturn(
+			func(context.Context, string) (string, http.Header, error) {
+				<-release
+				return jwks.String(), nil, nil
+			})
+
+		storeToTest := NewJwkStore(iss, httpClient, &cache, WithMinFetchInterval(0))
+		wg := sync.WaitGroup{}
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := storeToTest.GetJWK(context.Background(), "known", iss)
+				assert.Nil(t, err)
+			}()
+		}
+		// give the goroutines time to join the running download
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+	})
+}
+
+func Test_jwkStore_Refresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	httpClient := download.NewMockHttpRequester(ctrl)
+
+	kid := "unique"
+	iss := "http://test.de"
+	jwks := Jwks{Keys: []Jwk{{Kid: kid}}}
+
+	t.Run("expiry taken from cache-control", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+
+		header := http.Header{"Cache-Control": {"max-age=600"}}
+		httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Times(1).Return(jwks.String(), header, nil)
+
+		storeToTest := NewJwkStore(iss, httpClient, &cache)
+		assert.Nil(t, storeToTest.Refresh())
+
+		next, ok := cache.NextExpiry()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), next, 5*time.Second)
+
+		_, err := storeToTest.GetJWK(context.Background(), kid, iss)
+		assert.Nil(t, err)
+	})
+
+	t.Run("no-store is kept until next refresh", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+
+		header := http.Header{"Cache-Control": {"no-store"}}
+		httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Times(1).Return(jwks.String(), header, nil)
+
+		storeToTest := NewJwkStore(iss, httpClient, &cache)
+		assert.Nil(t, storeToTest.Refresh())
+
+		_, err := storeToTest.GetJWK(context.Background(), kid, iss)
+		assert.Nil(t, err)
+	})
+
+	t.Run("background refresh", func(t *testing.T) {
+		cache := JwkCache{}
+		cache.Init()
+
+		refreshed := make(chan struct{}, 10)
+		header := http.Header{"Cache-Control": {"max-age=0"}}
+		httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").MinTimes(2).DoAndReturn(
+			func(context.Context, string) (string, http.Header, error) {
+				select {
+				case refreshed <- struct{}{}:
+				default:
+				}
+				return jwks.String(), header, nil
+			})
+
+		storeToTest := NewJwkStore(iss, httpClient, &cache, WithRefreshWait(time.Millisecond, time.Second))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			storeToTest.RunRefresh(ctx)
+			close(done)
+		}()
+
+		<-refreshed
+		<-refreshed
+		cancel()
+		<-done
+	})
+}
+
+func Test_jwkStore_Ready(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	httpClient := download.NewMockHttpRequester(ctrl)
+
+	iss := "http://test.de"
+	jwks := Jwks{Keys: []Jwk{{Kid: "unique"}}}
+
+	cache := JwkCache{}
+	cache.Init()
+	storeToTest := NewJwkStore(iss, httpClient, &cache)
+	assert.Equal(t, NoKeyAvailable, storeToTest.Ready(time.Minute))
+
+	httpClient.EXPECT().GetContentWithHeader(gomock.Any(), iss+".well-known/jwks.json").Times(1).Return(jwks.String(), nil, nil)
+	assert.Nil(t, storeToTest.Refresh())
+	// the cached keys are checked, nothing is downloaded
+	assert.Nil(t, storeToTest.Ready(time.Minute))
+	assert.Nil(t, storeToTest.Ready(time.Minute))
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, KeysOutdated, storeToTest.Ready(5*time.Millisecond))
+}
+
+func Test_jwkStore_staticKeys(t *testing.T) {
+	iss := "http://127.0.0.1:8000/"
+	cache := JwkCache{}
+	cache.Init()
+	// nothing is downloaded, the client is not needed
+	storeToTest := NewJwkStore(iss, nil, &cache, WithStaticKeys(Jwks{Keys: []Jwk{{Kid: "dev"}}}))
+
+	rawJWK, err := storeToTest.GetJWK(context.Background(), "dev", iss)
+	assert.Nil(t, err)
+	assert.Contains(t, rawJWK, "dev")
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, storeToTest.Ready(5*time.Millisecond), "static keys are not outdated")
+}
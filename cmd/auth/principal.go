@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+)
+
+// Authentication methods a Principal can be authenticated with.
+const (
+	MethodJwt           = "jwt"
+	MethodIntrospection = "introspection"
+	MethodApiKey        = "apikey"
+	MethodMtls          = "mtls"
+)
+
+// Principal describes the authenticated caller of a request.
+type Principal struct {
+	// Subject identifies the caller, e.g. the user or the machine client.
+	Subject string `json:"sub,omitempty"`
+	// ClientId is the OAuth2 client the token was issued to.
+	ClientId string `json:"client_id,omitempty"`
+	// Scopes granted to the caller.
+	Scopes []string `json:"scopes,omitempty"`
+	// Tenant the caller belongs to.
+	Tenant string `json:"tenant,omitempty"`
+	// Method the caller was authenticated with.
+	Method string `json:"method,omitempty"`
+	// Claims contains all raw claims of the token.
+	Claims Claims `json:"-"`
+}
+
+// NewPrincipal creates a Principal from the claims of a token or an introspection response. Besides the registered
+// claims the common variants used by identity providers for client id, scopes and tenant are evaluated.
+func NewPrincipal(claims Claims, method string) *Principal {
+	p := &Principal{Method: method, Claims: claims}
+	p.Subject = firstString(claims, "sub")
+	p.ClientId = firstString(claims, "client_id", "cid", "azp")
+	p.Tenant = firstString(claims, "tnt", "tenant", "tid")
+	p.Scopes = scopesOf(claims)
+	return p
+}
+
+// HasScope returns true if the scope was granted to the principal.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Id returns the identifier that should be used to attribute actions to the principal, which is the subject or the
+// client id if the token has no subject.
+func (p *Principal) Id() string {
+	if p.Subject != "" {
+		return p.Subject
+	}
+	return p.ClientId
+}
+
+func firstString(claims Claims, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := claims[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// scopesOf supports scopes as list ("scp") as well as space separated string ("scope", RFC 8693).
+func scopesOf(claims Claims) (scopes []string) {
+	for _, key := range []string{"scp", "scope", "scopes"} {
+		switch value := claims[key].(type) {
+		case string:
+			scopes = append(scopes, strings.Fields(value)...)
+		case []string:
+			scopes = append(scopes, value...)
+		case []interface{}:
+			for _, v := range value {
+				if s, ok := v.(string); ok {
+					scopes = append(scopes, s)
+				}
+			}
+		}
+	}
+	return
+}
+
+//------------------------------------------------------------------------------
+
+// principalKey is the context key of the Principal, unexported to prevent collisions with other packages.
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the Principal of the authenticated caller. ok is false if the request was not
+// authenticated.
+func PrincipalFromContext(ctx context.Context) (p *Principal, ok bool) {
+	p, ok = ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// SubjectFromContext returns the subject of the authenticated caller or an empty string.
+func SubjectFromContext(ctx context.Context) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.Subject
+	}
+	return ""
+}
+
+// ScopesFromContext returns the scopes of the authenticated caller.
+func ScopesFromContext(ctx context.Context) []string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.Scopes
+	}
+	return nil
+}
+
+// RequireScope creates a middleware that only passes requests of principals with scope. Requests without principal
+// are rejected with 401, requests of principals without the scope with 403.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if !p.HasScope(scope) {
+				log.Ctx(r.Context()).Warn().Str("subject", p.Subject).Str("scope", scope).Msg("scope missing")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
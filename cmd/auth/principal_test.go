@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPrincipal(t *testing.T) {
+	t.Run("scopes as list", func(t *testing.T) {
+		p := NewPrincipal(Claims{
+			"sub":       "user",
+			"client_id": "my-client",
+			"scp":       []interface{}{"poi:read", "poi:write"},
+			"tnt":       "me",
+		}, MethodJwt)
+
+		assert.Equal(t, "user", p.Subject)
+		assert.Equal(t, "my-client", p.ClientId)
+		assert.Equal(t, "me", p.Tenant)
+		assert.Equal(t, MethodJwt, p.Method)
+		assert.True(t, p.HasScope("poi:write"))
+		assert.False(t, p.HasScope("poi:admin"))
+		assert.Equal(t, "user", p.Id())
+	})
+
+	t.Run("scopes as string", func(t *testing.T) {
+		p := NewPrincipal(Claims{"azp": "my-client", "scope": "poi:read  poi:write"}, MethodIntrospection)
+
+		assert.Equal(t, []string{"poi:read", "poi:write"}, p.Scopes)
+		assert.Equal(t, "my-client", p.ClientId)
+		assert.Equal(t, "my-client", p.Id())
+	})
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+	assert.Empty(t, SubjectFromContext(context.Background()))
+	assert.Nil(t, ScopesFromContext(context.Background()))
+
+	ctx := WithPrincipal(context.Background(), &Principal{Subject: "user", Scopes: []string{"a"}})
+	p, ok := PrincipalFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "user", p.Subject)
+	assert.Equal(t, "user", SubjectFromContext(ctx))
+	assert.Equal(t, []string{"a"}, ScopesFromContext(ctx))
+
+	_, ok = PrincipalFromContext(WithPrincipal(context.Background(), nil))
+	assert.False(t, ok)
+}
@@ -1,12 +1,22 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
-	"github.com/dgrijalva/jwt-go"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
 	les "github.com/lestrrat-go/jwx/jwt"
 	"github.com/rs/zerolog/log"
 	"strconv"
+	"strings"
 )
 
 type SignatureAlgorithm string
@@ -14,8 +24,34 @@ type SignatureAlgorithm string
 // Supported values for SignatureAlgorithm
 const (
 	RS256 SignatureAlgorithm = "RS256" // RSASSA-PKCS-v1.5 using SHA-256
+	PS256 SignatureAlgorithm = "PS256" // RSASSA-PSS using SHA-256 and MGF1 with SHA-256
+	ES256 SignatureAlgorithm = "ES256" // ECDSA using P-256 and SHA-256
+	ES384 SignatureAlgorithm = "ES384" // ECDSA using P-384 and SHA-384
+	ES512 SignatureAlgorithm = "ES512" // ECDSA using P-521 and SHA-512
+	EdDSA SignatureAlgorithm = "EdDSA" // EdDSA using Ed25519
 )
 
+// curves maps the ECDSA algorithms to the curve that must be used by the key.
+var curves = map[SignatureAlgorithm]string{
+	ES256: "P-256",
+	ES384: "P-384",
+	ES512: "P-521",
+}
+
+//------------------------------------------------------------------------------
+
+// UnsupportedAlgorithm is given if a token uses a signature algorithm that is not supported, e.g. "none".
+const UnsupportedAlgorithm = AlgorithmError("signature algorithm not supported")
+
+// AlgorithmMismatch is given if the signature algorithm does not fit to the type or the algorithm of the key.
+const AlgorithmMismatch = AlgorithmError("signature algorithm does not match key")
+
+type AlgorithmError string
+
+func (e AlgorithmError) Error() string { return string(e) }
+
+//------------------------------------------------------------------------------
+
 const (
 	// Kid key of the JWT header field that contains the key ID
 	Kid = "kid"
@@ -37,41 +73,90 @@ type Token interface {
 	IsValid(rawJWK string) error
 	// SetClaims adds claims if not already existing or overwrites existing ones with the provided values
 	SetClaims(claimsToAdapt Claims)
-	// Sign signs the token with the provided private RSA key and returns it as string. If something fails empty string is returned.
+	// Sign signs the token with the provided private key and returns it as string. The PEM encoded key must fit to alg,
+	// PKCS #1 and SEC 1 as well as PKCS #8 encodings are supported. If something fails empty string is returned.
 	// If kid is provided it will be set in header.
 	Sign(privateKeyPem string, alg SignatureAlgorithm, kid string) string
 }
 
 // token implements interface Token
 type token struct {
-	jwt *jwt.Token
+	raw    string
+	header map[string]interface{}
+	claims Claims
 }
 
 // Claims type that uses the map[string]interface{} for JSON decoding
 // This is the default claims type if you don't supply one
 type Claims map[string]interface{}
 
+// NewToken creates an unsigned Token with the provided claims. Use Token.Sign to get the encoded JWT.
+func NewToken(claims Claims) Token {
+	t := &token{header: map[string]interface{}{"typ": "JWT"}, claims: Claims{}}
+	t.SetClaims(claims)
+	return t
+}
+
 // NewUnverifiedToken creates a new unverified Token and will return error if the rawToken could not be parsed as JWT
 // base64Jwt - the base64 encoded Token jwt as provided in the header authorization field (without Bearer)
 func NewUnverifiedToken(base64Jwt string) (Token, error) {
-	claims := jwt.MapClaims{}
-	parser := jwt.Parser{}
-	jwt, _, err := parser.ParseUnverified(base64Jwt, claims)
+	// only the compact serialization is a valid JWT
+	if strings.Count(base64Jwt, ".") != 2 {
+		return nil, errors.New("token contains an invalid number of segments")
+	}
 
+	msg, err := jws.ParseString(base64Jwt)
 	if err != nil {
 		return nil, err
 	}
 
-	return &token{jwt: jwt}, nil
+	signatures := msg.Signatures()
+	if len(signatures) != 1 {
+		return nil, errors.New("token must contain exactly one signature")
+	}
+
+	// convert the header into plain JSON types, so it can be handled like the claims
+	rawHeader, err := json.Marshal(signatures[0].ProtectedHeaders())
+	if err != nil {
+		return nil, err
+	}
+	header := make(map[string]interface{})
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, err
+	}
+
+	claims := Claims{}
+	if err := json.Unmarshal(msg.Payload(), &claims); err != nil {
+		return nil, fmt.Errorf("token payload is no JSON object: %w", err)
+	}
+
+	return &token{raw: base64Jwt, header: header, claims: claims}, nil
 }
 
 func (j *token) IsValid(rawJWK string) error {
-	ks, err := jwk.ParseString(rawJWK)
+	key, err := jwk.ParseKey([]byte(rawJWK))
 	if err != nil {
 		return err
 	}
 
-	tok, err := les.ParseString(j.jwt.Raw, les.WithKeySet(ks))
+	// never trust the alg of the token alone -> it must be supported and fit to the key
+	alg := getStringFromMap("alg", j.header)
+	if alg == nil {
+		return UnsupportedAlgorithm
+	}
+	if keyAlg := key.Algorithm(); keyAlg != "" && keyAlg != *alg {
+		return fmt.Errorf("%w: token uses %s, key %s", AlgorithmMismatch, *alg, keyAlg)
+	}
+
+	var publicKey interface{}
+	if err := key.Raw(&publicKey); err != nil {
+		return err
+	}
+	if err := checkKeyForAlgorithm(SignatureAlgorithm(*alg), publicKey); err != nil {
+		return err
+	}
+
+	tok, err := les.ParseString(j.raw, les.WithVerify(jwa.SignatureAlgorithm(*alg), publicKey))
 	if err != nil {
 		return err
 	}
@@ -95,6 +180,9 @@ func getStringFromMap(key string, lookup map[string]interface{}) *string {
 	case int:
 		res, _ := resultIface.(int)
 		result = strconv.Itoa(res)
+	case int64:
+		res, _ := resultIface.(int64)
+		result = strconv.FormatInt(res, 10)
 	case float64:
 		res, _ := resultIface.(float64)
 		result = fmt.Sprintf("%.0f", res)
@@ -110,17 +198,17 @@ func getStringFromMap(key string, lookup map[string]interface{}) *string {
 
 // GetValueForHeaderKey see Token.GetValueForHeaderKey
 func (j *token) GetValueForHeaderKey(key string) *string {
-	return getStringFromMap(key, j.jwt.Header)
+	return getStringFromMap(key, j.header)
 }
 
 // GetValueForClaim see Token.GetValueForClaim
 func (j *token) GetValueForClaim(key string) *string {
-	return getStringFromMap(key, j.jwt.Claims.(jwt.MapClaims))
+	return getStringFromMap(key, j.claims)
 }
 
 // GetClaims see Token.GetClaims
 func (j *token) GetClaims() Claims {
-	return Claims(j.jwt.Claims.(jwt.MapClaims))
+	return j.claims
 }
 
 func (j *token) Sign(privateKeyPem string, alg SignatureAlgorithm, kid string) (signed string) {
@@ -129,31 +217,104 @@ func (j *token) Sign(privateKeyPem string, alg SignatureAlgorithm, kid string) (
 		return
 	}
 
-	signKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPem))
-
+	signKey, err := parsePrivateKeyPem(privateKeyPem)
 	if err != nil {
 		log.Err(err).Msgf("failed parsing PEM")
 		return
 	}
 
-	j.jwt.Method = jwt.GetSigningMethod(string(alg))
-	j.jwt.Header["alg"] = string(alg)
+	signer, ok := signKey.(crypto.Signer)
+	if !ok {
+		log.Error().Msg("private key can not be used for signing")
+		return
+	}
+	if err := checkKeyForAlgorithm(alg, signer.Public()); err != nil {
+		log.Err(err).Msg("private key can not be used for signing")
+		return
+	}
+
+	payload, err := json.Marshal(j.claims)
+	if err != nil {
+		log.Err(err).Msgf("encoding claims failed")
+		return
+	}
 
+	// keep the existing header, alg is replaced and kid only if provided
+	headers := jws.NewHeaders()
+	for key, value := range j.header {
+		if key == jws.AlgorithmKey {
+			continue
+		}
+		if err := headers.Set(key, value); err != nil {
+			log.Err(err).Str("header", key).Msgf("copying header failed")
+			return
+		}
+	}
 	if kid != "" {
-		j.jwt.Header["kid"] = kid
+		headers.Set(jws.KeyIDKey, kid)
 	}
 
-	tokenString, err := j.jwt.SignedString(signKey)
+	tokenBytes, err := jws.Sign(payload, jwa.SignatureAlgorithm(alg), signKey, jws.WithHeaders(headers))
 	if err != nil {
 		log.Err(err).Msgf("signing token failed")
 		return
 	}
 
-	return tokenString
+	j.raw = string(tokenBytes)
+	j.header[jws.AlgorithmKey] = string(alg)
+	if kid != "" {
+		j.header[jws.KeyIDKey] = kid
+	}
+
+	return j.raw
 }
 
 func (j *token) SetClaims(claimsToAdapt Claims) {
 	for claim, value := range claimsToAdapt {
-		j.jwt.Claims.(jwt.MapClaims)[claim] = value
+		j.claims[claim] = value
+	}
+}
+
+// checkKeyForAlgorithm checks that alg is supported and that the public key has the type (and curve) required by alg.
+func checkKeyForAlgorithm(alg SignatureAlgorithm, publicKey interface{}) error {
+	switch alg {
+	case RS256, PS256:
+		if _, ok := publicKey.(*rsa.PublicKey); !ok {
+			return fmt.Errorf("%w: %s requires a RSA key", AlgorithmMismatch, alg)
+		}
+	case ES256, ES384, ES512:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s requires an EC key", AlgorithmMismatch, alg)
+		}
+		if key.Curve.Params().Name != curves[alg] {
+			return fmt.Errorf("%w: %s requires curve %s", AlgorithmMismatch, alg, curves[alg])
+		}
+	case EdDSA:
+		if _, ok := publicKey.(ed25519.PublicKey); !ok {
+			return fmt.Errorf("%w: %s requires an Ed25519 key", AlgorithmMismatch, alg)
+		}
+	default:
+		return fmt.Errorf("%w: %s", UnsupportedAlgorithm, alg)
+	}
+	return nil
+}
+
+// parsePrivateKeyPem parses a PEM encoded RSA, EC or Ed25519 private key.
+func parsePrivateKeyPem(privateKeyPem string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(privateKeyPem))
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %s", block.Type)
 	}
 }
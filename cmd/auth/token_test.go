@@ -1,10 +1,16 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
-	jwtGo "github.com/dgrijalva/jwt-go"
+	"encoding/pem"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
@@ -132,23 +138,210 @@ func Test_token_GetClaims(t *testing.T) {
 }
 
 func Test_token_Sign(t *testing.T) {
-	jwt, err := NewUnverifiedToken(staticJwt)
-	require.NotNil(t, jwt)
+	tok, err := NewUnverifiedToken(staticJwt)
+	require.NotNil(t, tok)
 	require.Nil(t, err)
 
 	claims := make(map[string]interface{})
 	claims["iat"] = time.Now().Unix()
 	claims["exp"] = time.Now().Add(time.Hour).Unix()
-	jwt.SetClaims(claims)
+	tok.SetClaims(claims)
 
-	out := jwt.Sign(privateKey, RS256, "MBB")
-	require.NotNil(t, out)
+	out := tok.Sign(privateKey, RS256, "MBB")
+	require.NotEmpty(t, out)
 
-	finalToken, errParse := jwtGo.Parse(out, func(t *jwtGo.Token) (interface{}, error) {
-		ret, errParse := jwtGo.ParseRSAPublicKeyFromPEM([]byte(publicKey))
-		return ret, errParse
-	})
+	block, _ := pem.Decode([]byte(publicKey))
+	require.NotNil(t, block)
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.Nil(t, err)
 
+	finalToken, errParse := jwt.ParseString(out, jwt.WithVerify(jwa.RS256, pub), jwt.WithValidate(true))
 	require.Nil(t, errParse)
-	require.True(t, finalToken.Valid)
+	require.Equal(t, "someone", finalToken.Issuer())
+
+	// the signed token can be parsed again and contains the new header and all claims
+	signed, err := NewUnverifiedToken(out)
+	require.Nil(t, err)
+	require.Equal(t, "MBB", *signed.GetValueForHeaderKey(Kid))
+	require.Equal(t, "RS256", *signed.GetValueForHeaderKey("alg"))
+	require.Equal(t, "JWT", *signed.GetValueForHeaderKey("typ"))
+	require.Equal(t, len(tok.GetClaims()), len(signed.GetClaims()))
+	require.Equal(t, *tok.GetValueForClaim(Exp), *signed.GetValueForClaim(Exp))
+
+	// signing without kid keeps the existing one
+	out = signed.Sign(privateKey, RS256, "")
+	signed, err = NewUnverifiedToken(out)
+	require.Nil(t, err)
+	require.Equal(t, "MBB", *signed.GetValueForHeaderKey(Kid))
+
+	// invalid keys
+	require.Empty(t, tok.Sign("", RS256, "MBB"))
+	require.Empty(t, tok.Sign(publicKey, RS256, "MBB"))
+}
+
+func TestNewUnverifiedToken_Malformed(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	header := encode(`{"alg":"RS256","kid":"test"}`)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"too few segments", header + "." + encode(`{}`)},
+		{"too many segments", header + "." + encode(`{}`) + ".sig.sig"},
+		{"header no base64", "%%%." + encode(`{}`) + ".sig"},
+		{"header no json", encode("abc") + "." + encode(`{}`) + ".sig"},
+		{"payload no base64", header + ".%%%.sig"},
+		{"payload no json object", header + "." + encode(`[1,2]`) + ".sig"},
+		{"json serialization", `{"payload":"` + encode(`{}`) + `","signatures":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwt, err := NewUnverifiedToken(tt.token)
+			require.NotNil(t, err)
+			require.Nil(t, jwt)
+		})
+	}
+}
+
+func Test_token_IsValidClaims(t *testing.T) {
+	private, public := generateKeyPair(t, ES256)
+
+	t.Run("valid", func(t *testing.T) {
+		jwt, err := NewUnverifiedToken(newTestToken(t).Sign(private, ES256, "testKey"))
+		require.Nil(t, err)
+		require.Nil(t, jwt.IsValid(public))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		tok := newTestToken(t)
+		tok.SetClaims(Claims{"exp": time.Now().Add(-time.Hour).Unix()})
+		jwt, err := NewUnverifiedToken(tok.Sign(private, ES256, "testKey"))
+		require.Nil(t, err)
+		require.NotNil(t, jwt.IsValid(public))
+	})
+
+	t.Run("not yet valid", func(t *testing.T) {
+		tok := newTestToken(t)
+		tok.SetClaims(Claims{"nbf": time.Now().Add(time.Hour).Unix()})
+		jwt, err := NewUnverifiedToken(tok.Sign(private, ES256, "testKey"))
+		require.Nil(t, err)
+		require.NotNil(t, jwt.IsValid(public))
+	})
+
+	t.Run("invalid jwk", func(t *testing.T) {
+		jwt, err := NewUnverifiedToken(newTestToken(t).Sign(private, ES256, "testKey"))
+		require.Nil(t, err)
+		require.NotNil(t, jwt.IsValid("{}"))
+		require.NotNil(t, jwt.IsValid("no json"))
+	})
+}
+
+// generateKeyPair creates a PKCS #8 PEM encoded private key and the public key as JWK for alg.
+func generateKeyPair(t *testing.T, alg SignatureAlgorithm) (privatePem string, publicJwk string) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case RS256, PS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case ES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ES384:
+		private, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case ES512:
+		private, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	require.Nil(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.Nil(t, err)
+	privatePem = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	pubKey, err := jwk.New(private.Public())
+	require.Nil(t, err)
+	require.Nil(t, pubKey.Set(jwk.KeyIDKey, "testKey"))
+	raw, err := json.Marshal(pubKey)
+	require.Nil(t, err)
+	return privatePem, string(raw)
+}
+
+func newTestToken(t *testing.T) Token {
+	jwt, err := NewUnverifiedToken(staticJwt)
+	require.Nil(t, err)
+	jwt.SetClaims(Claims{
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	return jwt
+}
+
+func Test_token_SignAndValidateAlgorithms(t *testing.T) {
+	for _, alg := range []SignatureAlgorithm{RS256, PS256, ES256, ES384, ES512, EdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			private, public := generateKeyPair(t, alg)
+
+			signed := newTestToken(t).Sign(private, alg, "testKey")
+			require.NotEmpty(t, signed)
+
+			jwt, err := NewUnverifiedToken(signed)
+			require.Nil(t, err)
+			require.Equal(t, string(alg), *jwt.GetValueForHeaderKey("alg"))
+			require.Nil(t, jwt.IsValid(public))
+
+			// a different key of the same type must not validate the token
+			_, otherPublic := generateKeyPair(t, alg)
+			require.NotNil(t, jwt.IsValid(otherPublic))
+		})
+	}
+}
+
+func Test_token_RejectsAlgorithms(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		_, public := generateKeyPair(t, RS256)
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+		body := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"someone"}`))
+
+		jwt, err := NewUnverifiedToken(header + "." + body + ".")
+		require.Nil(t, err)
+		require.ErrorIs(t, jwt.IsValid(public), UnsupportedAlgorithm)
+	})
+
+	t.Run("kty does not match alg", func(t *testing.T) {
+		private, _ := generateKeyPair(t, ES256)
+		_, rsaPublic := generateKeyPair(t, RS256)
+
+		jwt, err := NewUnverifiedToken(newTestToken(t).Sign(private, ES256, "testKey"))
+		require.Nil(t, err)
+		require.ErrorIs(t, jwt.IsValid(rsaPublic), AlgorithmMismatch)
+	})
+
+	t.Run("curve does not match alg", func(t *testing.T) {
+		private, _ := generateKeyPair(t, ES256)
+		_, public := generateKeyPair(t, ES384)
+
+		jwt, err := NewUnverifiedToken(newTestToken(t).Sign(private, ES256, "testKey"))
+		require.Nil(t, err)
+		require.ErrorIs(t, jwt.IsValid(public), AlgorithmMismatch)
+	})
+
+	t.Run("alg of jwk does not match", func(t *testing.T) {
+		private, public := generateKeyPair(t, RS256)
+
+		jwt, err := NewUnverifiedToken(newTestToken(t).Sign(private, PS256, "testKey"))
+		require.Nil(t, err)
+
+		key, err := jwk.ParseKey([]byte(public))
+		require.Nil(t, err)
+		require.Nil(t, key.Set(jwk.AlgorithmKey, string(RS256)))
+		raw, err := json.Marshal(key)
+		require.Nil(t, err)
+		require.ErrorIs(t, jwt.IsValid(string(raw)), AlgorithmMismatch)
+	})
+
+	t.Run("signing key does not match alg", func(t *testing.T) {
+		private, _ := generateKeyPair(t, EdDSA)
+		require.Empty(t, newTestToken(t).Sign(private, RS256, "testKey"))
+	})
 }
@@ -0,0 +1,381 @@
+package config
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"net/url"
+	"poi-service/cmd/dedup"
+	"poi-service/cmd/idempotency"
+	"poi-service/cmd/logging"
+	"poi-service/cmd/ratelimit"
+	"poi-service/cmd/tlsconfig"
+	"poi-service/cmd/tracing"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sources of the events, see Events.Source.
+const (
+	// EventSourceHandler publishes the changes made by this instance in-process.
+	EventSourceHandler = "handler"
+	// EventSourceChangeStream watches the poi collection, so the changes of all instances are published.
+	EventSourceChangeStream = "changestream"
+	// EventSourceOutbox publishes the changes stored in the outbox in the same transaction.
+	EventSourceOutbox = "outbox"
+)
+
+// Modes of the authentication of bearer tokens, see Auth.Mode.
+const (
+	// AuthModeJwt accepts JWTs signed by the trusted issuer.
+	AuthModeJwt = "jwt"
+	// AuthModeIntrospection validates tokens by the OAuth2 introspection endpoint.
+	AuthModeIntrospection = "introspection"
+	// AuthModeJwtIntrospection validates JWTs with fallback to introspection, e.g. for opaque tokens.
+	AuthModeJwtIntrospection = "jwt+introspection"
+)
+
+// Invalid is given if the configuration can not be used.
+const Invalid = InvalidError("invalid configuration")
+
+type InvalidError string
+
+func (e InvalidError) Error() string { return string(e) }
+
+// Config contains all settings of the service. Each setting can be given in the config file by its path (e.g.
+// service.port), as environment variable (tag env) and as command line flag named like the path (e.g.
+// -service.port). Settings tagged with reload are taken over if the config file changes while running, all others
+// need a restart. Settings tagged with secret are redacted when the config is printed.
+type Config struct {
+	Service     Service     `yaml:"service" toml:"service"`
+	Log         Log         `yaml:"log" toml:"log"`
+	Tracing     Tracing     `yaml:"tracing" toml:"tracing"`
+	Database    Database    `yaml:"database" toml:"database"`
+	Events      Events      `yaml:"events" toml:"events"`
+	Auth        Auth        `yaml:"auth" toml:"auth"`
+	Tls         Tls         `yaml:"tls" toml:"tls"`
+	Audit       Audit       `yaml:"audit" toml:"audit"`
+	Trash       Trash       `yaml:"trash" toml:"trash"`
+	Http        Http        `yaml:"http" toml:"http"`
+	RateLimit   RateLimit   `yaml:"rateLimit" toml:"rateLimit"`
+	Idempotency Idempotency `yaml:"idempotency" toml:"idempotency"`
+	Dedup       Dedup       `yaml:"dedup" toml:"dedup"`
+}
+
+type Service struct {
+	Port string `yaml:"port" toml:"port" env:"SERVICE_PORT"`
+	// ShutdownTimeout limits the time to finish the open requests on shutdown.
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`
+	// ShutdownDelay is the time requests are still served after the service reported not ready, so the load balancer
+	// can stop sending requests first.
+	ShutdownDelay Duration `yaml:"shutdownDelay" toml:"shutdownDelay" env:"SHUTDOWN_DELAY" reload:"true"`
+}
+
+type Log struct {
+	// Level is one of trace, debug, info, warn or error.
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" reload:"true"`
+	// Format is logging.FormatJson or logging.FormatConsole.
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+}
+
+type Tracing struct {
+	// Exporter is one of the exporters of package tracing.
+	Exporter string `yaml:"exporter" toml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+}
+
+type Database struct {
+	// Url of the mongodb, the password is redacted.
+	Url  string `yaml:"url" toml:"url" env:"DATABASE_URL" secret:"url"`
+	Name string `yaml:"name" toml:"name" env:"DATABASE_NAME"`
+	// PoiCollection contains the pois, the other collections have fixed names.
+	PoiCollection string `yaml:"poiCollection" toml:"poiCollection" env:"DATABASE_POI_COLLECTION"`
+}
+
+type Events struct {
+	// Source is one of EventSourceHandler, EventSourceChangeStream or EventSourceOutbox.
+	Source string `yaml:"source" toml:"source" env:"EVENT_SOURCE"`
+}
+
+type Auth struct {
+	// Mode is one of AuthModeJwt, AuthModeIntrospection or AuthModeJwtIntrospection.
+	Mode string `yaml:"mode" toml:"mode" env:"AUTH_MODE"`
+	// Issuer is the OAuth server whose tokens are accepted.
+	Issuer string `yaml:"issuer" toml:"issuer" env:"AUTH_ISSUER"`
+	// DevTokenIssuer lets the service issue tokens to everyone. Never use in production!
+	DevTokenIssuer bool          `yaml:"devTokenIssuer" toml:"devTokenIssuer" env:"DEV_TOKEN_ISSUER"`
+	Introspection  Introspection `yaml:"introspection" toml:"introspection"`
+}
+
+// AcceptsJwt returns true if the mode validates JWTs, so the keys of the issuer are needed.
+func (a Auth) AcceptsJwt() bool {
+	return a.Mode == AuthModeJwt || a.Mode == AuthModeJwtIntrospection
+}
+
+type Introspection struct {
+	Url          string `yaml:"url" toml:"url" env:"INTROSPECTION_URL"`
+	ClientId     string `yaml:"clientId" toml:"clientId" env:"INTROSPECTION_CLIENT_ID"`
+	ClientSecret string `yaml:"clientSecret" toml:"clientSecret" env:"INTROSPECTION_CLIENT_SECRET" secret:"true"`
+}
+
+// Tls is served if CertFile is set.
+type Tls struct {
+	CertFile     string `yaml:"certFile" toml:"certFile" env:"TLS_CERT_FILE"`
+	KeyFile      string `yaml:"keyFile" toml:"keyFile" env:"TLS_KEY_FILE"`
+	ClientCaFile string `yaml:"clientCaFile" toml:"clientCaFile" env:"TLS_CLIENT_CA_FILE"`
+	// ClientAuth is one of the client auth modes of package tlsconfig.
+	ClientAuth string `yaml:"clientAuth" toml:"clientAuth" env:"TLS_CLIENT_AUTH"`
+}
+
+type Audit struct {
+	// SigningKey signs the audit records, if empty the records are only hash chained.
+	SigningKey string `yaml:"signingKey" toml:"signingKey" env:"AUDIT_SIGNING_KEY" secret:"true"`
+}
+
+type Trash struct {
+	// Retention is the time deleted pois can be restored.
+	Retention Duration `yaml:"retention" toml:"retention" env:"TRASH_RETENTION" reload:"true"`
+}
+
+type Http struct {
+	// ClientTimeout limits outgoing requests, e.g. the download of JWKs and the token introspection.
+	ClientTimeout Duration `yaml:"clientTimeout" toml:"clientTimeout" env:"HTTP_CLIENT_TIMEOUT"`
+	// WebhookTimeout limits the time a webhook receiver may take to answer.
+	WebhookTimeout Duration `yaml:"webhookTimeout" toml:"webhookTimeout" env:"WEBHOOK_TIMEOUT"`
+}
+
+type RateLimit struct {
+	// Enabled limits the requests of every IP address before the authorization and of every principal after it.
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// IpRate is the number of requests per second an IP address may send, including unauthenticated requests.
+	IpRate float64 `yaml:"ipRate" toml:"ipRate" env:"RATE_LIMIT_IP_RATE"`
+	// IpBurst is the number of requests an IP address may send at once.
+	IpBurst int `yaml:"ipBurst" toml:"ipBurst" env:"RATE_LIMIT_IP_BURST"`
+	// Rate is the number of requests per second a client may send to the routes without own limit.
+	Rate float64 `yaml:"rate" toml:"rate" env:"RATE_LIMIT_RATE"`
+	// Burst is the number of requests a client may send at once to the routes without own limit.
+	Burst int `yaml:"burst" toml:"burst" env:"RATE_LIMIT_BURST"`
+	// DailyQuota limits the requests of a client per day to the routes without own limit, 0 means unlimited.
+	DailyQuota int64 `yaml:"dailyQuota" toml:"dailyQuota" env:"RATE_LIMIT_DAILY_QUOTA"`
+	// Routes overrides the limits of single routes by method and path template, e.g. "POST /v1/pois/list". It can
+	// only be given in the config file.
+	Routes map[string]ratelimit.Limit `yaml:"routes" toml:"routes"`
+}
+
+// IpPolicy returns the limit of the IP addresses, it applies to all routes.
+func (r RateLimit) IpPolicy() ratelimit.Policy {
+	return ratelimit.Policy{Default: ratelimit.Limit{Rate: r.IpRate, Burst: r.IpBurst}}
+}
+
+// Policy returns the limits of all routes.
+func (r RateLimit) Policy() ratelimit.Policy {
+	return ratelimit.Policy{
+		Default: ratelimit.Limit{Rate: r.Rate, Burst: r.Burst, DailyQuota: r.DailyQuota},
+		Routes:  r.Routes,
+	}
+}
+
+type Idempotency struct {
+	// KeyTtl is the time the response of a request with Idempotency-Key is replayed for retries.
+	KeyTtl Duration `yaml:"keyTtl" toml:"keyTtl" env:"IDEMPOTENCY_KEY_TTL"`
+	// Lease is the time a key is reserved for a request in progress, afterwards a retry is processed again.
+	Lease Duration `yaml:"lease" toml:"lease" env:"IDEMPOTENCY_LEASE"`
+}
+
+type Dedup struct {
+	// MaxDistance is the distance in meter up to which pois are compared to find duplicates.
+	MaxDistance float64 `yaml:"maxDistance" toml:"maxDistance" env:"DEDUP_MAX_DISTANCE"`
+	// MinScore is the score between 0 and 1 a pair of pois needs to be listed as duplicate.
+	MinScore float64 `yaml:"minScore" toml:"minScore" env:"DEDUP_MIN_SCORE"`
+	// MaxPois is the number of pois in an area up to which duplicates are searched.
+	MaxPois int `yaml:"maxPois" toml:"maxPois" env:"DEDUP_MAX_POIS"`
+}
+
+// Default returns the settings used if they are not configured.
+func Default() Config {
+	return Config{
+		Service: Service{
+			Port:            "8000",
+			ShutdownTimeout: Duration(10 * time.Second),
+		},
+		Log: Log{
+			Level:  zerolog.InfoLevel.String(),
+			Format: logging.FormatJson,
+		},
+		Tracing:  Tracing{Exporter: tracing.ExporterNone},
+		Database: Database{Name: "poiDb", PoiCollection: "poi"},
+		Events:   Events{Source: EventSourceHandler},
+		Auth: Auth{
+			Mode:   AuthModeJwt,
+			Issuer: "http://127.0.0.1:4444/",
+		},
+		Tls:   Tls{ClientAuth: tlsconfig.ClientAuthNone},
+		Trash: Trash{Retention: Duration(30 * 24 * time.Hour)},
+		Http: Http{
+			ClientTimeout:  Duration(10 * time.Second),
+			WebhookTimeout: Duration(10 * time.Second),
+		},
+		RateLimit: RateLimit{
+			// the limits are off by default, e.g. if a gateway limits the requests already
+			Enabled: false,
+			// an IP address may be shared by several clients
+			IpRate:  50,
+			IpBurst: 100,
+			Rate:    10,
+			Burst:   20,
+			// the search may scan the whole collection, the duplicates compare all pois of an area
+			Routes: map[string]ratelimit.Limit{
+				"POST /v1/pois/list":       {Rate: 1, Burst: 5},
+				"POST /v1/duplicates/list": {Rate: 0.2, Burst: 2},
+			},
+		},
+		Idempotency: Idempotency{KeyTtl: Duration(idempotency.DefaultTtl), Lease: Duration(idempotency.DefaultLease)},
+		Dedup:       Dedup{MaxDistance: dedup.DefaultMaxDistance, MinScore: dedup.DefaultMinScore, MaxPois: dedup.DefaultMaxPois},
+	}
+}
+
+// Validate returns Invalid with all problems found.
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	oneOf := func(path, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		problems = append(problems, fmt.Sprintf("%s must be one of %s", path, strings.Join(allowed, ", ")))
+	}
+
+	port, err := strconv.Atoi(c.Service.Port)
+	check(err == nil && port > 0 && port <= 65535, "service.port must be a port number")
+	check(c.Service.ShutdownTimeout > 0, "service.shutdownTimeout must be positive")
+	check(c.Service.ShutdownDelay >= 0, "service.shutdownDelay must not be negative")
+
+	_, err = zerolog.ParseLevel(c.Log.Level)
+	check(err == nil && c.Log.Level != "", "log.level %q unknown", c.Log.Level)
+	oneOf("log.format", c.Log.Format, logging.FormatJson, logging.FormatConsole)
+	oneOf("tracing.exporter", c.Tracing.Exporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOtlp)
+
+	check(c.Database.Url != "", "database.url required")
+	check(c.Database.Name != "", "database.name required")
+	check(c.Database.PoiCollection != "", "database.poiCollection required")
+	oneOf("events.source", c.Events.Source, EventSourceHandler, EventSourceChangeStream, EventSourceOutbox)
+
+	oneOf("auth.mode", c.Auth.Mode, AuthModeJwt, AuthModeIntrospection, AuthModeJwtIntrospection)
+	check(isUrl(c.Auth.Issuer), "auth.issuer must be an absolute url")
+	if c.Auth.Mode != AuthModeJwt {
+		check(isUrl(c.Auth.Introspection.Url), "auth.introspection.url must be an absolute url for auth.mode %s", c.Auth.Mode)
+	}
+
+	check(c.Tls.CertFile == "" || c.Tls.KeyFile != "", "tls.keyFile required for tls.certFile")
+	oneOf("tls.clientAuth", c.Tls.ClientAuth, tlsconfig.ClientAuthNone, tlsconfig.ClientAuthRequest, tlsconfig.ClientAuthRequire)
+	if c.Tls.ClientAuth != tlsconfig.ClientAuthNone {
+		check(c.Tls.CertFile != "", "tls.certFile required for tls.clientAuth %s", c.Tls.ClientAuth)
+		check(c.Tls.ClientCaFile != "", "tls.clientCaFile required for tls.clientAuth %s", c.Tls.ClientAuth)
+	}
+
+	check(c.Trash.Retention >= 0, "trash.retention must not be negative")
+	check(c.Http.ClientTimeout > 0, "http.clientTimeout must be positive")
+	check(c.Http.WebhookTimeout > 0, "http.webhookTimeout must be positive")
+	routes := make([]string, 0, len(c.RateLimit.Routes))
+	for route := range c.RateLimit.Routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		limit := c.RateLimit.Routes[route]
+		check(limit.Rate > 0 && limit.Burst > 0 && limit.DailyQuota >= 0,
+			"rateLimit.routes[%s] needs positive rate and burst and a daily quota not negative", route)
+	}
+	check(c.RateLimit.IpRate > 0, "rateLimit.ipRate must be positive")
+	check(c.RateLimit.IpBurst > 0, "rateLimit.ipBurst must be positive")
+	check(c.RateLimit.Rate > 0, "rateLimit.rate must be positive")
+	check(c.RateLimit.Burst > 0, "rateLimit.burst must be positive")
+	check(c.RateLimit.DailyQuota >= 0, "rateLimit.dailyQuota must not be negative")
+	check(c.Idempotency.KeyTtl > 0, "idempotency.keyTtl must be positive")
+	check(c.Idempotency.Lease > 0, "idempotency.lease must be positive")
+	check(c.Dedup.MaxDistance > 0, "dedup.maxDistance must be positive")
+	check(c.Dedup.MinScore >= 0 && c.Dedup.MinScore <= 1, "dedup.minScore must be between 0 and 1")
+	check(c.Dedup.MaxPois > 0, "dedup.maxPois must be positive")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", Invalid, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func isUrl(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// Redacted returns a copy of the config that can be printed, the values of secrets are replaced.
+func (c Config) Redacted() Config {
+	v := reflect.ValueOf(&c).Elem()
+	walk(v, "", func(path string, field reflect.StructField, value reflect.Value) {
+		secret := field.Tag.Get("secret")
+		if secret == "" || value.String() == "" {
+			return
+		}
+		if u, err := url.Parse(value.String()); secret == "url" && err == nil {
+			value.SetString(u.Redacted())
+			return
+		}
+		value.SetString(redacted)
+	})
+	return c
+}
+
+const redacted = "xxxxx"
+
+// Settings returns the settings by their path, e.g. to log the effective config. Use Redacted first.
+func (c Config) Settings() map[string]interface{} {
+	settings := map[string]interface{}{}
+	walk(reflect.ValueOf(c), "", func(path string, _ reflect.StructField, value reflect.Value) {
+		if d, ok := value.Interface().(Duration); ok {
+			settings[path] = d.String()
+			return
+		}
+		settings[path] = value.Interface()
+	})
+	return settings
+}
+
+// walk calls visit for every setting of v, which is a struct. path is the path of the setting in the config file.
+func walk(v reflect.Value, prefix string, visit func(path string, field reflect.StructField, value reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		path := prefix + field.Tag.Get("yaml")
+		if field.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), path+".", visit)
+			continue
+		}
+		visit(path, field, v.Field(i))
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Duration is a time.Duration that is written as text like "1h30m" in the config file.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration { return time.Duration(d) }
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
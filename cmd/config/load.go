@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Source describes where the configuration is read from. The settings are taken from Default, the config file, the
+// environment and the flags, each one overriding the former.
+type Source struct {
+	// File is a YAML (.yaml, .yml) or TOML (.toml) file, optional.
+	File string
+	// LookupEnv returns the value of an environment variable, os.LookupEnv if nil.
+	LookupEnv func(key string) (string, bool)
+	// Flags contains the values of the flags given on the command line by path of the setting.
+	Flags map[string]string
+}
+
+// ParseFlags reads the command line args (without program name). Every setting can be given as flag named like its
+// path, e.g. -service.port=8080. The config file is given by -config or CONFIG_FILE.
+func ParseFlags(args []string) (Source, error) {
+	source := Source{Flags: map[string]string{}}
+
+	fs := flag.NewFlagSet("poi-service", flag.ContinueOnError)
+	fs.StringVar(&source.File, "config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file (env CONFIG_FILE)")
+	defaults := reflect.ValueOf(Default())
+	walk(defaults, "", func(path string, field reflect.StructField, value reflect.Value) {
+		if value.Kind() == reflect.Map {
+			return
+		}
+		usage := fmt.Sprintf("%s (env %s)", path, field.Tag.Get("env"))
+		fs.Var(&flagValue{path: path, flags: source.Flags, isBool: value.Kind() == reflect.Bool}, path, usage)
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return Source{}, err
+	}
+	return source, nil
+}
+
+// Load reads and validates the configuration.
+func (s Source) Load() (Config, error) {
+	c := Default()
+	if s.File != "" {
+		if err := decodeFile(s.File, &c); err != nil {
+			return Config{}, fmt.Errorf("%w: reading %s failed: %v", Invalid, s.File, err)
+		}
+	}
+
+	lookupEnv := s.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	var problems []string
+	walk(reflect.ValueOf(&c).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		if raw, ok := lookupEnv(field.Tag.Get("env")); ok && raw != "" {
+			if err := set(value, raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", field.Tag.Get("env"), err))
+			}
+		}
+		if raw, ok := s.Flags[path]; ok {
+			if err := set(value, raw); err != nil {
+				problems = append(problems, fmt.Sprintf("-%s: %v", path, err))
+			}
+		}
+	})
+	if len(problems) > 0 {
+		return Config{}, fmt.Errorf("%w: %s", Invalid, strings.Join(problems, "; "))
+	}
+
+	return c, c.Validate()
+}
+
+// decodeFile reads file into c, only the settings contained in the file are changed. Unknown settings are rejected,
+// so typos are not ignored silently.
+func decodeFile(file string, c *Config) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	case ".toml":
+		meta, err := toml.Decode(string(content), c)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown setting %s", undecoded[0])
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown file type %q", filepath.Ext(file))
+	}
+}
+
+// set parses raw into the setting.
+func set(value reflect.Value, raw string) error {
+	if u, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+// flagValue collects the value of a flag, it is applied to the config by Source.Load.
+type flagValue struct {
+	path   string
+	flags  map[string]string
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil || f.flags == nil {
+		return ""
+	}
+	return f.flags[f.path]
+}
+
+func (f *flagValue) Set(value string) error {
+	f.flags[f.path] = value
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool { return f.isBool }
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is the interval in which the config file is checked for changes.
+const DefaultReloadInterval = 30 * time.Second
+
+// Watcher provides the current configuration and reloads it if the config file changes. Only the settings tagged
+// with reload are taken over, changes of the other settings are logged and need a restart.
+type Watcher struct {
+	source   Source
+	interval time.Duration
+
+	mu        sync.RWMutex
+	current   Config
+	modified  time.Time
+	listeners []func(Config)
+}
+
+type WatcherOption func(w *Watcher)
+
+// WithReloadInterval sets the interval in which the config file is checked for changes.
+func WithReloadInterval(interval time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+// NewWatcher creates a Watcher for the config initial that was loaded from source.
+func NewWatcher(source Source, initial Config, opts ...WatcherOption) *Watcher {
+	w := &Watcher{
+		source:   source,
+		interval: DefaultReloadInterval,
+		current:  initial,
+		modified: modification(source.File),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Current returns the configuration with the latest reloaded settings.
+func (w *Watcher) Current() Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// OnReload registers fn to be called with the new configuration after settings were reloaded.
+func (w *Watcher) OnReload(fn func(Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Reload loads the configuration again and takes over the changed settings that can be reloaded. The current
+// configuration is kept if the new one is not valid. It returns the paths of the settings taken over.
+func (w *Watcher) Reload() (reloaded []string, err error) {
+	w.mu.Lock()
+	w.modified = modification(w.source.File)
+	w.mu.Unlock()
+
+	loaded, err := w.source.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]reflect.Value{}
+	walk(reflect.ValueOf(loaded), "", func(path string, _ reflect.StructField, value reflect.Value) {
+		values[path] = value
+	})
+
+	w.mu.Lock()
+	next := w.current
+	var restartRequired []string
+	walk(reflect.ValueOf(&next).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		if reflect.DeepEqual(value.Interface(), values[path].Interface()) {
+			return
+		}
+		if field.Tag.Get("reload") != "true" {
+			restartRequired = append(restartRequired, path)
+			return
+		}
+		value.Set(values[path])
+		reloaded = append(reloaded, path)
+	})
+	w.current = next
+	listeners := append([]func(Config){}, w.listeners...)
+	w.mu.Unlock()
+
+	if len(restartRequired) > 0 {
+		log.Warn().Strs("settings", restartRequired).Msg("changed settings need a restart, keeping the old values")
+	}
+	if len(reloaded) > 0 {
+		for _, listener := range listeners {
+			listener(next)
+		}
+		log.Info().Strs("settings", reloaded).Msg("settings reloaded")
+	}
+	return reloaded, nil
+}
+
+// Run checks the config file for changes until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	if w.source.File == "" {
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			if _, err := w.Reload(); err != nil {
+				log.Warn().Err(err).Str("file", w.source.File).Msg("reloading config failed")
+			}
+		}
+	}
+}
+
+// changed returns true if the config file was modified after the last load.
+func (w *Watcher) changed() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return modification(w.source.File).After(w.modified)
+}
+
+func modification(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_Reload(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+service:
+  port: "9000"
+log:
+  level: info
+database:
+  url: mongodb://localhost:27017
+`)
+	source := Source{File: file, LookupEnv: env(nil)}
+	initial, err := source.Load()
+	require.NoError(t, err)
+
+	w := NewWatcher(source, initial)
+	var notified []Config
+	w.OnReload(func(c Config) { notified = append(notified, c) })
+
+	require.NoError(t, os.WriteFile(file, []byte(`
+service:
+  port: "9001"
+log:
+  level: debug
+trash:
+  retention: 1h
+database:
+  url: mongodb://localhost:27017
+`), 0600))
+
+	reloaded, err := w.Reload()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"log.level", "trash.retention"}, reloaded)
+
+	current := w.Current()
+	assert.Equal(t, "debug", current.Log.Level)
+	assert.Equal(t, time.Hour, current.Trash.Retention.Duration())
+	assert.Equal(t, "9000", current.Service.Port, "the port needs a restart")
+	require.Len(t, notified, 1)
+	assert.Equal(t, current, notified[0])
+
+	t.Run("invalid config is ignored", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(file, []byte("log:\n  level: loud\n"), 0600))
+		_, err := w.Reload()
+		assert.ErrorIs(t, err, Invalid)
+		assert.Equal(t, current, w.Current())
+		assert.Len(t, notified, 1)
+	})
+}
+
+func TestWatcher_changed(t *testing.T) {
+	file := writeFile(t, "config.yaml", "database:\n  url: mongodb://localhost:27017\n")
+	w := NewWatcher(Source{File: file}, Default())
+	assert.False(t, w.changed())
+
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(file, later, later))
+	assert.True(t, w.changed())
+}
@@ -1,11 +1,20 @@
 package data
 
+import (
+	"math"
+	"time"
+)
+
 type Id string
 
 type Poi struct {
 	Name      string  `json:"name"`
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
+	// Source is the namespace of ExternalId, e.g. the partner the poi is imported from. Both are optional, but given
+	// together. They can not be changed once the poi is created.
+	Source     string `json:"source,omitempty"`
+	ExternalId string `json:"externalId,omitempty"`
 }
 
 type SearchArea struct {
@@ -14,4 +23,51 @@ type SearchArea struct {
 	RadiusInMeter uint64  `json:"radius"`
 }
 
+// earthRadiusInMeter is the mean radius of the earth used for distance calculations.
+const earthRadiusInMeter = 6371000
+
+// Contains returns true if the position is inside the area. An area without radius contains every position.
+func (a SearchArea) Contains(latitude, longitude float64) bool {
+	if a.RadiusInMeter == 0 {
+		return true
+	}
+	return Distance(a.Latitude, a.Longitude, latitude, longitude) <= float64(a.RadiusInMeter)
+}
+
+// Distance returns the great-circle distance in meter between two positions (haversine formula).
+func Distance(lat1, long1, lat2, long2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLong := toRad(long2 - long1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusInMeter * math.Asin(math.Sqrt(h))
+}
+
 type Pois []Poi
+
+// DeletedPoi is a poi in the trash, it can be restored until it is purged.
+type DeletedPoi struct {
+	Id Id `json:"id"`
+	Poi
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+type DeletedPois []DeletedPoi
+
+// Revision is a version of a poi, it was valid from ValidFrom until the next revision.
+type Revision struct {
+	Revision int `json:"revision"`
+	Poi
+	ValidFrom time.Time `json:"validFrom"`
+}
+
+type Revisions []Revision
+
+// Change describes a field that differs between two revisions.
+type Change struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type Changes []Change
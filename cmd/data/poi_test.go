@@ -0,0 +1,22 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	// Dresden - Berlin is about 165 km
+	d := Distance(51.050407, 13.737262, 52.520008, 13.404954)
+	assert.InDelta(t, 164800, d, 1000)
+	assert.Equal(t, float64(0), Distance(51, 13, 51, 13))
+}
+
+func TestSearchArea_Contains(t *testing.T) {
+	dresden := SearchArea{Latitude: 51.050407, Longitude: 13.737262, RadiusInMeter: 20000}
+
+	assert.True(t, dresden.Contains(51.1, 13.8))
+	assert.False(t, dresden.Contains(52.520008, 13.404954))
+	assert.True(t, SearchArea{}.Contains(52.520008, 13.404954), "no radius")
+}
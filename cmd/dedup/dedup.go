@@ -0,0 +1,252 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"sort"
+	"time"
+)
+
+// SamePoi is given if a poi is merged into itself.
+const SamePoi = MergeError("a poi can not be merged into itself")
+
+type MergeError string
+
+func (e MergeError) Error() string { return string(e) }
+
+// AreaRequired is given if the area to search has no radius, the duplicates are never searched in all pois.
+const AreaRequired = FindError("an area with radius is required")
+
+// AreaTooLarge is given if the area contains more pois than are compared at once.
+const AreaTooLarge = FindError("too many pois in the area, choose a smaller one")
+
+type FindError string
+
+func (e FindError) Error() string { return string(e) }
+
+// Defaults of the Deduplicator.
+const (
+	DefaultMaxDistance = 100
+	DefaultMinScore    = 0.7
+	// DefaultMaxPois is the number of pois in an area up to which duplicates are searched.
+	DefaultMaxPois = 5000
+	// DefaultLimit is the number of candidates returned if the page has no limit.
+	DefaultLimit = 50
+	// MaxLimit is the maximum number of candidates returned at once.
+	MaxLimit = 500
+	// nameWeight is the share of the name similarity in the score, the rest is taken by the distance.
+	nameWeight = 0.7
+	// metersPerDegree is a little less than the length of a degree of latitude, so pois closer than a distance are
+	// never further apart in latitude than distance / metersPerDegree.
+	metersPerDegree = 111000
+)
+
+// Page selects a part of the candidates ordered by score.
+type Page struct {
+	Offset int
+	// Limit is the number of candidates, DefaultLimit if 0 and at most MaxLimit.
+	Limit int
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return DefaultLimit
+	}
+	if p.Limit > MaxLimit {
+		return MaxLimit
+	}
+	return p.Limit
+}
+
+// Candidate is a pair of pois that probably describe the same place.
+type Candidate struct {
+	A CandidatePoi `json:"a"`
+	B CandidatePoi `json:"b"`
+	// Distance between both pois in meter.
+	Distance       float64 `json:"distance"`
+	NameSimilarity float64 `json:"nameSimilarity"`
+	// Score is between 0 and 1, the higher the more likely the pois are duplicates.
+	Score float64 `json:"score"`
+}
+
+type CandidatePoi struct {
+	Id string `json:"id"`
+	data.Poi
+}
+
+// Deduplicator finds pois that are stored twice and merges them.
+type Deduplicator struct {
+	db          handler.DbHandler
+	pois        handler.PoiHandler
+	redirects   RedirectStore
+	maxDistance float64
+	minScore    float64
+	maxPois     int
+}
+
+type DeduplicatorOption func(d *Deduplicator)
+
+// WithMaxDistance sets the distance in meter up to which pois are compared, DefaultMaxDistance if not set.
+func WithMaxDistance(meter float64) DeduplicatorOption {
+	return func(d *Deduplicator) {
+		d.maxDistance = meter
+	}
+}
+
+// WithMaxPois sets the number of pois in an area up to which duplicates are searched, DefaultMaxPois if not set.
+func WithMaxPois(max int) DeduplicatorOption {
+	return func(d *Deduplicator) {
+		d.maxPois = max
+	}
+}
+
+// WithMinScore sets the score a pair needs to be a candidate, DefaultMinScore if not set.
+func WithMinScore(score float64) DeduplicatorOption {
+	return func(d *Deduplicator) {
+		d.minScore = score
+	}
+}
+
+// NewDeduplicator creates a Deduplicator that searches the candidates in db by the geo index. Merges are made by pois,
+// so they are audited and published like every other change.
+func NewDeduplicator(db handler.DbHandler, pois handler.PoiHandler, redirects RedirectStore, opts ...DeduplicatorOption) *Deduplicator {
+	d := &Deduplicator{
+		db:          db,
+		pois:        pois,
+		redirects:   redirects,
+		maxDistance: DefaultMaxDistance,
+		minScore:    DefaultMinScore,
+		maxPois:     DefaultMaxPois,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Find returns a page of the candidates with at least one poi in area ordered by score, the best first. The pois of
+// the area are loaded once and compared in memory, AreaTooLarge is given if there are more than the maximum. At most
+// one poi more than the maximum is loaded to detect this.
+func (d *Deduplicator) Find(ctx context.Context, area data.SearchArea, page Page) ([]Candidate, error) {
+	if area.RadiusInMeter == 0 {
+		return nil, AreaRequired
+	}
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+
+	// pois at the border of the area have neighbours outside of it
+	radius := area.RadiusInMeter + uint64(math.Ceil(d.maxDistance))
+	pois, err := d.db.SearchByRadius(ctx, handler.NewLocation(area.Latitude, area.Longitude), radius, int64(d.maxPois)+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(pois) > d.maxPois {
+		return nil, AreaTooLarge
+	}
+
+	// ordered by latitude only the following pois up to the maximum distance in latitude have to be compared
+	sort.Slice(pois, func(i, j int) bool { return pois[i].Location.Latitude() < pois[j].Location.Latitude() })
+	maxLatitude := d.maxDistance / metersPerDegree
+
+	candidates := []Candidate{}
+	for i, a := range pois {
+		for _, b := range pois[i+1:] {
+			if b.Location.Latitude()-a.Location.Latitude() > maxLatitude {
+				break
+			}
+			if !contains(area, a) && !contains(area, b) {
+				continue
+			}
+			first, second := a, b
+			if first.Id > second.Id {
+				first, second = second, first
+			}
+			if candidate, ok := d.score(first, second); ok && candidate.Score >= d.minScore {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	// ordered by ids as well, so the pages are stable
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].A.Id != candidates[j].A.Id {
+			return candidates[i].A.Id < candidates[j].A.Id
+		}
+		return candidates[i].B.Id < candidates[j].B.Id
+	})
+	if page.Offset >= len(candidates) {
+		return []Candidate{}, nil
+	}
+	end := page.Offset + page.limit()
+	if end > len(candidates) {
+		end = len(candidates)
+	}
+	return candidates[page.Offset:end], nil
+}
+
+func contains(area data.SearchArea, poi handler.PoiDbEntry) bool {
+	return area.Contains(poi.Location.Latitude(), poi.Location.Longitude())
+}
+
+// score compares the names and the locations of a and b, ok is false if they are too far apart.
+func (d *Deduplicator) score(a, b handler.PoiDbEntry) (candidate Candidate, ok bool) {
+	distance := data.Distance(a.Location.Latitude(), a.Location.Longitude(), b.Location.Latitude(), b.Location.Longitude())
+	if distance > d.maxDistance {
+		return Candidate{}, false
+	}
+	nearness := math.Max(0, 1-distance/d.maxDistance)
+	similarity := NameSimilarity(a.Name, b.Name)
+
+	return Candidate{
+		A:              CandidatePoi{Id: a.Id, Poi: a.Poi()},
+		B:              CandidatePoi{Id: b.Id, Poi: b.Poi()},
+		Distance:       math.Round(distance*10) / 10,
+		NameSimilarity: round(similarity),
+		Score:          round(nameWeight*similarity + (1-nameWeight)*nearness),
+	}, true
+}
+
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
+
+// Merge keeps the poi keep and deletes merge. Requests for merge are redirected to keep from now on. The external id
+// of merge is moved to keep, so upserts of the source update keep. handler.ExternalIdConflict is given if both pois
+// have an external id. A merge can be repeated, e.g. if the redirect could not be stored, the poi changes are only
+// made once.
+func (d *Deduplicator) Merge(ctx context.Context, keep, merge data.Id) error {
+	if keep == merge {
+		return SamePoi
+	}
+	if err := d.pois.Merge(ctx, keep, merge); err != nil {
+		return err
+	}
+
+	err := d.redirects.Add(ctx, Redirect{
+		From:     string(merge),
+		To:       string(keep),
+		MergedAt: time.Now().UTC(),
+		MergedBy: auth.SubjectFromContext(ctx),
+	})
+	if err != nil {
+		return fmt.Errorf("storing redirect from %s to %s failed: %w", merge, keep, err)
+	}
+	return nil
+}
+
+// Resolve returns the id of the poi that id was merged into or NoRedirect.
+func (d *Deduplicator) Resolve(ctx context.Context, id data.Id) (data.Id, error) {
+	redirect, err := d.redirects.Get(ctx, string(id))
+	if err != nil {
+		return "", err
+	}
+	return data.Id(redirect.To), nil
+}
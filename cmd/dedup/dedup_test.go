@@ -0,0 +1,158 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		min, max float64
+	}{
+		{"Frauenkirche", "Frauenkirche", 1, 1},
+		{"Frauenkirche", "frauenkirche!", 1, 1},
+		{"Frauenkirche", "Frauenkriche", 0.8, 0.9},
+		{"Frauenkirche", "Dresdner Frauenkirche", 0.9, 0.9},
+		{"Semperoper", "Zwinger", 0, 0.3},
+		{"", "Zwinger", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			similarity := NameSimilarity(tt.a, tt.b)
+			assert.GreaterOrEqual(t, similarity, tt.min)
+			assert.LessOrEqual(t, similarity, tt.max)
+			assert.Equal(t, similarity, NameSimilarity(tt.b, tt.a), "symmetric")
+		})
+	}
+}
+
+func entry(id, name string, lat, long float64) handler.PoiDbEntry {
+	return handler.PoiDbEntry{Id: id, Name: name, Location: handler.NewLocation(lat, long)}
+}
+
+func TestDeduplicator_Find(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	church := entry("a", "Frauenkirche", 51.05190, 13.74150)
+	duplicate := entry("b", "Dresdner Frauenkirche", 51.05195, 13.74160)
+	other := entry("c", "Neumarkt", 51.05180, 13.74130)
+	// both outside of the area
+	outside := entry("d", "Zwinger", 51.05300, 13.73390)
+	outsideDuplicate := entry("e", "Zwinger", 51.05301, 13.73391)
+	area := data.SearchArea{Latitude: 51.05190, Longitude: 13.74150, RadiusInMeter: 100}
+	db := handler.NewMockDbHandler(ctrl)
+	db.EXPECT().SearchByRadius(gomock.Any(), handler.NewLocation(area.Latitude, area.Longitude), uint64(150), int64(DefaultMaxPois+1)).
+		Return(handler.PoiDbEntries{outside, other, duplicate, church, outsideDuplicate}, nil).Times(2)
+
+	deduplicator := NewDeduplicator(db, handler.NewMockPoiHandler(ctrl), NewMemoryRedirectStore(), WithMaxDistance(50))
+	_, err := deduplicator.Find(ctx, data.SearchArea{}, Page{})
+	assert.ErrorIs(t, err, AreaRequired)
+
+	candidates, err := deduplicator.Find(ctx, area, Page{})
+	require.NoError(t, err)
+	require.Len(t, candidates, 1, "every pair once, different names and pairs outside of the area are no candidates")
+	candidate := candidates[0]
+	assert.Equal(t, "a", candidate.A.Id)
+	assert.Equal(t, "b", candidate.B.Id)
+	assert.InDelta(t, 8.5, candidate.Distance, 1)
+	assert.Equal(t, 0.9, candidate.NameSimilarity)
+	assert.Greater(t, candidate.Score, DefaultMinScore)
+
+	candidates, err = deduplicator.Find(ctx, area, Page{Offset: 1})
+	require.NoError(t, err)
+	assert.Empty(t, candidates)
+
+	// one poi more than the maximum is loaded to detect that the area is too large
+	db.EXPECT().SearchByRadius(gomock.Any(), handler.NewLocation(area.Latitude, area.Longitude), uint64(150), int64(5)).
+		Return(handler.PoiDbEntries{outside, other, duplicate, church, outsideDuplicate}, nil)
+	_, err = NewDeduplicator(db, handler.NewMockPoiHandler(ctrl), NewMemoryRedirectStore(), WithMaxDistance(50), WithMaxPois(4)).
+		Find(ctx, area, Page{})
+	assert.ErrorIs(t, err, AreaTooLarge)
+}
+
+func TestDeduplicator_Find_paging(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// every pair of the three pois is a candidate
+	pois := handler.PoiDbEntries{
+		entry("a", "Frauenkirche", 51.05190, 13.74150),
+		entry("b", "Frauenkirche", 51.05191, 13.74150),
+		entry("c", "Frauenkirche", 51.05193, 13.74150),
+	}
+	area := data.SearchArea{Latitude: 51.05190, Longitude: 13.74150, RadiusInMeter: 100}
+	db := handler.NewMockDbHandler(ctrl)
+	db.EXPECT().SearchByRadius(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(pois, nil).AnyTimes()
+	deduplicator := NewDeduplicator(db, handler.NewMockPoiHandler(ctrl), NewMemoryRedirectStore())
+
+	all, err := deduplicator.Find(context.Background(), area, Page{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+
+	page, err := deduplicator.Find(context.Background(), area, Page{Offset: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, all[1:2], page)
+}
+
+// failingRedirectStore fails to add redirects while fail is set.
+type failingRedirectStore struct {
+	RedirectStore
+	fail bool
+}
+
+func (f *failingRedirectStore) Add(ctx context.Context, redirect Redirect) error {
+	if f.fail {
+		return errors.New("failed")
+	}
+	return f.RedirectStore.Add(ctx, redirect)
+}
+
+func TestDeduplicator_Merge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	pois := handler.NewMockPoiHandler(ctrl)
+	redirects := &failingRedirectStore{RedirectStore: NewMemoryRedirectStore()}
+	deduplicator := NewDeduplicator(handler.NewMockDbHandler(ctrl), pois, redirects)
+
+	assert.ErrorIs(t, deduplicator.Merge(ctx, "a", "a"), SamePoi)
+
+	pois.EXPECT().Merge(ctx, data.Id("unknown"), data.Id("b")).Return(handler.PoiNotFound)
+	assert.ErrorIs(t, deduplicator.Merge(ctx, "unknown", "b"), handler.PoiNotFound)
+
+	pois.EXPECT().Merge(ctx, data.Id("a"), data.Id("d")).Return(handler.ExternalIdConflict)
+	assert.ErrorIs(t, deduplicator.Merge(ctx, "a", "d"), handler.ExternalIdConflict)
+
+	// a merge whose redirect could not be stored is repeated
+	pois.EXPECT().Merge(ctx, data.Id("a"), data.Id("b")).Return(nil).Times(2)
+	redirects.fail = true
+	assert.Error(t, deduplicator.Merge(ctx, "a", "b"))
+	_, err := deduplicator.Resolve(ctx, "b")
+	assert.ErrorIs(t, err, NoRedirect)
+	redirects.fail = false
+	require.NoError(t, deduplicator.Merge(ctx, "a", "b"))
+	target, err := deduplicator.Resolve(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, data.Id("a"), target)
+
+	// redirects to merged pois follow the merge
+	pois.EXPECT().Merge(ctx, data.Id("c"), data.Id("a")).Return(nil)
+	require.NoError(t, deduplicator.Merge(ctx, "c", "a"))
+	target, err = deduplicator.Resolve(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, data.Id("c"), target)
+
+	_, err = deduplicator.Resolve(ctx, "c")
+	assert.ErrorIs(t, err, NoRedirect)
+}
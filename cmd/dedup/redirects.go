@@ -0,0 +1,101 @@
+package dedup
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+// NoRedirect is given if the poi was not merged into another one.
+const NoRedirect = RedirectError("no redirect")
+
+type RedirectError string
+
+func (e RedirectError) Error() string { return string(e) }
+
+// Redirect points from a merged poi to the poi it was merged into.
+type Redirect struct {
+	From     string    `json:"from" bson:"_id"`
+	To       string    `json:"to" bson:"to"`
+	MergedAt time.Time `json:"mergedAt" bson:"mergedAt"`
+	// MergedBy is the subject of the principal that merged the pois.
+	MergedBy string `json:"mergedBy" bson:"mergedBy"`
+}
+
+// RedirectStore persists the redirects of merged pois.
+type RedirectStore interface {
+	// Add stores the redirect. Redirects to its From are changed to its To, so there are no chains.
+	Add(ctx context.Context, redirect Redirect) error
+	// Get returns the redirect of the poi or NoRedirect.
+	Get(ctx context.Context, from string) (Redirect, error)
+}
+
+//------------------------------------------------------------------------------
+
+// NewMemoryRedirectStore creates a RedirectStore that keeps the redirects in memory only, e.g. for tests.
+func NewMemoryRedirectStore() RedirectStore {
+	return &memoryRedirectStore{redirects: make(map[string]Redirect)}
+}
+
+// memoryRedirectStore implements interface RedirectStore
+type memoryRedirectStore struct {
+	mu        sync.RWMutex
+	redirects map[string]Redirect
+}
+
+func (m *memoryRedirectStore) Add(_ context.Context, redirect Redirect) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for from, existing := range m.redirects {
+		if existing.To == redirect.From {
+			existing.To = redirect.To
+			m.redirects[from] = existing
+		}
+	}
+	m.redirects[redirect.From] = redirect
+	return nil
+}
+
+func (m *memoryRedirectStore) Get(_ context.Context, from string) (Redirect, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	redirect, ok := m.redirects[from]
+	if !ok {
+		return Redirect{}, NoRedirect
+	}
+	return redirect, nil
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoRedirectStore creates a RedirectStore that persists the redirects in collection.
+func NewMongoRedirectStore(ctx context.Context, collection *mongo.Collection) RedirectStore {
+	// chains are resolved by the target of the redirects
+	collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"to": 1}})
+	return &mongoRedirectStore{collection: collection}
+}
+
+// mongoRedirectStore implements interface RedirectStore
+type mongoRedirectStore struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoRedirectStore) Add(ctx context.Context, redirect Redirect) error {
+	_, err := m.collection.UpdateMany(ctx, bson.M{"to": redirect.From}, bson.M{"$set": bson.M{"to": redirect.To}})
+	if err != nil {
+		return err
+	}
+	_, err = m.collection.ReplaceOne(ctx, bson.M{"_id": redirect.From}, redirect, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *mongoRedirectStore) Get(ctx context.Context, from string) (redirect Redirect, err error) {
+	err = m.collection.FindOne(ctx, bson.M{"_id": from}).Decode(&redirect)
+	if err == mongo.ErrNoDocuments {
+		err = NoRedirect
+	}
+	return
+}
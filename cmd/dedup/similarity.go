@@ -0,0 +1,88 @@
+package dedup
+
+import (
+	"strings"
+	"unicode"
+)
+
+// containedWeight is the similarity of names where all words of the shorter name are part of the longer one, e.g.
+// "Frauenkirche" and "Dresdner Frauenkirche". It is below 1, so equal names are scored higher.
+const containedWeight = 0.9
+
+// NameSimilarity compares two names fuzzily, it returns 1 for equal names and 0 for names without anything in common.
+// Case, punctuation and whitespace are ignored. Typos are scored by the edit distance, names that differ by additional
+// words by the words they share.
+func NameSimilarity(a, b string) float64 {
+	wordsA, wordsB := words(a), words(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	joinedA, joinedB := []rune(strings.Join(wordsA, " ")), []rune(strings.Join(wordsB, " "))
+	longest := len(joinedA)
+	if len(joinedB) > longest {
+		longest = len(joinedB)
+	}
+	similarity := 1 - float64(levenshtein(joinedA, joinedB))/float64(longest)
+
+	if overlap := containedWeight * wordOverlap(wordsA, wordsB); overlap > similarity {
+		return overlap
+	}
+	return similarity
+}
+
+// words returns the lower case words of name without punctuation.
+func words(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// wordOverlap returns the share of the words of the shorter list that are contained in the other one.
+func wordOverlap(a, b []string) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	contained := make(map[string]bool, len(b))
+	for _, word := range b {
+		contained[word] = true
+	}
+	shared := 0
+	for _, word := range a {
+		if contained[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a))
+}
+
+// levenshtein returns the number of insertions, deletions and substitutions needed to change a into b.
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
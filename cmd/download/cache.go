@@ -0,0 +1,54 @@
+package download
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiresAt evaluates the caching directives of a http response header and returns the point in time the response
+// becomes stale. Cache-Control takes precedence over Expires as defined in RFC 7234. If the header contains no usable
+// directive ok is false and the caller should fall back to its own default.
+func ExpiresAt(header http.Header, now time.Time) (expires time.Time, ok bool) {
+	if header == nil {
+		return
+	}
+
+	// the age the response already had when it was received
+	age := time.Duration(0)
+	if v, err := strconv.ParseInt(strings.TrimSpace(header.Get("Age")), 10, 64); err == nil && v > 0 {
+		age = time.Duration(v) * time.Second
+	}
+
+	if cc := header.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			switch {
+			case directive == "no-store" || directive == "no-cache":
+				return now, true
+			case strings.HasPrefix(directive, "max-age="):
+				seconds, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(directive, "max-age="), "\""), 10, 64)
+				if err != nil || seconds < 0 {
+					continue
+				}
+				return now.Add(time.Duration(seconds)*time.Second - age), true
+			}
+		}
+	}
+
+	if exp := header.Get("Expires"); exp != "" {
+		t, err := http.ParseTime(exp)
+		if err != nil {
+			// invalid dates must be treated as already expired (RFC 7234 section 5.3)
+			return now, true
+		}
+		// compensate a clock skew between server and client by using the server date if available
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			return now.Add(t.Sub(date) - age), true
+		}
+		return t, true
+	}
+
+	return
+}
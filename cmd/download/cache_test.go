@@ -0,0 +1,43 @@
+package download
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiresAt(t *testing.T) {
+	now := time.Date(2021, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Time
+		wantOk bool
+	}{
+		{"no header", nil, time.Time{}, false},
+		{"no directives", http.Header{"Content-Type": {"application/json"}}, time.Time{}, false},
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=300"}}, now.Add(5 * time.Minute), true},
+		{"max-age minus age", http.Header{"Cache-Control": {"max-age=300"}, "Age": {"100"}}, now.Add(200 * time.Second), true},
+		{"no-store", http.Header{"Cache-Control": {"no-store"}}, now, true},
+		{"cache-control wins over expires", http.Header{
+			"Cache-Control": {"max-age=60"},
+			"Expires":       {now.Add(time.Hour).Format(http.TimeFormat)},
+		}, now.Add(time.Minute), true},
+		{"expires", http.Header{"Expires": {now.Add(time.Hour).Format(http.TimeFormat)}}, now.Add(time.Hour), true},
+		{"expires relative to date", http.Header{
+			"Date":    {now.Add(-time.Hour).Format(http.TimeFormat)},
+			"Expires": {now.Format(http.TimeFormat)},
+		}, now.Add(time.Hour), true},
+		{"invalid expires", http.Header{"Expires": {"0"}}, now, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ExpiresAt(tt.header, now)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.True(t, tt.want.Equal(got), "want %v got %v", tt.want, got)
+		})
+	}
+}
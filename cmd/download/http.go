@@ -1,19 +1,35 @@
 package download
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"poi-service/cmd/requestid"
+	"poi-service/cmd/tracing"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// HttpRequester is an abstraction for a default HTTP GET requests.
+// HttpRequester is an abstraction for a default HTTP GET requests. The requests are bound to ctx, so they are
+// cancelled with it and are part of its trace.
 type HttpRequester interface {
 	// GetContent fetches the content of the remote url.
-	GetContent(url string) (string, error)
+	GetContent(ctx context.Context, url string) (string, error)
+	// GetContentWithHeader fetches the content of the remote url and additionally returns the response header, e.g.
+	// to evaluate caching directives.
+	GetContentWithHeader(ctx context.Context, url string) (string, http.Header, error)
+	// PostContent sends body with the given content type and additional header to the remote url and returns the
+	// content of the response.
+	PostContent(ctx context.Context, url string, contentType string, body []byte, header http.Header) (string, error)
 }
 
+var tracer = otel.Tracer("poi-service/cmd/download")
+
 // NewHttpRequester creates a new HttpRequester with the give client.
 func NewHttpRequester(client *http.Client) HttpRequester {
 	return &httpRequester{client: client}
@@ -24,27 +40,91 @@ type httpRequester struct {
 	client *http.Client
 }
 
-func (hr *httpRequester) GetContent(url string) (content string, err error) {
+func (hr *httpRequester) GetContent(ctx context.Context, url string) (content string, err error) {
+	content, _, err = hr.GetContentWithHeader(ctx, url)
+	return
+}
+
+func (hr *httpRequester) GetContentWithHeader(ctx context.Context, url string) (content string, header http.Header, err error) {
+	ctx, span := tracer.Start(ctx, "HttpRequester.GetContent", trace.WithAttributes(semconv.HTTPURLKey.String(url)))
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		log.Ctx(ctx).Err(err).Str("url", url).Msg("Creating request failed")
+		return
+	}
+	forwardRequestId(ctx, req)
+
 	var rsp *http.Response
-	rsp, err = hr.client.Get(url)
+	rsp, err = hr.client.Do(req)
 	if err != nil {
-		log.Err(err).Str("url", url).Msg("Download failed")
+		log.Ctx(ctx).Err(err).Str("url", url).Msg("Download failed")
 		return
 	}
+	defer rsp.Body.Close()
 
+	content, err = readContent(rsp)
+	if err != nil {
+		log.Ctx(ctx).Err(err).Str("url", url).Msg("Download failed")
+		return
+	}
+	header = rsp.Header
+	return
+}
+
+func (hr *httpRequester) PostContent(ctx context.Context, url string, contentType string, body []byte, header http.Header) (content string, err error) {
+	ctx, span := tracer.Start(ctx, "HttpRequester.PostContent", trace.WithAttributes(semconv.HTTPURLKey.String(url)))
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Ctx(ctx).Err(err).Str("url", url).Msg("Creating request failed")
+		return
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Content-Type", contentType)
+	forwardRequestId(ctx, req)
+
+	rsp, err := hr.client.Do(req)
+	if err != nil {
+		log.Ctx(ctx).Err(err).Str("url", url).Msg("Post failed")
+		return
+	}
+	defer rsp.Body.Close()
+
+	content, err = readContent(rsp)
+	if err != nil {
+		log.Ctx(ctx).Err(err).Str("url", url).Msg("Post failed")
+	}
+	return
+}
+
+// readContent returns the body of a successful response.
+func readContent(rsp *http.Response) (content string, err error) {
 	statuscode := rsp.StatusCode
 
-	if statuscode != http.StatusOK {
+	if statuscode < http.StatusOK || statuscode >= http.StatusMultipleChoices {
 		err = fmt.Errorf("server response status: %d", rsp.StatusCode)
-		log.Err(err).Str("url", url).Msg("Download failed")
 		return
 	}
 	var rawBody []byte
 	rawBody, err = io.ReadAll(rsp.Body)
 	if err != nil {
-		log.Err(err).Str("url", url).Msg("Download failed to read body")
+		err = fmt.Errorf("failed to read body: %w", err)
 		return
 	}
 	content = string(rawBody)
 	return
 }
+
+// forwardRequestId passes the id of the incoming request on, so the logs of the called service can be correlated.
+func forwardRequestId(ctx context.Context, req *http.Request) {
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+}
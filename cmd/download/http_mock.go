@@ -5,6 +5,8 @@
 package download
 
 import (
+	context "context"
+	http "net/http"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,16 +36,47 @@ func (m *MockHttpRequester) EXPECT() *MockHttpRequesterMockRecorder {
 }
 
 // GetContent mocks base method.
-func (m *MockHttpRequester) GetContent(url string) (string, error) {
+func (m *MockHttpRequester) GetContent(ctx context.Context, url string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetContent", url)
+	ret := m.ctrl.Call(m, "GetContent", ctx, url)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetContent indicates an expected call of GetContent.
-func (mr *MockHttpRequesterMockRecorder) GetContent(url interface{}) *gomock.Call {
+func (mr *MockHttpRequesterMockRecorder) GetContent(ctx, url interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContent", reflect.TypeOf((*MockHttpRequester)(nil).GetContent), url)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContent", reflect.TypeOf((*MockHttpRequester)(nil).GetContent), ctx, url)
+}
+
+// GetContentWithHeader mocks base method.
+func (m *MockHttpRequester) GetContentWithHeader(ctx context.Context, url string) (string, http.Header, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContentWithHeader", ctx, url)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(http.Header)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetContentWithHeader indicates an expected call of GetContentWithHeader.
+func (mr *MockHttpRequesterMockRecorder) GetContentWithHeader(ctx, url interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContentWithHeader", reflect.TypeOf((*MockHttpRequester)(nil).GetContentWithHeader), ctx, url)
+}
+
+// PostContent mocks base method.
+func (m *MockHttpRequester) PostContent(ctx context.Context, url, contentType string, body []byte, header http.Header) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostContent", ctx, url, contentType, body, header)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PostContent indicates an expected call of PostContent.
+func (mr *MockHttpRequesterMockRecorder) PostContent(ctx, url, contentType, body, header interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostContent", reflect.TypeOf((*MockHttpRequester)(nil).PostContent), ctx, url, contentType, body, header)
 }
@@ -1,7 +1,9 @@
 package download
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -27,7 +29,7 @@ func TestRequester_OkCaseForGet(t *testing.T) {
 	requester := NewHttpRequester(http.DefaultClient)
 
 	// when
-	receivedData, err := requester.GetContent(testKeyServer.URL)
+	receivedData, err := requester.GetContent(context.Background(), testKeyServer.URL)
 
 	// then
 	require.NoError(t, err)
@@ -37,7 +39,7 @@ func TestRequester_OkCaseForGet(t *testing.T) {
 func TestRequester_GetRequestWithUnsupportedProtocolScheme(t *testing.T) {
 	// when
 	requester := NewHttpRequester(http.DefaultClient)
-	receivedData, err := requester.GetContent("")
+	receivedData, err := requester.GetContent(context.Background(), "")
 
 	// then
 	assert.Equal(t, receivedData, "")
@@ -53,10 +55,53 @@ func TestRequester_GetWithErrorStatusCode(t *testing.T) {
 	requester := NewHttpRequester(http.DefaultClient)
 
 	// when
-	receivedData, err := requester.GetContent(testKeyServer.URL)
+	receivedData, err := requester.GetContent(context.Background(), testKeyServer.URL)
 
 	// then
 	assert.Equal(t, receivedData, "")
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), fmt.Sprintf("status: %d", responseStatus))
 }
+
+func TestRequester_PostContent(t *testing.T) {
+	// given
+	testKeyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "text/plain" || r.Header.Get("X-Test") != "yes" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(body)
+	}))
+	requester := NewHttpRequester(http.DefaultClient)
+
+	// when
+	receivedData, err := requester.PostContent(context.Background(), testKeyServer.URL, "text/plain", []byte("abc"), http.Header{"X-Test": {"yes"}})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "abc", receivedData)
+
+	// when
+	receivedData, err = requester.PostContent(context.Background(), testKeyServer.URL, "application/json", []byte("abc"), nil)
+
+	// then
+	assert.Equal(t, "", receivedData)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("status: %d", http.StatusBadRequest))
+}
+
+func TestRequester_PostContentNoContent(t *testing.T) {
+	// given
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	requester := NewHttpRequester(http.DefaultClient)
+
+	// when
+	receivedData, err := requester.PostContent(context.Background(), testServer.URL, "application/json", []byte("{}"), nil)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "", receivedData)
+}
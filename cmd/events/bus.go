@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"sync"
+)
+
+// Bus distributes events in-process to all subscribers.
+type Bus struct {
+	mu          sync.RWMutex
+	next        int
+	subscribers map[int]chan Event
+}
+
+// NewBus creates an empty Bus.
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[int]chan Event)}
+}
+
+// Publish passes the event to all subscribers. It never blocks, events for subscribers that do not keep up are
+// dropped and logged with the logger of ctx.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for id, ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			log.Ctx(ctx).Warn().Int("subscriber", id).Str("event", e.Id).Msg("subscriber too slow, event dropped")
+		}
+	}
+}
+
+// Subscribe returns a channel that receives all published events. Up to buffer events are queued. The returned
+// function ends the subscription and closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	ch := make(chan Event, buffer)
+	b.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, id)
+			close(ch)
+		})
+	}
+}
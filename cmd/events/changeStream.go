@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poi-service/cmd/handler"
+	"time"
+)
+
+// Backoff of the change stream if the connection to the database is lost.
+const (
+	minWatchBackoff = time.Second
+	maxWatchBackoff = time.Minute
+)
+
+// changeEvent contains the used fields of a Mongo change event.
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		Id string `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument      *handler.PoiDbEntry `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// WatchChangeStream publishes the changes of the poi collection to bus until ctx is done. Change streams are only
+// available if Mongo runs as replica set. Unlike the in-process source, changes of all instances are published.
+func WatchChangeStream(ctx context.Context, collection *mongo.Collection, bus *Bus) {
+	var resumeToken bson.Raw
+	backoff := minWatchBackoff
+	for {
+		opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+		if resumeToken != nil {
+			// continue after the last published change, so no change is lost during reconnects
+			opts.SetResumeAfter(resumeToken)
+		}
+
+		stream, err := collection.Watch(ctx, mongo.Pipeline{}, opts)
+		if err == nil {
+			backoff = minWatchBackoff
+			for stream.Next(ctx) {
+				var change changeEvent
+				if err := stream.Decode(&change); err != nil {
+					log.Ctx(ctx).Warn().Err(err).Msg("decoding change event failed")
+				} else if event, ok := eventFromChange(change); ok {
+					bus.Publish(ctx, event)
+				}
+				resumeToken = stream.ResumeToken()
+			}
+			err = stream.Err()
+			stream.Close(context.Background())
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		log.Ctx(ctx).Warn().Err(err).Dur("backoff", backoff).Msg("change stream interrupted")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxWatchBackoff {
+			backoff = maxWatchBackoff
+		}
+	}
+}
+
+// eventFromChange maps a change of the collection to an event. Pois are only marked as deleted, so the final
+// removal by the purge is not published.
+func eventFromChange(change changeEvent) (Event, bool) {
+	var eventType Type
+	switch change.OperationType {
+	case "insert":
+		eventType = Created
+	case "update", "replace":
+		eventType = Updated
+		if _, ok := change.UpdateDescription.UpdatedFields["deletedAt"]; ok {
+			eventType = Deleted
+		}
+		for _, field := range change.UpdateDescription.RemovedFields {
+			if field == "deletedAt" {
+				eventType = Restored
+			}
+		}
+	default:
+		return Event{}, false
+	}
+
+	event := NewEvent(eventType, change.DocumentKey.Id, nil)
+	// the document may be gone already if it was changed again in the meantime
+	if change.FullDocument != nil && len(change.FullDocument.Location.Coordinates) == 2 {
+		poi := change.FullDocument.Poi()
+		event.Poi = &poi
+	}
+	return event, true
+}
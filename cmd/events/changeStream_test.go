@@ -0,0 +1,57 @@
+package events
+
+import (
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func Test_eventFromChange(t *testing.T) {
+	entry := &handler.PoiDbEntry{Id: "abc", Name: "Dresden", Location: handler.NewLocation(51.05, 13.73)}
+
+	change := func(operation string, updated bson.M, removed ...string) changeEvent {
+		c := changeEvent{OperationType: operation, FullDocument: entry}
+		c.DocumentKey.Id = "abc"
+		c.UpdateDescription.UpdatedFields = updated
+		c.UpdateDescription.RemovedFields = removed
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		change   changeEvent
+		expected Type
+	}{
+		{"insert", change("insert", nil), Created},
+		{"update", change("update", bson.M{"name": "x"}), Updated},
+		{"replace", change("replace", nil), Updated},
+		{"soft delete", change("update", bson.M{"deletedAt": "now"}), Deleted},
+		{"restore", change("update", nil, "deletedAt"), Restored},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := eventFromChange(tt.change)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expected, event.Type)
+			assert.Equal(t, "abc", event.PoiId)
+			assert.Equal(t, "Dresden", event.Poi.Name)
+			assert.Equal(t, 51.05, event.Poi.Latitude)
+			assert.Equal(t, 13.73, event.Poi.Longitude)
+		})
+	}
+
+	t.Run("purge ignored", func(t *testing.T) {
+		_, ok := eventFromChange(change("delete", nil))
+		assert.False(t, ok)
+	})
+
+	t.Run("document gone", func(t *testing.T) {
+		c := change("update", nil)
+		c.FullDocument = nil
+		event, ok := eventFromChange(c)
+		assert.True(t, ok)
+		assert.Nil(t, event.Poi)
+	})
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"github.com/google/uuid"
+	"poi-service/cmd/data"
+	"time"
+)
+
+// Type is the kind of change of a poi.
+type Type string
+
+// Published changes of pois.
+const (
+	Created  Type = "poi.created"
+	Updated  Type = "poi.updated"
+	Deleted  Type = "poi.deleted"
+	Restored Type = "poi.restored"
+	// Entered and Exited notify geofence subscribers, Reason contains the change that caused them.
+	Entered Type = "poi.entered"
+	Exited  Type = "poi.exited"
+)
+
+// Event describes a change of a poi.
+type Event struct {
+	Id    string `json:"id"`
+	Type  Type   `json:"type"`
+	PoiId string `json:"poiId"`
+	// Poi is the state after the change, for deleted pois the last state.
+	Poi  *data.Poi `json:"poi,omitempty"`
+	Time time.Time `json:"time"`
+	// FenceId and Reason are only set for geofence notifications.
+	FenceId string `json:"fenceId,omitempty"`
+	Reason  Type   `json:"reason,omitempty"`
+}
+
+// NewEvent creates an event with a new id for the change of the poi with poiId.
+func NewEvent(eventType Type, poiId string, poi *data.Poi) Event {
+	return Event{
+		Id:    uuid.New().String(),
+		Type:  eventType,
+		PoiId: poiId,
+		Poi:   poi,
+		Time:  time.Now().UTC(),
+	}
+}
+
+// Filter selects the events a consumer is interested in. Zero values match all events.
+type Filter struct {
+	// Area limits the events to pois inside the area.
+	Area *data.SearchArea `json:"area,omitempty" bson:"area,omitempty"`
+	// Types limits the events to the given types.
+	Types []Type `json:"types,omitempty" bson:"types,omitempty"`
+}
+
+// Matches returns true if the event passes the filter.
+func (f Filter) Matches(e Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.Area != nil && f.Area.RadiusInMeter > 0 {
+		if e.Poi == nil || !f.Area.Contains(e.Poi.Latitude, e.Poi.Longitude) {
+			return false
+		}
+	}
+	return true
+}
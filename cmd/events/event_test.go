@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"poi-service/cmd/data"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Matches(t *testing.T) {
+	dresden := &data.Poi{Name: "Dresden", Latitude: 51.050407, Longitude: 13.737262}
+	berlin := &data.Poi{Name: "Berlin", Latitude: 52.520008, Longitude: 13.404954}
+	area := &data.SearchArea{Latitude: 51.05, Longitude: 13.73, RadiusInMeter: 20000}
+
+	assert.True(t, Filter{}.Matches(NewEvent(Created, "a", dresden)))
+	assert.True(t, Filter{Area: area}.Matches(NewEvent(Created, "a", dresden)))
+	assert.False(t, Filter{Area: area}.Matches(NewEvent(Created, "b", berlin)))
+	assert.False(t, Filter{Area: area}.Matches(NewEvent(Deleted, "b", nil)), "position unknown")
+	assert.True(t, Filter{Types: []Type{Created, Deleted}}.Matches(NewEvent(Deleted, "a", nil)))
+	assert.False(t, Filter{Types: []Type{Created}}.Matches(NewEvent(Updated, "a", dresden)))
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	first, cancelFirst := bus.Subscribe(1)
+	second, cancelSecond := bus.Subscribe(1)
+	defer cancelSecond()
+
+	event := NewEvent(Created, "a", nil)
+	bus.Publish(context.Background(), event)
+	assert.Equal(t, event, <-first)
+	assert.Equal(t, event, <-second)
+
+	// slow subscribers must not block the publisher
+	bus.Publish(context.Background(), NewEvent(Updated, "a", nil))
+	bus.Publish(context.Background(), NewEvent(Updated, "a", nil))
+
+	cancelFirst()
+	cancelFirst()
+	for range first {
+	}
+
+	select {
+	case e := <-second:
+		assert.Equal(t, Updated, e.Type)
+	case <-time.After(time.Second):
+		require.Fail(t, "event missing")
+	}
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"context"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"time"
+)
+
+// NewPoiHandler decorates next, so every successful change is published to bus. It is the event source if Mongo
+// change streams are not available.
+func NewPoiHandler(next handler.PoiHandler, bus *Bus) handler.PoiHandler {
+	if next == nil || bus == nil {
+		return nil
+	}
+	return &poiHandler{next: next, bus: bus}
+}
+
+// poiHandler implements interface handler.PoiHandler
+type poiHandler struct {
+	next handler.PoiHandler
+	bus  *Bus
+}
+
+func (p *poiHandler) Create(ctx context.Context, poi *data.Poi) (string, error) {
+	id, err := p.next.Create(ctx, poi)
+	if err == nil {
+		p.bus.Publish(ctx, NewEvent(Created, id, p.stored(ctx, data.Id(id), poi)))
+	}
+	return id, err
+}
+
+func (p *poiHandler) Update(ctx context.Context, idToUpdate data.Id, updatedPoi *data.Poi) error {
+	err := p.next.Update(ctx, idToUpdate, updatedPoi)
+	if err == nil {
+		p.bus.Publish(ctx, NewEvent(Updated, string(idToUpdate), p.stored(ctx, idToUpdate, updatedPoi)))
+	}
+	return err
+}
+
+func (p *poiHandler) Get(ctx context.Context, id data.Id) (data.Poi, error) {
+	return p.next.Get(ctx, id)
+}
+
+func (p *poiHandler) Delete(ctx context.Context, id data.Id) error {
+	// the last state is needed to filter the event by area
+	before := p.current(ctx, id)
+	err := p.next.Delete(ctx, id)
+	if err == nil {
+		p.bus.Publish(ctx, NewEvent(Deleted, string(id), before))
+	}
+	return err
+}
+
+func (p *poiHandler) Search(ctx context.Context, pos data.SearchArea) (data.Pois, error) {
+	return p.next.Search(ctx, pos)
+}
+
+func (p *poiHandler) ListDeleted(ctx context.Context) (data.DeletedPois, error) {
+	return p.next.ListDeleted(ctx)
+}
+
+func (p *poiHandler) Restore(ctx context.Context, id data.Id) error {
+	err := p.next.Restore(ctx, id)
+	if err == nil {
+		p.bus.Publish(ctx, NewEvent(Restored, string(id), p.current(ctx, id)))
+	}
+	return err
+}
+
+func (p *poiHandler) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	return p.next.PurgeDeleted(ctx, retention)
+}
+
+func (p *poiHandler) GetAsOf(ctx context.Context, id data.Id, asOf time.Time) (data.Poi, error) {
+	return p.next.GetAsOf(ctx, id, asOf)
+}
+
+func (p *poiHandler) ListRevisions(ctx context.Context, id data.Id) (data.Revisions, error) {
+	return p.next.ListRevisions(ctx, id)
+}
+
+func (p *poiHandler) GetRevision(ctx context.Context, id data.Id, revision int) (data.Revision, error) {
+	return p.next.GetRevision(ctx, id, revision)
+}
+
+func (p *poiHandler) Diff(ctx context.Context, id data.Id, from, to int) (data.Changes, error) {
+	return p.next.Diff(ctx, id, from, to)
+}
+
+func (p *poiHandler) Revert(ctx context.Context, id data.Id, revision int) error {
+	err := p.next.Revert(ctx, id, revision)
+	if err == nil {
+		p.bus.Publish(ctx, NewEvent(Updated, string(id), p.current(ctx, id)))
+	}
+	return err
+}
+
+func (p *poiHandler) GetByExternalId(ctx context.Context, source, externalId string) (string, data.Poi, error) {
+	return p.next.GetByExternalId(ctx, source, externalId)
+}
+
+func (p *poiHandler) Upsert(ctx context.Context, poi *data.Poi) (string, bool, error) {
+	id, created, err := p.next.Upsert(ctx, poi)
+	if err == nil {
+		eventType := Updated
+		if created {
+			eventType = Created
+		}
+		p.bus.Publish(ctx, NewEvent(eventType, id, p.stored(ctx, data.Id(id), poi)))
+	}
+	return id, created, err
+}
+
+// Merge publishes the deletion of merge and the update of keep if it took over the external id. A repeated merge
+// changes nothing, so nothing is published.
+func (p *poiHandler) Merge(ctx context.Context, keep, merge data.Id) error {
+	merged, kept := p.current(ctx, merge), p.current(ctx, keep)
+	err := p.next.Merge(ctx, keep, merge)
+	if err != nil || merged == nil {
+		return err
+	}
+	p.bus.Publish(ctx, NewEvent(Deleted, string(merge), merged))
+	if after := p.current(ctx, keep); after != nil && kept != nil && *after != *kept {
+		p.bus.Publish(ctx, NewEvent(Updated, string(keep), after))
+	}
+	return nil
+}
+
+// current returns the state of the poi or nil if it is not available.
+func (p *poiHandler) current(ctx context.Context, id data.Id) *data.Poi {
+	poi, err := p.next.Get(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return &poi
+}
+
+// stored returns the state of the poi after a change as it was persisted, so consumers get the same data as by Get.
+// written is used if the poi is not available.
+func (p *poiHandler) stored(ctx context.Context, id data.Id, written *data.Poi) *data.Poi {
+	if poi := p.current(ctx, id); poi != nil {
+		return poi
+	}
+	poi := *written
+	return &poi
+}
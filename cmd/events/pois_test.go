@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_poiHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	bus := NewBus()
+	events, cancel := bus.Subscribe(10)
+	defer cancel()
+	handlerToTest := NewPoiHandler(next, bus)
+	ctx := context.Background()
+
+	assert.Nil(t, NewPoiHandler(next, nil))
+
+	poi := &data.Poi{Name: "new"}
+	// the events contain the persisted state, not the request
+	stored := data.Poi{Name: "new", Latitude: 51.05, Longitude: 13.73}
+	next.EXPECT().Create(ctx, poi).Return("id", nil)
+	next.EXPECT().Update(ctx, data.Id("id"), poi).Return(nil)
+	next.EXPECT().Delete(ctx, data.Id("id")).Return(nil)
+	next.EXPECT().Restore(ctx, data.Id("id")).Return(nil)
+	next.EXPECT().Get(ctx, data.Id("id")).Return(stored, nil).Times(4)
+
+	_, err := handlerToTest.Create(ctx, poi)
+	require.NoError(t, err)
+	require.NoError(t, handlerToTest.Update(ctx, "id", poi))
+	require.NoError(t, handlerToTest.Delete(ctx, "id"))
+	require.NoError(t, handlerToTest.Restore(ctx, "id"))
+
+	// failed changes are not published
+	next.EXPECT().Update(ctx, data.Id("other"), poi).Return(errors.New("failed"))
+	assert.Error(t, handlerToTest.Update(ctx, "other", poi))
+
+	for _, expected := range []Type{Created, Updated, Deleted, Restored} {
+		event := <-events
+		assert.Equal(t, expected, event.Type)
+		assert.Equal(t, "id", event.PoiId)
+		assert.Equal(t, stored, *event.Poi)
+	}
+	assert.Empty(t, events)
+}
+
+func Test_poiHandler_Upsert(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	bus := NewBus()
+	events, cancel := bus.Subscribe(10)
+	defer cancel()
+	handlerToTest := NewPoiHandler(next, bus)
+	ctx := context.Background()
+
+	poi := &data.Poi{Name: "new", Source: "partner", ExternalId: "p-1"}
+	next.EXPECT().Get(ctx, data.Id("id")).Return(*poi, nil).Times(2)
+	next.EXPECT().Upsert(ctx, poi).Return("id", true, nil)
+	next.EXPECT().Upsert(ctx, poi).Return("id", false, nil)
+	next.EXPECT().Upsert(ctx, poi).Return("id", false, handler.PoiInTrash)
+
+	for i := 0; i < 3; i++ {
+		handlerToTest.Upsert(ctx, poi)
+	}
+
+	for _, expected := range []Type{Created, Updated} {
+		event := <-events
+		assert.Equal(t, expected, event.Type)
+		assert.Equal(t, "p-1", event.Poi.ExternalId)
+	}
+	assert.Empty(t, events)
+}
+
+func Test_poiHandler_Merge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	bus := NewBus()
+	events, cancel := bus.Subscribe(10)
+	defer cancel()
+	handlerToTest := NewPoiHandler(next, bus)
+	ctx := context.Background()
+
+	kept := data.Poi{Name: "Frauenkirche"}
+	merged := data.Poi{Name: "Frauenkirche", Source: "partner", ExternalId: "p-1"}
+	gomock.InOrder(
+		next.EXPECT().Get(ctx, data.Id("b")).Return(merged, nil),
+		next.EXPECT().Get(ctx, data.Id("a")).Return(kept, nil),
+		next.EXPECT().Merge(ctx, data.Id("a"), data.Id("b")).Return(nil),
+		next.EXPECT().Get(ctx, data.Id("a")).Return(kept, nil),
+	)
+	require.NoError(t, handlerToTest.Merge(ctx, "a", "b"))
+
+	event := <-events
+	assert.Equal(t, Deleted, event.Type)
+	assert.Equal(t, "b", event.PoiId)
+	assert.Empty(t, events, "keep is unchanged, so only the deletion is published")
+
+	// failed merges are not published
+	next.EXPECT().Get(ctx, data.Id("c")).Return(merged, nil)
+	next.EXPECT().Get(ctx, data.Id("a")).Return(merged, nil)
+	next.EXPECT().Merge(ctx, data.Id("a"), data.Id("c")).Return(handler.ExternalIdConflict)
+	assert.ErrorIs(t, handlerToTest.Merge(ctx, "a", "c"), handler.ExternalIdConflict)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/data"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sseBuffer is the number of events queued for a slow client before events are dropped.
+	sseBuffer = 64
+	// sseKeepAlive is the interval of comments that keep idle connections open through proxies.
+	sseKeepAlive = 15 * time.Second
+)
+
+// SSEHandler streams the events of bus as Server-Sent Events. The stream can be filtered by the query parameters
+// latitude, longitude and radius (in meter) and a comma separated list of types.
+func SSEHandler(bus *Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			log.Ctx(r.Context()).Error().Msg("streaming not supported by response writer")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		filter, err := filterFromQuery(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(err.Error())
+			return
+		}
+
+		events, cancel := bus.Subscribe(sseBuffer)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if !filter.Matches(event) {
+					continue
+				}
+				payload, err := json.Marshal(event)
+				if err != nil {
+					log.Ctx(r.Context()).Warn().Err(err).Msg("encoding event failed")
+					continue
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, payload)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// filterFromQuery reads the filter of a stream from the query parameters.
+func filterFromQuery(r *http.Request) (filter Filter, err error) {
+	query := r.URL.Query()
+
+	if radius := query.Get("radius"); radius != "" {
+		area := data.SearchArea{}
+		if area.RadiusInMeter, err = strconv.ParseUint(radius, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid radius: %w", err)
+		}
+		if area.Latitude, err = strconv.ParseFloat(query.Get("latitude"), 64); err != nil {
+			return filter, fmt.Errorf("invalid latitude: %w", err)
+		}
+		if area.Longitude, err = strconv.ParseFloat(query.Get("longitude"), 64); err != nil {
+			return filter, fmt.Errorf("invalid longitude: %w", err)
+		}
+		filter.Area = &area
+	}
+
+	if types := query.Get("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			filter.Types = append(filter.Types, Type(strings.TrimSpace(t)))
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/data"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEHandler(t *testing.T) {
+	bus := NewBus()
+	server := httptest.NewServer(SSEHandler(bus))
+	defer server.Close()
+
+	t.Run("invalid filter", func(t *testing.T) {
+		rsp, err := http.Get(server.URL + "?radius=abc")
+		require.NoError(t, err)
+		rsp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	})
+
+	t.Run("filtered stream", func(t *testing.T) {
+		rsp, err := http.Get(server.URL + "?latitude=51.05&longitude=13.73&radius=20000&types=poi.created,poi.updated")
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, "text/event-stream", rsp.Header.Get("Content-Type"))
+
+		// the subscription is active as soon as the header is received
+		bus.Publish(context.Background(), NewEvent(Created, "berlin", &data.Poi{Latitude: 52.52, Longitude: 13.40}))
+		bus.Publish(context.Background(), NewEvent(Deleted, "dresden", &data.Poi{Latitude: 51.05, Longitude: 13.73}))
+		expected := NewEvent(Created, "dresden", &data.Poi{Latitude: 51.05, Longitude: 13.73})
+		bus.Publish(context.Background(), expected)
+
+		lines := make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(rsp.Body)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+			close(lines)
+		}()
+
+		var received []string
+		for len(received) < 3 {
+			select {
+			case line := <-lines:
+				received = append(received, line)
+			case <-time.After(time.Second):
+				require.Fail(t, "event missing", "received %v", received)
+			}
+		}
+
+		assert.Equal(t, "id: "+expected.Id, received[0])
+		assert.Equal(t, "event: poi.created", received[1])
+		var event Event
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(received[2], "data: ")), &event))
+		assert.Equal(t, "dresden", event.PoiId)
+	})
+}
@@ -0,0 +1,237 @@
+package events
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"poi-service/cmd/download"
+	"strconv"
+	"time"
+)
+
+// Headers of webhook deliveries.
+const (
+	// SignatureHeader contains "t=<unix time>,v1=<hex HMAC-SHA256 of '<unix time>.<body>'>". Receivers must check
+	// the signature with the secret of the subscription and should reject old timestamps to prevent replays.
+	SignatureHeader = "X-Poi-Signature"
+	// EventTypeHeader contains the type of the event.
+	EventTypeHeader = "X-Poi-Event"
+	// DeliveryHeader contains the id of the event, it is the same for all attempts of a delivery.
+	DeliveryHeader = "X-Poi-Delivery"
+)
+
+// Defaults of the webhook delivery.
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 5 * time.Minute
+	// maxConcurrentDeliveries limits the number of deliveries in progress.
+	maxConcurrentDeliveries = 16
+)
+
+// Subscription registers a url for events.
+type Subscription struct {
+	Id  string `json:"id" bson:"_id"`
+	Url string `json:"url" bson:"url"`
+	// Secret is used to sign the payload, it is only returned on creation.
+	Secret    string    `json:"-" bson:"secret"`
+	Filter    Filter    `json:"filter" bson:"filter"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// NewSubscription creates a subscription with a new id and secret.
+func NewSubscription(url string, filter Filter) (Subscription, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Subscription{}, err
+	}
+	return Subscription{
+		Id:        uuid.New().String(),
+		Url:       url,
+		Secret:    hex.EncodeToString(secret),
+		Filter:    filter,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// DeadLetter is an event that could not be delivered to a subscription.
+type DeadLetter struct {
+	Id             string    `json:"id" bson:"_id"`
+	SubscriptionId string    `json:"subscriptionId" bson:"subscriptionId"`
+	Event          Event     `json:"event" bson:"event"`
+	Attempts       int       `json:"attempts" bson:"attempts"`
+	LastError      string    `json:"lastError" bson:"lastError"`
+	FailedAt       time.Time `json:"failedAt" bson:"failedAt"`
+}
+
+// Sign returns the value of the SignatureHeader for body.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+//------------------------------------------------------------------------------
+
+// DispatcherOption configures optional behaviour of the Dispatcher.
+type DispatcherOption func(d *Dispatcher)
+
+// WithMaxAttempts sets the number of delivery attempts before an event is moved to the dead letters.
+func WithMaxAttempts(attempts int) DispatcherOption {
+	return func(d *Dispatcher) { d.maxAttempts = attempts }
+}
+
+// WithBackoff sets the wait before the first retry and the maximum wait, the wait doubles with every attempt.
+func WithBackoff(initial, max time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.initialBackoff = initial
+		d.maxBackoff = max
+	}
+}
+
+// Dispatcher delivers events to the webhook subscriptions.
+type Dispatcher struct {
+	store          WebhookStore
+	client         download.HttpRequester
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	deliveries     chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher that sends events with client to the subscriptions of store.
+func NewDispatcher(store WebhookStore, client download.HttpRequester, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		store:          store,
+		client:         client,
+		maxAttempts:    DefaultMaxAttempts,
+		initialBackoff: DefaultInitialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+		deliveries:     make(chan struct{}, maxConcurrentDeliveries),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run delivers the events to all matching subscriptions until ctx is done or events is closed. Deliveries run
+// concurrently, so the order of events is not guaranteed.
+func (d *Dispatcher) Run(ctx context.Context, events <-chan Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			subscriptions, err := d.store.ListSubscriptions(ctx)
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Str("event", event.Id).Msg("reading webhook subscriptions failed")
+				continue
+			}
+			for _, subscription := range subscriptions {
+				if !subscription.Filter.Matches(event) {
+					continue
+				}
+				select {
+				case d.deliveries <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				go func(subscription Subscription, event Event) {
+					defer func() { <-d.deliveries }()
+					d.Deliver(ctx, subscription, event)
+				}(subscription, event)
+			}
+		}
+	}
+}
+
+// Deliver sends the event to the subscription and retries with exponential backoff. If all attempts fail the event
+// is stored as dead letter.
+func (d *Dispatcher) Deliver(ctx context.Context, subscription Subscription, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := d.initialBackoff
+	for attempt := 1; ; attempt++ {
+		err = d.send(ctx, subscription, event, body)
+		if err == nil {
+			return nil
+		}
+		log.Ctx(ctx).Warn().Err(err).Str("subscription", subscription.Id).Str("event", event.Id).Int("attempt", attempt).Msg("webhook delivery failed")
+
+		if attempt >= d.maxAttempts {
+			return d.deadLetter(ctx, subscription, event, attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return d.deadLetter(context.Background(), subscription, event, attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > d.maxBackoff {
+			backoff = d.maxBackoff
+		}
+	}
+}
+
+// Redeliver sends a dead letter again and removes it if the delivery succeeds.
+func (d *Dispatcher) Redeliver(ctx context.Context, deadLetterId string) error {
+	letter, err := d.store.GetDeadLetter(ctx, deadLetterId)
+	if err != nil {
+		return err
+	}
+	subscription, err := d.store.GetSubscription(ctx, letter.SubscriptionId)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(letter.Event)
+	if err != nil {
+		return err
+	}
+	if err := d.send(ctx, subscription, letter.Event, body); err != nil {
+		return err
+	}
+	return d.store.DeleteDeadLetter(ctx, deadLetterId)
+}
+
+func (d *Dispatcher) send(ctx context.Context, subscription Subscription, event Event, body []byte) error {
+	header := http.Header{}
+	header.Set(SignatureHeader, Sign(subscription.Secret, time.Now(), body))
+	header.Set(EventTypeHeader, string(event.Type))
+	header.Set(DeliveryHeader, event.Id)
+	_, err := d.client.PostContent(ctx, subscription.Url, "application/json", body, header)
+	return err
+}
+
+func (d *Dispatcher) deadLetter(ctx context.Context, subscription Subscription, event Event, attempts int, cause error) error {
+	letter := DeadLetter{
+		Id:             uuid.New().String(),
+		SubscriptionId: subscription.Id,
+		Event:          event,
+		Attempts:       attempts,
+		LastError:      cause.Error(),
+		FailedAt:       time.Now().UTC(),
+	}
+	if err := d.store.AddDeadLetter(ctx, letter); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("subscription", subscription.Id).Str("event", event.Id).Msg("storing dead letter failed")
+		return err
+	}
+	return fmt.Errorf("%w: moved to dead letters after %d attempts", cause, attempts)
+}
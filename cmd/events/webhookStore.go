@@ -0,0 +1,205 @@
+package events
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sort"
+	"sync"
+)
+
+// NotFound is given if the requested subscription or dead letter does not exist.
+const NotFound = NotFoundError("not found")
+
+type NotFoundError string
+
+func (e NotFoundError) Error() string { return string(e) }
+
+// WebhookStore persists the webhook subscriptions and the dead letters.
+type WebhookStore interface {
+	AddSubscription(ctx context.Context, subscription Subscription) error
+	// GetSubscription returns the subscription or NotFound.
+	GetSubscription(ctx context.Context, id string) (Subscription, error)
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	// DeleteSubscription removes the subscription or returns NotFound.
+	DeleteSubscription(ctx context.Context, id string) error
+
+	AddDeadLetter(ctx context.Context, letter DeadLetter) error
+	// GetDeadLetter returns the dead letter or NotFound.
+	GetDeadLetter(ctx context.Context, id string) (DeadLetter, error)
+	ListDeadLetters(ctx context.Context) ([]DeadLetter, error)
+	// DeleteDeadLetter removes the dead letter or returns NotFound.
+	DeleteDeadLetter(ctx context.Context, id string) error
+}
+
+//------------------------------------------------------------------------------
+
+// NewMemoryWebhookStore creates a WebhookStore that keeps everything in memory only, e.g. for tests.
+func NewMemoryWebhookStore() WebhookStore {
+	return &memoryWebhookStore{
+		subscriptions: make(map[string]Subscription),
+		deadLetters:   make(map[string]DeadLetter),
+	}
+}
+
+// memoryWebhookStore implements interface WebhookStore
+type memoryWebhookStore struct {
+	mu            sync.RWMutex
+	subscriptions map[string]Subscription
+	deadLetters   map[string]DeadLetter
+}
+
+func (m *memoryWebhookStore) AddSubscription(_ context.Context, subscription Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscriptions[subscription.Id] = subscription
+	return nil
+}
+
+func (m *memoryWebhookStore) GetSubscription(_ context.Context, id string) (Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subscription, ok := m.subscriptions[id]
+	if !ok {
+		return Subscription{}, NotFound
+	}
+	return subscription, nil
+}
+
+func (m *memoryWebhookStore) ListSubscriptions(_ context.Context) ([]Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]Subscription, 0, len(m.subscriptions))
+	for _, subscription := range m.subscriptions {
+		result = append(result, subscription)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result, nil
+}
+
+func (m *memoryWebhookStore) DeleteSubscription(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subscriptions[id]; !ok {
+		return NotFound
+	}
+	delete(m.subscriptions, id)
+	return nil
+}
+
+func (m *memoryWebhookStore) AddDeadLetter(_ context.Context, letter DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deadLetters[letter.Id] = letter
+	return nil
+}
+
+func (m *memoryWebhookStore) GetDeadLetter(_ context.Context, id string) (DeadLetter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	letter, ok := m.deadLetters[id]
+	if !ok {
+		return DeadLetter{}, NotFound
+	}
+	return letter, nil
+}
+
+func (m *memoryWebhookStore) ListDeadLetters(_ context.Context) ([]DeadLetter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]DeadLetter, 0, len(m.deadLetters))
+	for _, letter := range m.deadLetters {
+		result = append(result, letter)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].FailedAt.Before(result[j].FailedAt) })
+	return result, nil
+}
+
+func (m *memoryWebhookStore) DeleteDeadLetter(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.deadLetters[id]; !ok {
+		return NotFound
+	}
+	delete(m.deadLetters, id)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoWebhookStore creates a WebhookStore that persists the subscriptions and dead letters in the given
+// collections.
+func NewMongoWebhookStore(subscriptions, deadLetters *mongo.Collection) WebhookStore {
+	return &mongoWebhookStore{subscriptions: subscriptions, deadLetters: deadLetters}
+}
+
+// mongoWebhookStore implements interface WebhookStore
+type mongoWebhookStore struct {
+	subscriptions *mongo.Collection
+	deadLetters   *mongo.Collection
+}
+
+func (m *mongoWebhookStore) AddSubscription(ctx context.Context, subscription Subscription) error {
+	_, err := m.subscriptions.InsertOne(ctx, subscription)
+	return err
+}
+
+func (m *mongoWebhookStore) GetSubscription(ctx context.Context, id string) (subscription Subscription, err error) {
+	err = m.subscriptions.FindOne(ctx, bson.M{"_id": id}).Decode(&subscription)
+	if err == mongo.ErrNoDocuments {
+		err = NotFound
+	}
+	return
+}
+
+func (m *mongoWebhookStore) ListSubscriptions(ctx context.Context) (result []Subscription, err error) {
+	cur, err := m.subscriptions.Find(ctx, bson.M{})
+	if err != nil {
+		return
+	}
+	result = []Subscription{}
+	err = cur.All(ctx, &result)
+	return
+}
+
+func (m *mongoWebhookStore) DeleteSubscription(ctx context.Context, id string) error {
+	return deleteOne(ctx, m.subscriptions, id)
+}
+
+func (m *mongoWebhookStore) AddDeadLetter(ctx context.Context, letter DeadLetter) error {
+	_, err := m.deadLetters.InsertOne(ctx, letter)
+	return err
+}
+
+func (m *mongoWebhookStore) GetDeadLetter(ctx context.Context, id string) (letter DeadLetter, err error) {
+	err = m.deadLetters.FindOne(ctx, bson.M{"_id": id}).Decode(&letter)
+	if err == mongo.ErrNoDocuments {
+		err = NotFound
+	}
+	return
+}
+
+func (m *mongoWebhookStore) ListDeadLetters(ctx context.Context) (result []DeadLetter, err error) {
+	cur, err := m.deadLetters.Find(ctx, bson.M{})
+	if err != nil {
+		return
+	}
+	result = []DeadLetter{}
+	err = cur.All(ctx, &result)
+	return
+}
+
+func (m *mongoWebhookStore) DeleteDeadLetter(ctx context.Context, id string) error {
+	return deleteOne(ctx, m.deadLetters, id)
+}
+
+func deleteOne(ctx context.Context, collection *mongo.Collection, id string) error {
+	res, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return NotFound
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/data"
+	"poi-service/cmd/download"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver records the webhook deliveries and fails the first ones.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.calls++
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	rc.bodies = append(rc.bodies, body)
+	rc.headers = append(rc.headers, r.Header.Clone())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rc *receiver) received() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.bodies)
+}
+
+func newTestDispatcher(store WebhookStore) *Dispatcher {
+	return NewDispatcher(store, download.NewHttpRequester(http.DefaultClient),
+		WithMaxAttempts(3), WithBackoff(time.Millisecond, 2*time.Millisecond))
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	rc := &receiver{failures: 2}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store := NewMemoryWebhookStore()
+	subscription, err := NewSubscription(server.URL, Filter{})
+	require.NoError(t, err)
+	event := NewEvent(Created, "abc", &data.Poi{Name: "Dresden"})
+
+	require.NoError(t, newTestDispatcher(store).Deliver(context.Background(), subscription, event))
+	assert.Equal(t, 3, rc.calls, "two retries")
+	require.Equal(t, 1, rc.received())
+
+	header := rc.headers[0]
+	assert.Equal(t, string(Created), header.Get(EventTypeHeader))
+	assert.Equal(t, event.Id, header.Get(DeliveryHeader))
+
+	// the receiver can verify the signature with the secret
+	var ts int64
+	_, err = fmt.Sscanf(header.Get(SignatureHeader), "t=%d,", &ts)
+	require.NoError(t, err)
+	assert.Equal(t, Sign(subscription.Secret, time.Unix(ts, 0), rc.bodies[0]), header.Get(SignatureHeader))
+	assert.NotEqual(t, Sign("other", time.Unix(ts, 0), rc.bodies[0]), header.Get(SignatureHeader))
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	rc := &receiver{failures: 3}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store := NewMemoryWebhookStore()
+	subscription, err := NewSubscription(server.URL, Filter{})
+	require.NoError(t, err)
+	require.NoError(t, store.AddSubscription(context.Background(), subscription))
+	dispatcher := newTestDispatcher(store)
+
+	err = dispatcher.Deliver(context.Background(), subscription, NewEvent(Deleted, "abc", nil))
+	assert.Error(t, err)
+	assert.Equal(t, 0, rc.received())
+
+	letters, err := store.ListDeadLetters(context.Background())
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, subscription.Id, letters[0].SubscriptionId)
+
+	// the receiver is available again
+	require.NoError(t, dispatcher.Redeliver(context.Background(), letters[0].Id))
+	assert.Equal(t, 1, rc.received())
+	letters, _ = store.ListDeadLetters(context.Background())
+	assert.Empty(t, letters)
+
+	assert.ErrorIs(t, dispatcher.Redeliver(context.Background(), "unknown"), NotFound)
+}
+
+func TestDispatcher_Run(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store := NewMemoryWebhookStore()
+	onlyDeleted, _ := NewSubscription(server.URL, Filter{Types: []Type{Deleted}})
+	require.NoError(t, store.AddSubscription(context.Background(), onlyDeleted))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewBus()
+	events, stop := bus.Subscribe(10)
+	defer stop()
+	go newTestDispatcher(store).Run(ctx, events)
+
+	bus.Publish(context.Background(), NewEvent(Created, "abc", nil))
+	bus.Publish(context.Background(), NewEvent(Deleted, "abc", nil))
+
+	assert.Eventually(t, func() bool { return rc.received() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Contains(t, string(rc.bodies[0]), string(Deleted))
+}
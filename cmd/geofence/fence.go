@@ -0,0 +1,46 @@
+package geofence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"poi-service/cmd/data"
+	"time"
+)
+
+// Fence is an area whose owner is notified if pois enter or leave it.
+type Fence struct {
+	Id    string          `json:"id" bson:"_id"`
+	Area  data.SearchArea `json:"area" bson:"area"`
+	Owner string          `json:"owner" bson:"owner"`
+	// WebhookUrl receives the notifications if set, otherwise they are only available via WebSocket.
+	WebhookUrl string `json:"webhookUrl,omitempty" bson:"webhookUrl,omitempty"`
+	// Secret is used to sign the webhook payloads, it is only returned on creation.
+	Secret    string    `json:"-" bson:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// NewFence creates a fence with a new id for owner. If webhookUrl is given a secret to sign the payloads is
+// generated.
+func NewFence(area data.SearchArea, owner, webhookUrl string) (Fence, error) {
+	fence := Fence{
+		Id:         uuid.New().String(),
+		Area:       area,
+		Owner:      owner,
+		WebhookUrl: webhookUrl,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if webhookUrl != "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return Fence{}, err
+		}
+		fence.Secret = hex.EncodeToString(secret)
+	}
+	return fence, nil
+}
+
+// contains returns true if poi is inside the fence, nil is never inside.
+func (f *Fence) contains(poi *data.Poi) bool {
+	return poi != nil && f.Area.Contains(poi.Latitude, poi.Longitude)
+}
@@ -0,0 +1,89 @@
+package geofence
+
+import (
+	"math"
+)
+
+// cellSize is the edge length in degree of the cells of the grid index. Fences are registered in all cells their
+// bounding box touches, so a lookup only checks the fences of a single cell.
+const cellSize = 0.5
+
+// metersPerDegree is the length of a degree of latitude.
+const metersPerDegree = 111320
+
+type cell struct {
+	lat, long int
+}
+
+// gridIndex is a spatial index of fences on a regular grid of latitude and longitude.
+type gridIndex struct {
+	cells map[cell]map[string]*Fence
+}
+
+func newGridIndex() *gridIndex {
+	return &gridIndex{cells: make(map[cell]map[string]*Fence)}
+}
+
+func cellOf(lat, long float64) cell {
+	return cell{lat: int(math.Floor(lat / cellSize)), long: normalizeLong(int(math.Floor(long / cellSize)))}
+}
+
+// normalizeLong maps the longitude index into the range of -180 to 180 degree, so areas crossing the date line
+// are found.
+func normalizeLong(index int) int {
+	n := int(360 / cellSize)
+	half := n / 2
+	return ((index+half)%n+n)%n - half
+}
+
+// cellsOf returns the cells touched by the bounding box of the fence.
+func cellsOf(f *Fence) []cell {
+	dLat := float64(f.Area.RadiusInMeter) / metersPerDegree
+	minLat := math.Max(f.Area.Latitude-dLat, -90)
+	maxLat := math.Min(f.Area.Latitude+dLat, 90)
+
+	// near the poles the longitude range covers the whole earth
+	minLong, maxLong := -180.0, 180.0-cellSize
+	cos := math.Cos(math.Max(math.Abs(minLat), math.Abs(maxLat)) * math.Pi / 180)
+	if dLong := dLat / math.Max(cos, 1e-9); dLong < 180 {
+		minLong, maxLong = f.Area.Longitude-dLong, f.Area.Longitude+dLong
+	}
+
+	var cells []cell
+	seen := make(map[cell]bool)
+	for lat := math.Floor(minLat / cellSize); lat <= math.Floor(maxLat/cellSize); lat++ {
+		for long := math.Floor(minLong / cellSize); long <= math.Floor(maxLong/cellSize); long++ {
+			c := cell{lat: int(lat), long: normalizeLong(int(long))}
+			if !seen[c] {
+				seen[c] = true
+				cells = append(cells, c)
+			}
+		}
+	}
+	return cells
+}
+
+func (g *gridIndex) add(f *Fence) {
+	for _, c := range cellsOf(f) {
+		fences, ok := g.cells[c]
+		if !ok {
+			fences = make(map[string]*Fence)
+			g.cells[c] = fences
+		}
+		fences[f.Id] = f
+	}
+}
+
+func (g *gridIndex) remove(f *Fence) {
+	for _, c := range cellsOf(f) {
+		delete(g.cells[c], f.Id)
+		if len(g.cells[c]) == 0 {
+			delete(g.cells, c)
+		}
+	}
+}
+
+// candidates returns the fences whose bounding box contains the position.
+func (g *gridIndex) candidates(lat, long float64) map[string]*Fence {
+	return g.cells[cellOf(lat, long)]
+}
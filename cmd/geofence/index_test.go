@@ -0,0 +1,36 @@
+package geofence
+
+import (
+	"poi-service/cmd/data"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_gridIndex(t *testing.T) {
+	index := newGridIndex()
+	dresden := &Fence{Id: "dresden", Area: data.SearchArea{Latitude: 51.05, Longitude: 13.73, RadiusInMeter: 50000}}
+	dateLine := &Fence{Id: "fiji", Area: data.SearchArea{Latitude: -17, Longitude: 179.9, RadiusInMeter: 50000}}
+	index.add(dresden)
+	index.add(dateLine)
+
+	assert.Contains(t, index.candidates(51.05, 13.73), "dresden")
+	// the bounding box reaches into the neighbour cells
+	assert.Contains(t, index.candidates(51.4, 13.73), "dresden")
+	assert.NotContains(t, index.candidates(52.52, 13.40), "dresden")
+
+	assert.Contains(t, index.candidates(-17, 179.9), "fiji")
+	assert.Contains(t, index.candidates(-17, -179.9), "fiji", "area crosses the date line")
+
+	index.remove(dresden)
+	assert.Empty(t, index.candidates(51.05, 13.73))
+	assert.Len(t, index.cells, len(cellsOf(dateLine)), "empty cells are removed")
+}
+
+func Test_cellsOf_pole(t *testing.T) {
+	fence := &Fence{Area: data.SearchArea{Latitude: 89.9, Longitude: 0, RadiusInMeter: 50000}}
+	cells := cellsOf(fence)
+	// every longitude around the pole is covered
+	assert.Contains(t, cells, cellOf(89.9, 179))
+	assert.Contains(t, cells, cellOf(89.9, -179))
+}
@@ -0,0 +1,149 @@
+package geofence
+
+import (
+	"context"
+	"poi-service/cmd/data"
+	"poi-service/cmd/events"
+	"poi-service/cmd/handler"
+	"time"
+)
+
+// NewPoiHandler decorates next, so the fences of registry are notified about pois that enter or leave them.
+func NewPoiHandler(next handler.PoiHandler, registry *Registry) handler.PoiHandler {
+	if next == nil || registry == nil {
+		return nil
+	}
+	return &poiHandler{next: next, registry: registry}
+}
+
+// poiHandler implements interface handler.PoiHandler
+type poiHandler struct {
+	next     handler.PoiHandler
+	registry *Registry
+}
+
+func (p *poiHandler) Create(ctx context.Context, poi *data.Poi) (string, error) {
+	id, err := p.next.Create(ctx, poi)
+	if err == nil {
+		created := *poi
+		p.registry.Changed(id, events.Created, nil, &created)
+	}
+	return id, err
+}
+
+func (p *poiHandler) Update(ctx context.Context, idToUpdate data.Id, updatedPoi *data.Poi) error {
+	before := p.current(ctx, idToUpdate)
+	err := p.next.Update(ctx, idToUpdate, updatedPoi)
+	if err == nil {
+		p.registry.Changed(string(idToUpdate), events.Updated, before, p.stored(ctx, idToUpdate, updatedPoi))
+	}
+	return err
+}
+
+func (p *poiHandler) Get(ctx context.Context, id data.Id) (data.Poi, error) {
+	return p.next.Get(ctx, id)
+}
+
+func (p *poiHandler) Delete(ctx context.Context, id data.Id) error {
+	before := p.current(ctx, id)
+	err := p.next.Delete(ctx, id)
+	if err == nil {
+		p.registry.Changed(string(id), events.Deleted, before, nil)
+	}
+	return err
+}
+
+func (p *poiHandler) Search(ctx context.Context, pos data.SearchArea) (data.Pois, error) {
+	return p.next.Search(ctx, pos)
+}
+
+func (p *poiHandler) ListDeleted(ctx context.Context) (data.DeletedPois, error) {
+	return p.next.ListDeleted(ctx)
+}
+
+func (p *poiHandler) Restore(ctx context.Context, id data.Id) error {
+	err := p.next.Restore(ctx, id)
+	if err == nil {
+		p.registry.Changed(string(id), events.Restored, nil, p.current(ctx, id))
+	}
+	return err
+}
+
+func (p *poiHandler) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	return p.next.PurgeDeleted(ctx, retention)
+}
+
+func (p *poiHandler) GetAsOf(ctx context.Context, id data.Id, asOf time.Time) (data.Poi, error) {
+	return p.next.GetAsOf(ctx, id, asOf)
+}
+
+func (p *poiHandler) ListRevisions(ctx context.Context, id data.Id) (data.Revisions, error) {
+	return p.next.ListRevisions(ctx, id)
+}
+
+func (p *poiHandler) GetRevision(ctx context.Context, id data.Id, revision int) (data.Revision, error) {
+	return p.next.GetRevision(ctx, id, revision)
+}
+
+func (p *poiHandler) Diff(ctx context.Context, id data.Id, from, to int) (data.Changes, error) {
+	return p.next.Diff(ctx, id, from, to)
+}
+
+func (p *poiHandler) Revert(ctx context.Context, id data.Id, revision int) error {
+	before := p.current(ctx, id)
+	err := p.next.Revert(ctx, id, revision)
+	if err == nil {
+		p.registry.Changed(string(id), events.Updated, before, p.current(ctx, id))
+	}
+	return err
+}
+
+func (p *poiHandler) GetByExternalId(ctx context.Context, source, externalId string) (string, data.Poi, error) {
+	return p.next.GetByExternalId(ctx, source, externalId)
+}
+
+func (p *poiHandler) Upsert(ctx context.Context, poi *data.Poi) (string, bool, error) {
+	var before *data.Poi
+	if _, existing, err := p.next.GetByExternalId(ctx, poi.Source, poi.ExternalId); err == nil {
+		before = &existing
+	}
+	id, created, err := p.next.Upsert(ctx, poi)
+	if err == nil {
+		upserted := p.stored(ctx, data.Id(id), poi)
+		if created {
+			p.registry.Changed(id, events.Created, nil, upserted)
+		} else {
+			p.registry.Changed(id, events.Updated, before, upserted)
+		}
+	}
+	return id, created, err
+}
+
+func (p *poiHandler) Merge(ctx context.Context, keep, merge data.Id) error {
+	// the location of keep is not changed by a merge
+	before := p.current(ctx, merge)
+	err := p.next.Merge(ctx, keep, merge)
+	if err == nil && before != nil {
+		p.registry.Changed(string(merge), events.Deleted, before, nil)
+	}
+	return err
+}
+
+// current returns the state of the poi or nil if it is not available.
+func (p *poiHandler) current(ctx context.Context, id data.Id) *data.Poi {
+	poi, err := p.next.Get(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return &poi
+}
+
+// stored returns the state of the poi after a change, so it is read the same way as the state before. written is
+// used if the poi is not available.
+func (p *poiHandler) stored(ctx context.Context, id data.Id, written *data.Poi) *data.Poi {
+	if poi := p.current(ctx, id); poi != nil {
+		return poi
+	}
+	poi := *written
+	return &poi
+}
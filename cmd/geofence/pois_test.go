@@ -0,0 +1,97 @@
+package geofence
+
+import (
+	"context"
+	"poi-service/cmd/data"
+	"poi-service/cmd/events"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_poiHandler_notifiesFences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	registry, fence := newTestRegistry(t)
+	notifications, cancel := registry.Listen(fence.Id)
+	defer cancel()
+	poiHandlerToTest := NewPoiHandler(next, registry)
+
+	next.EXPECT().Create(ctx, gomock.Any()).Return("a", nil)
+	_, err := poiHandlerToTest.Create(ctx, inDresden)
+	require.NoError(t, err)
+	event := receive(t, notifications)
+	assert.Equal(t, events.Entered, event.Type)
+
+	next.EXPECT().Get(ctx, data.Id("a")).Return(*inDresden, nil)
+	next.EXPECT().Update(ctx, data.Id("a"), inBerlin).Return(nil)
+	next.EXPECT().Get(ctx, data.Id("a")).Return(*inBerlin, nil)
+	require.NoError(t, poiHandlerToTest.Update(ctx, "a", inBerlin))
+	event = receive(t, notifications)
+	assert.Equal(t, events.Exited, event.Type)
+
+	next.EXPECT().Restore(ctx, data.Id("b")).Return(nil)
+	next.EXPECT().Get(ctx, data.Id("b")).Return(*inDresden, nil)
+	require.NoError(t, poiHandlerToTest.Restore(ctx, "b"))
+	event = receive(t, notifications)
+	assert.Equal(t, events.Entered, event.Type)
+	assert.Equal(t, events.Restored, event.Reason)
+
+	// failed mutations are not notified
+	next.EXPECT().Get(ctx, data.Id("b")).Return(*inDresden, nil)
+	next.EXPECT().Delete(ctx, data.Id("b")).Return(handler.PoiNotFound)
+	assert.Error(t, poiHandlerToTest.Delete(ctx, "b"))
+	assert.Empty(t, notifications)
+}
+
+func Test_poiHandler_upsertNotifiesFences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	next := handler.NewMockPoiHandler(ctrl)
+	registry, fence := newTestRegistry(t)
+	notifications, cancel := registry.Listen(fence.Id)
+	defer cancel()
+	poiHandlerToTest := NewPoiHandler(next, registry)
+
+	moved := *inBerlin
+	moved.Source, moved.ExternalId = "partner", "p-1"
+	next.EXPECT().GetByExternalId(ctx, "partner", "p-1").Return("a", *inDresden, nil)
+	next.EXPECT().Upsert(ctx, &moved).Return("a", false, nil)
+	next.EXPECT().Get(ctx, data.Id("a")).Return(moved, nil)
+	_, _, err := poiHandlerToTest.Upsert(ctx, &moved)
+	require.NoError(t, err)
+	event := receive(t, notifications)
+	assert.Equal(t, events.Exited, event.Type)
+	assert.Equal(t, "a", event.PoiId)
+}
+
+func Test_poiHandler_updateInsideFence(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	db := handler.NewMockDbHandler(ctrl)
+	registry, fence := newTestRegistry(t)
+	notifications, cancel := registry.Listen(fence.Id)
+	defer cancel()
+	// the states before and after the update are read from the stored location
+	poiHandlerToTest := NewPoiHandler(handler.NewPoiHandler(db), registry)
+
+	renamed := *inDresden
+	renamed.Name = "Dresdner Frauenkirche"
+	stored := handler.PoiDbEntry{Id: "a", Name: inDresden.Name, Location: handler.NewLocation(inDresden.Latitude, inDresden.Longitude)}
+	db.EXPECT().GetPoi(ctx, "a").Return(stored, nil)
+	db.EXPECT().UpdatePoi(ctx, "a", gomock.Any()).Return(nil)
+	stored.Name = renamed.Name
+	db.EXPECT().GetPoi(ctx, "a").Return(stored, nil)
+	require.NoError(t, poiHandlerToTest.Update(ctx, "a", &renamed))
+	assert.Empty(t, notifications, "the poi did not move")
+}
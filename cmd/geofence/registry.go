@@ -0,0 +1,254 @@
+package geofence
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"poi-service/cmd/data"
+	"poi-service/cmd/events"
+	"sync"
+	"time"
+)
+
+// MaxRadiusInMeter limits the size of a fence, so the spatial index stays small.
+const MaxRadiusInMeter = 500000
+
+// listenerBuffer is the number of notifications queued for a slow WebSocket client before they are dropped.
+const listenerBuffer = 64
+
+// NotFound is given if the requested fence does not exist.
+const NotFound = NotFoundError("fence not found")
+
+type NotFoundError string
+
+func (e NotFoundError) Error() string { return string(e) }
+
+//------------------------------------------------------------------------------
+
+// InvalidArea is given if the area of a fence has no or a too large radius or an invalid position.
+const InvalidArea = InvalidAreaError("invalid area")
+
+type InvalidAreaError string
+
+func (e InvalidAreaError) Error() string { return string(e) }
+
+//------------------------------------------------------------------------------
+
+// Registry keeps all fences in memory with a spatial index and delivers the notifications.
+type Registry struct {
+	store      Store
+	dispatcher *events.Dispatcher
+
+	mu        sync.RWMutex
+	fences    map[string]*Fence
+	index     *gridIndex
+	listeners map[string]map[int]chan events.Event
+	next      int
+}
+
+// NewRegistry creates a Registry with the fences of store. Notifications for fences with webhook are delivered
+// by dispatcher.
+func NewRegistry(ctx context.Context, store Store, dispatcher *events.Dispatcher) (*Registry, error) {
+	r := &Registry{
+		store:      store,
+		dispatcher: dispatcher,
+		fences:     make(map[string]*Fence),
+		index:      newGridIndex(),
+		listeners:  make(map[string]map[int]chan events.Event),
+	}
+	if err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Add validates and stores the fence.
+func (r *Registry) Add(ctx context.Context, fence Fence) error {
+	area := fence.Area
+	if area.RadiusInMeter == 0 || area.RadiusInMeter > MaxRadiusInMeter ||
+		area.Latitude < -90 || area.Latitude > 90 || area.Longitude < -180 || area.Longitude > 180 {
+		return fmt.Errorf("%w: radius must be between 1 and %d m", InvalidArea, MaxRadiusInMeter)
+	}
+
+	if err := r.store.Add(ctx, fence); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(&fence)
+	return nil
+}
+
+// Get returns the fence or NotFound.
+func (r *Registry) Get(id string) (Fence, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fence, ok := r.fences[id]
+	if !ok {
+		return Fence{}, NotFound
+	}
+	return *fence, nil
+}
+
+// List returns the fences of owner.
+func (r *Registry) List(owner string) []Fence {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := []Fence{}
+	for _, fence := range r.fences {
+		if fence.Owner == owner {
+			result = append(result, *fence)
+		}
+	}
+	return result
+}
+
+// Remove deletes the fence and closes its WebSocket listeners.
+func (r *Registry) Remove(ctx context.Context, id string) error {
+	if err := r.store.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fence, ok := r.fences[id]; ok {
+		r.remove(fence)
+	}
+	for _, ch := range r.listeners[id] {
+		close(ch)
+	}
+	delete(r.listeners, id)
+	return nil
+}
+
+// Reload replaces the fences with the ones of the store, e.g. to pick up fences added by other instances.
+func (r *Registry) Reload(ctx context.Context) error {
+	fences, err := r.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fences = make(map[string]*Fence)
+	r.index = newGridIndex()
+	for i := range fences {
+		r.add(&fences[i])
+	}
+	return nil
+}
+
+// RunReload reloads the fences every interval until ctx is done.
+func (r *Registry) RunReload(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(ctx); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("reloading geofences failed")
+			}
+		}
+	}
+}
+
+func (r *Registry) add(fence *Fence) {
+	r.fences[fence.Id] = fence
+	r.index.add(fence)
+}
+
+func (r *Registry) remove(fence *Fence) {
+	r.index.remove(fence)
+	delete(r.fences, fence.Id)
+}
+
+// matching returns the fences that contain poi.
+func (r *Registry) matching(poi *data.Poi) []Fence {
+	if poi == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []Fence
+	for _, fence := range r.index.candidates(poi.Latitude, poi.Longitude) {
+		if fence.contains(poi) {
+			result = append(result, *fence)
+		}
+	}
+	return result
+}
+
+// Listen returns a channel that receives the notifications of the fence. The returned function ends the
+// subscription. The channel is closed if the fence is removed.
+func (r *Registry) Listen(fenceId string) (<-chan events.Event, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.next
+	r.next++
+	ch := make(chan events.Event, listenerBuffer)
+	if r.listeners[fenceId] == nil {
+		r.listeners[fenceId] = make(map[int]chan events.Event)
+	}
+	r.listeners[fenceId][id] = ch
+
+	return ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.listeners[fenceId][id]; ok {
+			delete(r.listeners[fenceId], id)
+			close(ch)
+		}
+	}
+}
+
+// Changed notifies the fences that poi with poiId entered or left because of change. before and after are the
+// states of the poi, nil if it did not exist.
+func (r *Registry) Changed(poiId string, change events.Type, before, after *data.Poi) {
+	inBefore := make(map[string]Fence)
+	for _, fence := range r.matching(before) {
+		inBefore[fence.Id] = fence
+	}
+	inAfter := make(map[string]Fence)
+	for _, fence := range r.matching(after) {
+		inAfter[fence.Id] = fence
+	}
+
+	for id, fence := range inAfter {
+		if _, ok := inBefore[id]; !ok {
+			r.notify(fence, r.newNotification(events.Entered, change, poiId, after, id))
+		}
+	}
+	for id, fence := range inBefore {
+		if _, ok := inAfter[id]; !ok {
+			// the last known position is reported, it is inside the fence
+			r.notify(fence, r.newNotification(events.Exited, change, poiId, before, id))
+		}
+	}
+}
+
+func (r *Registry) newNotification(eventType, change events.Type, poiId string, poi *data.Poi, fenceId string) events.Event {
+	event := events.NewEvent(eventType, poiId, poi)
+	event.FenceId = fenceId
+	event.Reason = change
+	return event
+}
+
+func (r *Registry) notify(fence Fence, event events.Event) {
+	r.mu.RLock()
+	for _, ch := range r.listeners[fence.Id] {
+		select {
+		case ch <- event:
+		default:
+			log.Warn().Str("fence", fence.Id).Str("event", event.Id).Msg("geofence listener too slow, notification dropped")
+		}
+	}
+	r.mu.RUnlock()
+
+	if fence.WebhookUrl != "" && r.dispatcher != nil {
+		subscription := events.Subscription{Id: fence.Id, Url: fence.WebhookUrl, Secret: fence.Secret}
+		go r.dispatcher.Deliver(context.Background(), subscription, event)
+	}
+}
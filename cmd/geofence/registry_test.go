@@ -0,0 +1,95 @@
+package geofence
+
+import (
+	"context"
+	"poi-service/cmd/data"
+	"poi-service/cmd/events"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	dresdenArea = data.SearchArea{Latitude: 51.050407, Longitude: 13.737262, RadiusInMeter: 20000}
+	inDresden   = &data.Poi{Name: "Frauenkirche", Latitude: 51.0519, Longitude: 13.7416}
+	inBerlin    = &data.Poi{Name: "Brandenburger Tor", Latitude: 52.5163, Longitude: 13.3777}
+)
+
+func newTestRegistry(t *testing.T) (*Registry, Fence) {
+	registry, err := NewRegistry(context.Background(), NewMemoryStore(), nil)
+	require.NoError(t, err)
+	fence, err := NewFence(dresdenArea, "fleet", "")
+	require.NoError(t, err)
+	require.NoError(t, registry.Add(context.Background(), fence))
+	return registry, fence
+}
+
+func receive(t *testing.T, ch <-chan events.Event) events.Event {
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		require.Fail(t, "notification missing")
+	}
+	return events.Event{}
+}
+
+func TestRegistry_Add(t *testing.T) {
+	registry, fence := newTestRegistry(t)
+
+	assert.ErrorIs(t, registry.Add(context.Background(), Fence{Id: "a"}), InvalidArea, "no radius")
+	assert.ErrorIs(t, registry.Add(context.Background(), Fence{Id: "b", Area: data.SearchArea{RadiusInMeter: MaxRadiusInMeter + 1}}), InvalidArea)
+
+	found, err := registry.Get(fence.Id)
+	require.NoError(t, err)
+	assert.Equal(t, fence, found)
+	assert.Len(t, registry.List("fleet"), 1)
+	assert.Empty(t, registry.List("other"))
+
+	// a new registry loads the fences of the store
+	reloaded, err := NewRegistry(context.Background(), registry.store, nil)
+	require.NoError(t, err)
+	_, err = reloaded.Get(fence.Id)
+	assert.NoError(t, err)
+}
+
+func TestRegistry_Changed(t *testing.T) {
+	registry, fence := newTestRegistry(t)
+	notifications, cancel := registry.Listen(fence.Id)
+	defer cancel()
+
+	registry.Changed("a", events.Created, nil, inDresden)
+	event := receive(t, notifications)
+	assert.Equal(t, events.Entered, event.Type)
+	assert.Equal(t, events.Created, event.Reason)
+	assert.Equal(t, fence.Id, event.FenceId)
+	assert.Equal(t, "a", event.PoiId)
+
+	// moving inside the fence or outside of it is no transition
+	registry.Changed("a", events.Updated, inDresden, inDresden)
+	registry.Changed("b", events.Created, nil, inBerlin)
+	assert.Empty(t, notifications)
+
+	registry.Changed("b", events.Updated, inBerlin, inDresden)
+	event = receive(t, notifications)
+	assert.Equal(t, events.Entered, event.Type)
+	assert.Equal(t, events.Updated, event.Reason)
+
+	registry.Changed("a", events.Updated, inDresden, inBerlin)
+	event = receive(t, notifications)
+	assert.Equal(t, events.Exited, event.Type)
+	assert.Equal(t, "Frauenkirche", event.Poi.Name, "last position inside the fence")
+
+	registry.Changed("b", events.Deleted, inDresden, nil)
+	event = receive(t, notifications)
+	assert.Equal(t, events.Exited, event.Type)
+	assert.Equal(t, events.Deleted, event.Reason)
+
+	// removing the fence ends the listeners
+	require.NoError(t, registry.Remove(context.Background(), fence.Id))
+	_, open := <-notifications
+	assert.False(t, open)
+	assert.ErrorIs(t, registry.Remove(context.Background(), fence.Id), NotFound)
+}
@@ -0,0 +1,94 @@
+package geofence
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sync"
+)
+
+// Store persists the fences.
+type Store interface {
+	Add(ctx context.Context, fence Fence) error
+	List(ctx context.Context) ([]Fence, error)
+	// Delete removes the fence or returns NotFound.
+	Delete(ctx context.Context, id string) error
+}
+
+//------------------------------------------------------------------------------
+
+// NewMemoryStore creates a Store that keeps the fences in memory only, e.g. for tests.
+func NewMemoryStore() Store {
+	return &memoryStore{fences: make(map[string]Fence)}
+}
+
+// memoryStore implements interface Store
+type memoryStore struct {
+	mu     sync.RWMutex
+	fences map[string]Fence
+}
+
+func (m *memoryStore) Add(_ context.Context, fence Fence) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fences[fence.Id] = fence
+	return nil
+}
+
+func (m *memoryStore) List(_ context.Context) ([]Fence, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]Fence, 0, len(m.fences))
+	for _, fence := range m.fences {
+		result = append(result, fence)
+	}
+	return result, nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.fences[id]; !ok {
+		return NotFound
+	}
+	delete(m.fences, id)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoStore creates a Store that persists the fences in collection.
+func NewMongoStore(collection *mongo.Collection) Store {
+	return &mongoStore{collection: collection}
+}
+
+// mongoStore implements interface Store
+type mongoStore struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoStore) Add(ctx context.Context, fence Fence) error {
+	_, err := m.collection.InsertOne(ctx, fence)
+	return err
+}
+
+func (m *mongoStore) List(ctx context.Context) (result []Fence, err error) {
+	cur, err := m.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return
+	}
+	result = []Fence{}
+	err = cur.All(ctx, &result)
+	return
+}
+
+func (m *mongoStore) Delete(ctx context.Context, id string) error {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return NotFound
+	}
+	return nil
+}
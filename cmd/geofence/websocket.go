@@ -0,0 +1,76 @@
+package geofence
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"time"
+)
+
+const (
+	// writeWait is the time allowed to write a message to the client.
+	writeWait = 10 * time.Second
+	// pongWait is the time allowed to read the next pong from the client.
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait.
+	pingPeriod = pongWait * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// ServeWebSocket upgrades the request and sends the notifications of the fence as JSON messages until the client
+// disconnects or the fence is removed. The caller must have checked that the client may read the fence.
+func (r *Registry) ServeWebSocket(w http.ResponseWriter, req *http.Request, fenceId string) {
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// the upgrader already responded with an error
+		log.Ctx(req.Context()).Warn().Err(err).Msg("websocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	notifications, cancel := r.Listen(fenceId)
+	defer cancel()
+
+	// the client only sends control messages, reading is needed to process pongs and close messages
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case event, ok := <-notifications:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "fence removed"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				log.Ctx(req.Context()).Warn().Err(err).Str("fence", fenceId).Msg("writing geofence notification failed")
+				return
+			}
+		}
+	}
+}
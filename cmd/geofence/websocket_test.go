@@ -0,0 +1,41 @@
+package geofence
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/events"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_ServeWebSocket(t *testing.T) {
+	registry, fence := newTestRegistry(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registry.ServeWebSocket(w, r, fence.Id)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// wait until the listener is registered
+	assert.Eventually(t, func() bool {
+		registry.mu.RLock()
+		defer registry.mu.RUnlock()
+		return len(registry.listeners[fence.Id]) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	registry.Changed("a", events.Created, nil, inDresden)
+
+	var event events.Event
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	require.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, events.Entered, event.Type)
+	assert.Equal(t, fence.Id, event.FenceId)
+}
@@ -2,29 +2,98 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.mongodb.org/mongo-driver/x/bsonx"
-	"gopkg.in/mgo.v2"
-	"gopkg.in/mgo.v2/bson"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"poi-service/cmd/data"
+	"time"
 )
 
+// DbHandler stores the pois. Deleted pois are only marked as deleted, they are ignored by all operations except
+// the ones for deleted pois until they are purged.
 type DbHandler interface {
-	AddPoi(poi PoiDbEntry) (id string, err error)
-	GetPoi(id string) (poi PoiDbEntry, err error)
-	UpdatePoi(id string, poi PoiDbEntry) (err error)
-	DeletePoi(id string) (err error)
-	SearchByRadius(location Location, distanceInMeter uint64) (result PoiDbEntries, err error)
-	GetAllPois() (result PoiDbEntries, err error)
+	AddPoi(ctx context.Context, poi PoiDbEntry) (id string, err error)
+	// GetPoi returns the poi or PoiNotFound.
+	GetPoi(ctx context.Context, id string) (poi PoiDbEntry, err error)
+	// UpdatePoi changes the poi or returns PoiNotFound.
+	UpdatePoi(ctx context.Context, id string, poi PoiDbEntry) (err error)
+	// DeletePoi marks the poi as deleted or returns PoiNotFound.
+	DeletePoi(ctx context.Context, id string) (err error)
+	// SearchByRadius returns the pois within distanceInMeter of location, the nearest first. Only the first limit pois
+	// are returned if limit is greater than 0.
+	SearchByRadius(ctx context.Context, location Location, distanceInMeter uint64, limit int64) (result PoiDbEntries, err error)
+	GetAllPois(ctx context.Context) (result PoiDbEntries, err error)
+	// GetDeletedPois returns all pois marked as deleted.
+	GetDeletedPois(ctx context.Context) (result PoiDbEntries, err error)
+	// RestorePoi removes the deleted mark or returns PoiNotFound if there is no deleted poi with id.
+	RestorePoi(ctx context.Context, id string) (err error)
+	// PurgeDeletedPois finally removes all pois deleted before the given time including their revisions.
+	PurgeDeletedPois(ctx context.Context, deletedBefore time.Time) (purged int64, err error)
+	// GetRevisions returns all stored versions of the poi ordered by revision or PoiNotFound.
+	GetRevisions(ctx context.Context, id string) (result PoiRevisions, err error)
+	// GetRevision returns a single version of the poi or RevisionNotFound.
+	GetRevision(ctx context.Context, id string, revision int) (result PoiRevision, err error)
+	// GetPoiAsOf returns the version of the poi that was valid at the given time or PoiNotFound if the poi did not
+	// exist at that time.
+	GetPoiAsOf(ctx context.Context, id string, asOf time.Time) (result PoiRevision, err error)
+	// GetPoiByExternalId returns the poi with the external id of source or PoiNotFound.
+	GetPoiByExternalId(ctx context.Context, source, externalId string) (poi PoiDbEntry, err error)
+	// UpsertPoi adds the poi if there is none with its source and external id yet, otherwise the existing poi is
+	// updated. It returns the id of the poi or PoiInTrash if the existing poi is deleted.
+	UpsertPoi(ctx context.Context, poi PoiDbEntry) (id string, created bool, err error)
+	// MergePoi marks the poi merge as deleted and moves its source and external id to the poi keep. It returns
+	// ExternalIdConflict if both pois have an external id and PoiNotFound if one of them does not exist. Merging a
+	// poi into the same poi again changes nothing.
+	MergePoi(ctx context.Context, keep, merge string) (err error)
 }
 
-func NewDbHandler(url string) (DbHandler, error) {
+// PoiNotFound is given if there is no (not deleted) poi with the requested id.
+const PoiNotFound = PoiNotFoundError("poi not found")
+
+type PoiNotFoundError string
+
+func (e PoiNotFoundError) Error() string { return string(e) }
+
+// RevisionNotFound is given if the poi has no revision with the requested number.
+const RevisionNotFound = PoiNotFoundError("revision not found")
+
+// ExternalIdTaken is given if a poi with the same source and external id already exists.
+const ExternalIdTaken = ConflictError("external id already used")
+
+// externalIdIndex is the name of the unique index of source and external id.
+const externalIdIndex = "source_1_externalId_1"
+
+// duplicateKeyCode is the code of the error given by Mongo if a unique index is violated.
+const duplicateKeyCode = 11000
+
+// PoiInTrash is given if the poi to upsert is deleted, it has to be restored first.
+const PoiInTrash = ConflictError("poi is in the trash")
+
+// ExternalIdConflict is given if pois are merged that both have an external id, only one of them can be kept.
+const ExternalIdConflict = ConflictError("both pois have an external id")
+
+type ConflictError string
+
+func (e ConflictError) Error() string { return string(e) }
+
+// DbName is the name of the mongodb database used by the service, if not set by WithDatabase.
+const DbName = "poiDb"
+
+// PoiCollection is the name of the collection that contains the pois, if not set by WithDatabase.
+const PoiCollection = "poi"
+
+// NewMongoClient connects to the mongodb at url and checks that it is reachable. Every command is recorded as span
+// of the trace in its context.
+func NewMongoClient(url string) (*mongo.Client, error) {
 	log.Info().Str("url", url).Msg("db connection")
 
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(url))
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(url).SetMonitor(otelmongo.NewMonitor()))
 	if err != nil {
 		log.Error().Err(err).Str("url", url).Msg("Connecting to mongodb failed")
 		return nil, err
@@ -37,32 +106,107 @@ func NewDbHandler(url string) (DbHandler, error) {
 	}
 
 	log.Info().Str("url", url).Msg("Connecting to mongodb done")
+	return client, nil
+}
+
+// NewDbHandler connects to the mongodb at url and creates a DbHandler for it.
+func NewDbHandler(url string, opts ...DbHandlerOption) (DbHandler, error) {
+	client, err := NewMongoClient(url)
+	if err != nil {
+		return nil, err
+	}
 
+	return NewDbHandlerWithClient(client, opts...), nil
+}
+
+// NewDbHandlerWithClient creates a DbHandler that uses an already connected client.
+func NewDbHandlerWithClient(client *mongo.Client, opts ...DbHandlerOption) DbHandler {
 	handler := &dbHandler{
-		dbClient:   client,
-		dbName:     "poiDb",
-		collection: "poi",
+		dbClient:           client,
+		dbName:             DbName,
+		collection:         PoiCollection,
+		revisionCollection: "poiRevisions",
+	}
+	for _, opt := range opts {
+		opt(handler)
 	}
 
 	handler.createIndex()
 
-	return handler, nil
+	return handler
+}
+
+// DbHandlerOption configures optional features of the DbHandler.
+type DbHandlerOption func(c *dbHandler)
+
+// WithDatabase stores the pois in collection of the database name instead of PoiCollection of DbName. The other
+// collections are created in the same database.
+func WithDatabase(name, collection string) DbHandlerOption {
+	return func(c *dbHandler) {
+		c.dbName = name
+		c.collection = collection
+	}
 }
 
 type dbHandler struct {
-	dbClient   *mongo.Client
-	dbName     string
-	collection string
+	dbClient           *mongo.Client
+	dbName             string
+	collection         string
+	revisionCollection string
+	// outboxCollection is empty if the outbox is disabled
+	outboxCollection string
 }
 
 type PoiDbEntry struct {
 	Id       string   `json:"id" bson:"_id"`
 	Name     string   `json:"name" bson:"name"`
 	Location Location `json:"location" bson:"location"`
+	// Source and ExternalId identify the poi at its origin, they are unique if set.
+	Source     string `json:"source,omitempty" bson:"source,omitempty"`
+	ExternalId string `json:"externalId,omitempty" bson:"externalId,omitempty"`
+	// DeletedAt is set if the poi is deleted.
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	// MergedInto is the id of the poi a deleted poi was merged into.
+	MergedInto string `json:"mergedInto,omitempty" bson:"mergedInto,omitempty"`
+	// Revision is incremented with every change, starting with 1.
+	Revision  int       `json:"revision" bson:"revision"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
 }
 
 type PoiDbEntries []PoiDbEntry
 
+// Poi converts the entry to the representation of the api.
+func (e *PoiDbEntry) Poi() data.Poi {
+	return data.Poi{
+		Name:       e.Name,
+		Latitude:   e.Location.Latitude(),
+		Longitude:  e.Location.Longitude(),
+		Source:     e.Source,
+		ExternalId: e.ExternalId,
+	}
+}
+
+// PoiRevision is a version of a poi. Every version is valid from its creation until the next revision.
+type PoiRevision struct {
+	PoiId     string    `json:"poiId" bson:"poiId"`
+	Revision  int       `json:"revision" bson:"revision"`
+	Name      string    `json:"name" bson:"name"`
+	Location  Location  `json:"location" bson:"location"`
+	ValidFrom time.Time `json:"validFrom" bson:"validFrom"`
+}
+
+type PoiRevisions []PoiRevision
+
+func newRevision(poi PoiDbEntry) PoiRevision {
+	return PoiRevision{
+		PoiId:     poi.Id,
+		Revision:  poi.Revision,
+		Name:      poi.Name,
+		Location:  poi.Location,
+		ValidFrom: poi.UpdatedAt,
+	}
+}
+
 // We need this type so we can store it in our mongodb db and do geospatial queries
 // https://docs.mongodb.com/manual/geospatial-queries/
 type Location struct {
@@ -77,86 +221,378 @@ func NewLocation(lat, long float64) Location {
 	}
 }
 
+// Latitude returns the latitude, GeoJSON stores the coordinates as longitude, latitude.
+func (l Location) Latitude() float64 {
+	return l.Coordinates[1]
+}
+
+// Longitude returns the longitude, GeoJSON stores the coordinates as longitude, latitude.
+func (l Location) Longitude() float64 {
+	return l.Coordinates[0]
+}
+
 func (c *dbHandler) getMongoDbCollection() (collection *mongo.Collection) {
 	collection = c.dbClient.Database(c.dbName).Collection(c.collection)
 	return
 }
 
-func (c *dbHandler) AddPoi(poi PoiDbEntry) (id string, err error) {
-	insertResult, err := c.getMongoDbCollection().InsertOne(context.TODO(), poi)
+func (c *dbHandler) getRevisionCollection() *mongo.Collection {
+	return c.dbClient.Database(c.dbName).Collection(c.revisionCollection)
+}
+
+func (c *dbHandler) AddPoi(ctx context.Context, poi PoiDbEntry) (id string, err error) {
+	poi.Revision = 1
+	poi.UpdatedAt = time.Now().UTC()
+	var insertResult *mongo.InsertOneResult
+	err = c.inTransaction(ctx, func(ctx context.Context) (err error) {
+		insertResult, err = c.getMongoDbCollection().InsertOne(ctx, poi)
+		if err != nil {
+			return
+		}
+		if err = c.addRevision(ctx, poi); err != nil {
+			return
+		}
+		return c.addOutboxEntry(ctx, PoiCreated, poi)
+	})
+	if isExternalIdTaken(err) {
+		return "", ExternalIdTaken
+	}
 	if err != nil {
-		log.Printf("Could not insert new Point. Id")
+		log.Ctx(ctx).Warn().Err(err).Str("poi id", poi.Id).Msg("inserting poi failed")
 		return "", err
 	}
 	id = poi.Id
-	log.Info().Str("poi id", poi.Id).Msg("Inserted new Point")
-	log.Info().Str("id", fmt.Sprint(insertResult.InsertedID)).Msg("created unique id")
+	log.Ctx(ctx).Info().Str("poi id", poi.Id).Msg("Inserted new Point")
+	log.Ctx(ctx).Info().Str("id", fmt.Sprint(insertResult.InsertedID)).Msg("created unique id")
 
 	return
 }
 
-func (c *dbHandler) GetPoi(id string) (poi PoiDbEntry, err error) {
-	filter := bson.M{"_id": bson.M{"$eq": id}}
-	if err = c.getMongoDbCollection().FindOne(context.TODO(), filter).Decode(&poi); err != nil {
-		//fmt.Println(err)
-		return
+// isExternalIdTaken returns true if err violates the unique index of the external ids. Other duplicate keys, e.g. of
+// the id or the revisions, are no conflicts of the caller.
+func isExternalIdTaken(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCodeWithMessage(duplicateKeyCode, "index: "+externalIdIndex+" ")
+}
+
+// notDeleted extends filter to ignore deleted pois.
+func notDeleted(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": false}
+	return filter
+}
+
+func (c *dbHandler) GetPoi(ctx context.Context, id string) (poi PoiDbEntry, err error) {
+	filter := notDeleted(bson.M{"_id": bson.M{"$eq": id}})
+	err = c.getMongoDbCollection().FindOne(ctx, filter).Decode(&poi)
+	if err == mongo.ErrNoDocuments {
+		err = PoiNotFound
+	}
+	return
+}
+
+func (c *dbHandler) GetPoiByExternalId(ctx context.Context, source, externalId string) (poi PoiDbEntry, err error) {
+	filter := notDeleted(bson.M{"source": source, "externalId": externalId})
+	err = c.getMongoDbCollection().FindOne(ctx, filter).Decode(&poi)
+	if err == mongo.ErrNoDocuments {
+		err = PoiNotFound
 	}
 	return
 }
 
-func (c *dbHandler) GetAllPois() (result PoiDbEntries, err error) {
-	cur, err := c.getMongoDbCollection().Find(context.TODO(), bson.M{})
+func (c *dbHandler) UpsertPoi(ctx context.Context, poi PoiDbEntry) (id string, created bool, err error) {
+	// the poi is matched and added by the unique index in a single operation, so concurrent upserts can not add it
+	// twice. A deleted poi is not matched, adding it again violates the index.
+	filter := notDeleted(bson.M{"source": poi.Source, "externalId": poi.ExternalId})
+	update := bson.M{
+		"$set":         bson.M{"name": poi.Name, "location": poi.Location, "updatedAt": time.Now().UTC()},
+		"$inc":         bson.M{"revision": 1},
+		"$setOnInsert": bson.M{"_id": poi.Id},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	// a poi added concurrently by another request is not matched in the first attempt but in the second
+	for attempt := 0; attempt < 2; attempt++ {
+		var upserted PoiDbEntry
+		err = c.inTransaction(ctx, func(ctx context.Context) error {
+			err := c.getMongoDbCollection().FindOneAndUpdate(ctx, filter, update, opts).Decode(&upserted)
+			if err != nil {
+				return err
+			}
+			if err = c.addRevision(ctx, upserted); err != nil {
+				return err
+			}
+			change := PoiUpdated
+			if upserted.Id == poi.Id {
+				change = PoiCreated
+			}
+			return c.addOutboxEntry(ctx, change, upserted)
+		})
+		if isExternalIdTaken(err) {
+			continue
+		}
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("source", poi.Source).Str("external id", poi.ExternalId).Msg("upserting poi failed")
+			return "", false, err
+		}
+		return upserted.Id, upserted.Id == poi.Id, nil
+	}
+	// the poi with the external id is not matched because it is deleted
+	return "", false, PoiInTrash
+}
+
+func (c *dbHandler) MergePoi(ctx context.Context, keep, merge string) (err error) {
+	return c.inTransaction(ctx, func(ctx context.Context) error {
+		var merged PoiDbEntry
+		err := c.getMongoDbCollection().FindOne(ctx, bson.M{"_id": merge}).Decode(&merged)
+		if err == mongo.ErrNoDocuments {
+			return PoiNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if merged.DeletedAt != nil {
+			// the merge is repeated, e.g. because the caller did not get the result
+			if merged.MergedInto == keep {
+				return nil
+			}
+			return PoiNotFound
+		}
+		kept, err := c.GetPoi(ctx, keep)
+		if err != nil {
+			return err
+		}
+		if merged.ExternalId != "" && kept.ExternalId != "" {
+			return ExternalIdConflict
+		}
+
+		// the external id is removed first, so the unique index is not violated while both pois carry it
+		now := time.Now().UTC()
+		deleted, err := c.updateOne(ctx, notDeleted(bson.M{"_id": merge}), bson.M{
+			"$set":   bson.M{"deletedAt": now, "mergedInto": keep},
+			"$unset": bson.M{"source": "", "externalId": ""},
+		})
+		if err != nil {
+			return err
+		}
+		if err = c.addOutboxEntry(ctx, PoiDeleted, deleted); err != nil {
+			return err
+		}
+		if merged.ExternalId == "" {
+			return nil
+		}
+
+		updated, err := c.updateOne(ctx, notDeleted(bson.M{"_id": keep, "externalId": bson.M{"$exists": false}}), bson.M{
+			"$set": bson.M{"source": merged.Source, "externalId": merged.ExternalId, "updatedAt": now},
+			"$inc": bson.M{"revision": 1},
+		})
+		if err != nil {
+			return err
+		}
+		if err = c.addRevision(ctx, updated); err != nil {
+			return err
+		}
+		return c.addOutboxEntry(ctx, PoiUpdated, updated)
+	})
+}
+
+func (c *dbHandler) GetAllPois(ctx context.Context) (result PoiDbEntries, err error) {
+	return c.find(ctx, notDeleted(bson.M{}))
+}
+
+func (c *dbHandler) GetDeletedPois(ctx context.Context) (result PoiDbEntries, err error) {
+	return c.find(ctx, bson.M{"deletedAt": bson.M{"$exists": true}})
+}
+
+func (c *dbHandler) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) (result PoiDbEntries, err error) {
+	cur, err := c.getMongoDbCollection().Find(ctx, filter, opts...)
 	if err != nil {
-		log.Warn().Err(err).Msg("GetAllPois failed")
+		log.Ctx(ctx).Warn().Err(err).Msg("finding pois failed")
 		return
 	}
+	defer cur.Close(ctx)
 
-	for cur.Next(context.TODO()) {
+	for cur.Next(ctx) {
 		//Create a value into which the single document can be decoded
 		var elem PoiDbEntry
 		err := cur.Decode(&elem)
 		if err != nil {
-			log.Warn().Err(err).Msg("GetAllPois failed")
+			log.Ctx(ctx).Warn().Err(err).Msg("decoding poi failed")
+			continue
 		}
 
 		result = append(result, elem)
 	}
 
-	return
+	return result, cur.Err()
 }
 
-func (c *dbHandler) UpdatePoi(id string, poi PoiDbEntry) (err error) {
-	filter := bson.M{"_id": bson.M{"$eq": id}}
+func (c *dbHandler) UpdatePoi(ctx context.Context, id string, poi PoiDbEntry) (err error) {
+	filter := notDeleted(bson.M{"_id": bson.M{"$eq": id}})
 	update := bson.M{
-		"$set": bson.M{"name": poi.Name, "location": poi.Location},
+		"$set": bson.M{"name": poi.Name, "location": poi.Location, "updatedAt": time.Now().UTC()},
+		"$inc": bson.M{"revision": 1},
+	}
+
+	return c.inTransaction(ctx, func(ctx context.Context) error {
+		// the incremented revision is needed for the history
+		updated, err := c.updateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		if err = c.addRevision(ctx, updated); err != nil {
+			return err
+		}
+		return c.addOutboxEntry(ctx, PoiUpdated, updated)
+	})
+}
+
+// addRevision stores a version of poi in the history. Without outbox a failure only leaves a gap in the history, the
+// change itself is already done. Within the transaction of the outbox the failure aborts the change.
+func (c *dbHandler) addRevision(ctx context.Context, poi PoiDbEntry) error {
+	_, err := c.getRevisionCollection().InsertOne(ctx, newRevision(poi))
+	if err == nil {
+		return nil
+	}
+	log.Ctx(ctx).Error().Err(err).Str("poi id", poi.Id).Int("revision", poi.Revision).Msg("storing revision failed")
+	if c.outboxCollection == "" {
+		return nil
+	}
+	return err
+}
+
+func (c *dbHandler) GetRevisions(ctx context.Context, id string) (result PoiRevisions, err error) {
+	cur, err := c.getRevisionCollection().Find(ctx, bson.M{"poiId": id},
+		options.Find().SetSort(bson.M{"revision": 1}))
+	if err != nil {
+		return
+	}
+	if err = cur.All(ctx, &result); err != nil {
+		return
+	}
+	if len(result) == 0 {
+		return nil, PoiNotFound
+	}
+	return
+}
+
+func (c *dbHandler) GetRevision(ctx context.Context, id string, revision int) (result PoiRevision, err error) {
+	err = c.getRevisionCollection().FindOne(ctx, bson.M{"poiId": id, "revision": revision}).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		err = RevisionNotFound
+	}
+	return
+}
+
+func (c *dbHandler) GetPoiAsOf(ctx context.Context, id string, asOf time.Time) (result PoiRevision, err error) {
+	// a poi deleted before asOf did not exist at that time
+	var current PoiDbEntry
+	err = c.getMongoDbCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		return result, PoiNotFound
+	}
+	if err != nil {
+		return
+	}
+	if current.DeletedAt != nil && !current.DeletedAt.After(asOf) {
+		return result, PoiNotFound
 	}
-	_, err = c.getMongoDbCollection().UpdateOne(
-		context.Background(),
-		filter,
-		update,
-	)
 
+	filter := bson.M{"poiId": id, "validFrom": bson.M{"$lte": asOf}}
+	err = c.getRevisionCollection().FindOne(ctx, filter,
+		options.FindOne().SetSort(bson.M{"revision": -1})).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		err = PoiNotFound
+	}
 	return
 }
 
-func (c *dbHandler) DeletePoi(id string) (err error) {
-	filter := bson.M{"_id": bson.M{"$eq": id}}
-	_, err = c.getMongoDbCollection().DeleteOne(context.TODO(), filter)
+func (c *dbHandler) DeletePoi(ctx context.Context, id string) (err error) {
+	filter := notDeleted(bson.M{"_id": bson.M{"$eq": id}})
+	update := bson.M{
+		"$set": bson.M{"deletedAt": time.Now().UTC()},
+	}
+	return c.inTransaction(ctx, func(ctx context.Context) error {
+		deleted, err := c.updateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		return c.addOutboxEntry(ctx, PoiDeleted, deleted)
+	})
+}
+
+func (c *dbHandler) RestorePoi(ctx context.Context, id string) (err error) {
+	filter := bson.M{"_id": bson.M{"$eq": id}, "deletedAt": bson.M{"$exists": true}}
+	update := bson.M{
+		"$unset": bson.M{"deletedAt": "", "mergedInto": ""},
+	}
+	return c.inTransaction(ctx, func(ctx context.Context) error {
+		restored, err := c.updateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		return c.addOutboxEntry(ctx, PoiRestored, restored)
+	})
+}
+
+// updateOne applies update to the poi matching filter and returns the updated poi or PoiNotFound.
+func (c *dbHandler) updateOne(ctx context.Context, filter, update bson.M) (updated PoiDbEntry, err error) {
+	err = c.getMongoDbCollection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		err = PoiNotFound
+	}
 	return
 }
 
-func (c *dbHandler) SearchByRadius(location Location, distanceInMeter uint64) (result PoiDbEntries, err error) {
-	// connect to mongo
-	session, err := mgo.Dial("localhost")
+func (c *dbHandler) PurgeDeletedPois(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	filter := bson.M{"deletedAt": bson.M{"$lt": deletedBefore}}
+	pois, err := c.find(ctx, filter)
+	if err != nil || len(pois) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(pois))
+	for _, poi := range pois {
+		ids = append(ids, poi.Id)
+	}
+
+	// the filter is repeated, so a poi restored in the meantime is kept
+	res, err := c.getMongoDbCollection().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "deletedAt": filter["deletedAt"]})
+	if err != nil {
+		return
+	}
+
+	// the history of restored pois must be kept as well
+	kept, err := c.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
 	if err != nil {
-		log.Fatal().Err(err).Msg("could not connect to db")
+		return res.DeletedCount, err
+	}
+	purgedIds := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !containsPoi(kept, id) {
+			purgedIds = append(purgedIds, id)
+		}
+	}
+
+	_, err = c.getRevisionCollection().DeleteMany(ctx, bson.M{"poiId": bson.M{"$in": purgedIds}})
+	return res.DeletedCount, err
+}
+
+func containsPoi(pois PoiDbEntries, id string) bool {
+	for _, poi := range pois {
+		if poi.Id == id {
+			return true
+		}
 	}
-	defer session.Close()
-	session.SetMode(mgo.Monotonic, true)
+	return false
+}
 
-	// query the database
-	col := session.DB(c.dbName).C(c.collection)
-	err = col.Find(bson.M{
+func (c *dbHandler) SearchByRadius(ctx context.Context, location Location, distanceInMeter uint64, limit int64) (result PoiDbEntries, err error) {
+	opts := options.Find()
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	return c.find(ctx, notDeleted(bson.M{
 		"location": bson.M{
 			"$nearSphere": bson.M{
 				"$geometry": bson.M{
@@ -166,12 +602,7 @@ func (c *dbHandler) SearchByRadius(location Location, distanceInMeter uint64) (r
 				"$maxDistance": distanceInMeter,
 			},
 		},
-	}).All(&result)
-	if err != nil {
-		panic(err)
-	}
-
-	return result, nil
+	}), opts)
 }
 
 func (c *dbHandler) createIndex() (err error) {
@@ -179,6 +610,38 @@ func (c *dbHandler) createIndex() (err error) {
 		Keys: bsonx.MDoc{"location": bsonx.String("2dsphere")},
 	}
 
-	_, err = c.getMongoDbCollection().Indexes().CreateOne(context.TODO(), pointIndexModel)
+	// the purge looks for deleted pois
+	deletedIndexModel := mongo.IndexModel{
+		Keys:    bsonx.MDoc{"deletedAt": bsonx.Int32(1)},
+		Options: options.Index().SetSparse(true),
+	}
+
+	// deleted pois keep their external id, so they can be restored
+	externalIdIndexModel := mongo.IndexModel{
+		Keys: bsonx.Doc{{Key: "source", Value: bsonx.Int32(1)}, {Key: "externalId", Value: bsonx.Int32(1)}},
+		Options: options.Index().SetName(externalIdIndex).SetUnique(true).
+			SetPartialFilterExpression(bson.M{"externalId": bson.M{"$exists": true}}),
+	}
+
+	_, err = c.getMongoDbCollection().Indexes().CreateMany(context.TODO(),
+		[]mongo.IndexModel{pointIndexModel, deletedIndexModel, externalIdIndexModel})
+	if err != nil {
+		return
+	}
+
+	revisionIndexModel := mongo.IndexModel{
+		Keys:    bsonx.Doc{{Key: "poiId", Value: bsonx.Int32(1)}, {Key: "revision", Value: bsonx.Int32(1)}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = c.getRevisionCollection().Indexes().CreateOne(context.TODO(), revisionIndexModel)
+	if err != nil || c.outboxCollection == "" {
+		return
+	}
+
+	// the relay publishes the oldest entries first
+	outboxIndexModel := mongo.IndexModel{
+		Keys: bsonx.MDoc{"createdAt": bsonx.Int32(1)},
+	}
+	_, err = c.getOutboxCollection().Indexes().CreateOne(context.TODO(), outboxIndexModel)
 	return
 }
@@ -5,7 +5,9 @@
 package handler
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,89 +36,223 @@ func (m *MockDbHandler) EXPECT() *MockDbHandlerMockRecorder {
 }
 
 // AddPoi mocks base method.
-func (m *MockDbHandler) AddPoi(poi PoiDbEntry) (string, error) {
+func (m *MockDbHandler) AddPoi(ctx context.Context, poi PoiDbEntry) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddPoi", poi)
+	ret := m.ctrl.Call(m, "AddPoi", ctx, poi)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddPoi indicates an expected call of AddPoi.
-func (mr *MockDbHandlerMockRecorder) AddPoi(poi interface{}) *gomock.Call {
+func (mr *MockDbHandlerMockRecorder) AddPoi(ctx, poi interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPoi", reflect.TypeOf((*MockDbHandler)(nil).AddPoi), poi)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPoi", reflect.TypeOf((*MockDbHandler)(nil).AddPoi), ctx, poi)
 }
 
 // DeletePoi mocks base method.
-func (m *MockDbHandler) DeletePoi(id string) error {
+func (m *MockDbHandler) DeletePoi(ctx context.Context, id string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePoi", id)
+	ret := m.ctrl.Call(m, "DeletePoi", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeletePoi indicates an expected call of DeletePoi.
-func (mr *MockDbHandlerMockRecorder) DeletePoi(id interface{}) *gomock.Call {
+func (mr *MockDbHandlerMockRecorder) DeletePoi(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePoi", reflect.TypeOf((*MockDbHandler)(nil).DeletePoi), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePoi", reflect.TypeOf((*MockDbHandler)(nil).DeletePoi), ctx, id)
 }
 
 // GetAllPois mocks base method.
-func (m *MockDbHandler) GetAllPois() (PoiDbEntries, error) {
+func (m *MockDbHandler) GetAllPois(ctx context.Context) (PoiDbEntries, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllPois")
+	ret := m.ctrl.Call(m, "GetAllPois", ctx)
 	ret0, _ := ret[0].(PoiDbEntries)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllPois indicates an expected call of GetAllPois.
-func (mr *MockDbHandlerMockRecorder) GetAllPois() *gomock.Call {
+func (mr *MockDbHandlerMockRecorder) GetAllPois(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPois", reflect.TypeOf((*MockDbHandler)(nil).GetAllPois))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPois", reflect.TypeOf((*MockDbHandler)(nil).GetAllPois), ctx)
+}
+
+// GetDeletedPois mocks base method.
+func (m *MockDbHandler) GetDeletedPois(ctx context.Context) (PoiDbEntries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletedPois", ctx)
+	ret0, _ := ret[0].(PoiDbEntries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletedPois indicates an expected call of GetDeletedPois.
+func (mr *MockDbHandlerMockRecorder) GetDeletedPois(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletedPois", reflect.TypeOf((*MockDbHandler)(nil).GetDeletedPois), ctx)
 }
 
 // GetPoi mocks base method.
-func (m *MockDbHandler) GetPoi(id string) (PoiDbEntry, error) {
+func (m *MockDbHandler) GetPoi(ctx context.Context, id string) (PoiDbEntry, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPoi", id)
+	ret := m.ctrl.Call(m, "GetPoi", ctx, id)
 	ret0, _ := ret[0].(PoiDbEntry)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPoi indicates an expected call of GetPoi.
-func (mr *MockDbHandlerMockRecorder) GetPoi(id interface{}) *gomock.Call {
+func (mr *MockDbHandlerMockRecorder) GetPoi(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPoi", reflect.TypeOf((*MockDbHandler)(nil).GetPoi), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPoi", reflect.TypeOf((*MockDbHandler)(nil).GetPoi), ctx, id)
+}
+
+// GetPoiAsOf mocks base method.
+func (m *MockDbHandler) GetPoiAsOf(ctx context.Context, id string, asOf time.Time) (PoiRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPoiAsOf", ctx, id, asOf)
+	ret0, _ := ret[0].(PoiRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPoiAsOf indicates an expected call of GetPoiAsOf.
+func (mr *MockDbHandlerMockRecorder) GetPoiAsOf(ctx, id, asOf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPoiAsOf", reflect.TypeOf((*MockDbHandler)(nil).GetPoiAsOf), ctx, id, asOf)
+}
+
+// GetPoiByExternalId mocks base method.
+func (m *MockDbHandler) GetPoiByExternalId(ctx context.Context, source, externalId string) (PoiDbEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPoiByExternalId", ctx, source, externalId)
+	ret0, _ := ret[0].(PoiDbEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPoiByExternalId indicates an expected call of GetPoiByExternalId.
+func (mr *MockDbHandlerMockRecorder) GetPoiByExternalId(ctx, source, externalId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPoiByExternalId", reflect.TypeOf((*MockDbHandler)(nil).GetPoiByExternalId), ctx, source, externalId)
+}
+
+// GetRevision mocks base method.
+func (m *MockDbHandler) GetRevision(ctx context.Context, id string, revision int) (PoiRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevision", ctx, id, revision)
+	ret0, _ := ret[0].(PoiRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevision indicates an expected call of GetRevision.
+func (mr *MockDbHandlerMockRecorder) GetRevision(ctx, id, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevision", reflect.TypeOf((*MockDbHandler)(nil).GetRevision), ctx, id, revision)
+}
+
+// GetRevisions mocks base method.
+func (m *MockDbHandler) GetRevisions(ctx context.Context, id string) (PoiRevisions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevisions", ctx, id)
+	ret0, _ := ret[0].(PoiRevisions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevisions indicates an expected call of GetRevisions.
+func (mr *MockDbHandlerMockRecorder) GetRevisions(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevisions", reflect.TypeOf((*MockDbHandler)(nil).GetRevisions), ctx, id)
+}
+
+// MergePoi mocks base method.
+func (m *MockDbHandler) MergePoi(ctx context.Context, keep, merge string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MergePoi", ctx, keep, merge)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MergePoi indicates an expected call of MergePoi.
+func (mr *MockDbHandlerMockRecorder) MergePoi(ctx, keep, merge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergePoi", reflect.TypeOf((*MockDbHandler)(nil).MergePoi), ctx, keep, merge)
+}
+
+// PurgeDeletedPois mocks base method.
+func (m *MockDbHandler) PurgeDeletedPois(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedPois", ctx, deletedBefore)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedPois indicates an expected call of PurgeDeletedPois.
+func (mr *MockDbHandlerMockRecorder) PurgeDeletedPois(ctx, deletedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedPois", reflect.TypeOf((*MockDbHandler)(nil).PurgeDeletedPois), ctx, deletedBefore)
+}
+
+// RestorePoi mocks base method.
+func (m *MockDbHandler) RestorePoi(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestorePoi", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestorePoi indicates an expected call of RestorePoi.
+func (mr *MockDbHandlerMockRecorder) RestorePoi(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePoi", reflect.TypeOf((*MockDbHandler)(nil).RestorePoi), ctx, id)
 }
 
 // SearchByRadius mocks base method.
-func (m *MockDbHandler) SearchByRadius(location Location, distanceInMeter uint64) (PoiDbEntries, error) {
+func (m *MockDbHandler) SearchByRadius(ctx context.Context, location Location, distanceInMeter uint64, limit int64) (PoiDbEntries, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SearchByRadius", location, distanceInMeter)
+	ret := m.ctrl.Call(m, "SearchByRadius", ctx, location, distanceInMeter, limit)
 	ret0, _ := ret[0].(PoiDbEntries)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SearchByRadius indicates an expected call of SearchByRadius.
-func (mr *MockDbHandlerMockRecorder) SearchByRadius(location, distanceInMeter interface{}) *gomock.Call {
+func (mr *MockDbHandlerMockRecorder) SearchByRadius(ctx, location, distanceInMeter, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchByRadius", reflect.TypeOf((*MockDbHandler)(nil).SearchByRadius), location, distanceInMeter)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchByRadius", reflect.TypeOf((*MockDbHandler)(nil).SearchByRadius), ctx, location, distanceInMeter, limit)
 }
 
 // UpdatePoi mocks base method.
-func (m *MockDbHandler) UpdatePoi(id string, poi PoiDbEntry) error {
+func (m *MockDbHandler) UpdatePoi(ctx context.Context, id string, poi PoiDbEntry) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdatePoi", id, poi)
+	ret := m.ctrl.Call(m, "UpdatePoi", ctx, id, poi)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdatePoi indicates an expected call of UpdatePoi.
-func (mr *MockDbHandlerMockRecorder) UpdatePoi(id, poi interface{}) *gomock.Call {
+func (mr *MockDbHandlerMockRecorder) UpdatePoi(ctx, id, poi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePoi", reflect.TypeOf((*MockDbHandler)(nil).UpdatePoi), ctx, id, poi)
+}
+
+// UpsertPoi mocks base method.
+func (m *MockDbHandler) UpsertPoi(ctx context.Context, poi PoiDbEntry) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertPoi", ctx, poi)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UpsertPoi indicates an expected call of UpsertPoi.
+func (mr *MockDbHandlerMockRecorder) UpsertPoi(ctx, poi interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePoi", reflect.TypeOf((*MockDbHandler)(nil).UpdatePoi), id, poi)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertPoi", reflect.TypeOf((*MockDbHandler)(nil).UpsertPoi), ctx, poi)
 }
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Test_isExternalIdTaken(t *testing.T) {
+	duplicate := func(index string) error {
+		return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+			Code:    duplicateKeyCode,
+			Message: "E11000 duplicate key error collection: poiDb.poi index: " + index + " dup key: { _id: \"abc\" }",
+		}}}
+	}
+
+	assert.True(t, isExternalIdTaken(duplicate(externalIdIndex)))
+	assert.False(t, isExternalIdTaken(duplicate("_id_")), "duplicate ids are no external id conflicts")
+	assert.False(t, isExternalIdTaken(errors.New("timeout")))
+	assert.False(t, isExternalIdTaken(nil))
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// ChangeType is the kind of change recorded in the outbox.
+type ChangeType string
+
+// Recorded changes of pois. The final removal by the purge is not recorded.
+const (
+	PoiCreated  ChangeType = "created"
+	PoiUpdated  ChangeType = "updated"
+	PoiDeleted  ChangeType = "deleted"
+	PoiRestored ChangeType = "restored"
+)
+
+// OutboxEntry records a change of a poi until it is published.
+type OutboxEntry struct {
+	Id     string     `json:"id" bson:"_id"`
+	Change ChangeType `json:"change" bson:"change"`
+	// Poi is the state after the change, for deleted pois the last state.
+	Poi       PoiDbEntry `json:"poi" bson:"poi"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+}
+
+// NewOutboxEntry creates an entry with a new id for the change of poi.
+func NewOutboxEntry(change ChangeType, poi PoiDbEntry) OutboxEntry {
+	return OutboxEntry{
+		Id:        uuid.New().String(),
+		Change:    change,
+		Poi:       poi,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// WithOutbox stores an OutboxEntry for every change in collection. The entry is written in the same transaction as
+// the change, so no change is lost if the service stops before it is published. Transactions require Mongo to run
+// as replica set.
+func WithOutbox(collection string) DbHandlerOption {
+	return func(c *dbHandler) {
+		c.outboxCollection = collection
+	}
+}
+
+func (c *dbHandler) getOutboxCollection() *mongo.Collection {
+	return c.dbClient.Database(c.dbName).Collection(c.outboxCollection)
+}
+
+// inTransaction runs fn in a transaction if the outbox is enabled, so a change and its outbox entry are stored
+// atomically. fn may be called again if the transaction is retried.
+func (c *dbHandler) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if c.outboxCollection == "" {
+		return fn(ctx)
+	}
+
+	session, err := c.dbClient.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// addOutboxEntry records the change of poi if the outbox is enabled.
+func (c *dbHandler) addOutboxEntry(ctx context.Context, change ChangeType, poi PoiDbEntry) error {
+	if c.outboxCollection == "" {
+		return nil
+	}
+	_, err := c.getOutboxCollection().InsertOne(ctx, NewOutboxEntry(change, poi))
+	return err
+}
@@ -1,20 +1,61 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"poi-service/cmd/data"
+	"time"
 )
 
-// PoiHandler provide abstraction to manage pois
+// PoiHandler provide abstraction to manage pois. The context of the request is passed through, so decorators can
+// access request scoped values like the authenticated principal.
 type PoiHandler interface {
-	Create(poi *data.Poi) (uniqueId string, err error)
-	Update(idToUpdate data.Id, updatedPoi *data.Poi) (err error)
-	Get(id data.Id) (resp data.Poi, err error)
-	Delete(id data.Id) (err error)
-	Search(pos data.SearchArea) (resp data.Pois, err error)
+	Create(ctx context.Context, poi *data.Poi) (uniqueId string, err error)
+	Update(ctx context.Context, idToUpdate data.Id, updatedPoi *data.Poi) (err error)
+	Get(ctx context.Context, id data.Id) (resp data.Poi, err error)
+	Delete(ctx context.Context, id data.Id) (err error)
+	Search(ctx context.Context, pos data.SearchArea) (resp data.Pois, err error)
+	// ListDeleted returns the pois in the trash.
+	ListDeleted(ctx context.Context) (resp data.DeletedPois, err error)
+	// Restore moves a poi out of the trash.
+	Restore(ctx context.Context, id data.Id) (err error)
+	// PurgeDeleted finally removes the pois that are in the trash for longer than retention.
+	PurgeDeleted(ctx context.Context, retention time.Duration) (purged int64, err error)
+	// GetAsOf returns the poi as it was at the given time.
+	GetAsOf(ctx context.Context, id data.Id, asOf time.Time) (resp data.Poi, err error)
+	// ListRevisions returns all versions of the poi.
+	ListRevisions(ctx context.Context, id data.Id) (resp data.Revisions, err error)
+	// GetRevision returns a single version of the poi.
+	GetRevision(ctx context.Context, id data.Id, revision int) (resp data.Revision, err error)
+	// Diff returns the fields that differ between two revisions of the poi.
+	Diff(ctx context.Context, id data.Id, from, to int) (resp data.Changes, err error)
+	// Revert changes the poi back to the state of an earlier revision. This creates a new revision.
+	Revert(ctx context.Context, id data.Id, revision int) (err error)
+	// GetByExternalId returns the poi with the external id of source and its id or PoiNotFound.
+	GetByExternalId(ctx context.Context, source, externalId string) (id string, resp data.Poi, err error)
+	// Upsert creates the poi given by Source and ExternalId of poi or updates it if it already exists. It returns
+	// PoiInTrash if the poi is deleted.
+	Upsert(ctx context.Context, poi *data.Poi) (uniqueId string, created bool, err error)
+	// Merge moves the poi merge into the trash and its source and external id to keep. It returns ExternalIdConflict
+	// if both have an external id. Merging a poi into the same poi again succeeds without changes.
+	Merge(ctx context.Context, keep, merge data.Id) (err error)
 }
 
+// InvalidExternalId is given if only one of source and external id is set.
+const InvalidExternalId = InvalidPoiError("source and externalId must be given together")
+
+// LongitudeOutOfRange and LatitudeOutOfRange are given for coordinates that are not on earth.
+const (
+	LongitudeOutOfRange = InvalidPoiError("longitude out of range")
+	LatitudeOutOfRange  = InvalidPoiError("latitude out of range")
+)
+
+type InvalidPoiError string
+
+func (e InvalidPoiError) Error() string { return string(e) }
+
 func NewPoiHandler(dbHandler DbHandler) PoiHandler {
 	if dbHandler == nil {
 		return nil
@@ -26,70 +67,235 @@ type poiHandler struct {
 	dbHandler DbHandler
 }
 
-func (p *poiHandler) Create(poi *data.Poi) (uniqueId string, err error) {
+func (p *poiHandler) Create(ctx context.Context, poi *data.Poi) (uniqueId string, err error) {
+	if err := validate(poi); err != nil {
+		return "", err
+	}
+
+	uniqueId, err = p.dbHandler.AddPoi(ctx, newEntry(poi))
+	return uniqueId, err
+}
+
+// validate checks the values of a poi to store, source and external id are optional but only valid together.
+func validate(poi *data.Poi) error {
 	if poi == nil {
-		return "", errors.New("poi is nil")
+		return errors.New("poi is nil")
 	}
-	if poi.Longitude < -180 || poi.Longitude > 80 {
-		return "", errors.New("longitude out of range")
+	if poi.Longitude < -180 || poi.Longitude > 180 {
+		return LongitudeOutOfRange
 	}
 	if poi.Latitude < -90 || poi.Latitude > 90 {
-		return "", errors.New("latitude out of range")
+		return LatitudeOutOfRange
+	}
+	if (poi.Source == "") != (poi.ExternalId == "") {
+		return InvalidExternalId
 	}
+	return nil
+}
 
-	uniqueId, err = p.dbHandler.AddPoi(PoiDbEntry{
-		Id:       uuid.New().String(),
-		Name:     poi.Name,
-		Location: NewLocation(poi.Latitude, poi.Longitude),
-	})
-	return uniqueId, err
+// newEntry creates the entry of a new poi with a new id.
+func newEntry(poi *data.Poi) PoiDbEntry {
+	return PoiDbEntry{
+		Id:         uuid.New().String(),
+		Name:       poi.Name,
+		Location:   NewLocation(poi.Latitude, poi.Longitude),
+		Source:     poi.Source,
+		ExternalId: poi.ExternalId,
+	}
 }
 
-func (p *poiHandler) Update(idToUpdate data.Id, updatedPoi *data.Poi) error {
-	return p.dbHandler.UpdatePoi(string(idToUpdate), PoiDbEntry{
+func (p *poiHandler) Update(ctx context.Context, idToUpdate data.Id, updatedPoi *data.Poi) error {
+	if err := validate(updatedPoi); err != nil {
+		return err
+	}
+	return p.dbHandler.UpdatePoi(ctx, string(idToUpdate), PoiDbEntry{
 		Id:       string(idToUpdate),
 		Name:     updatedPoi.Name,
 		Location: NewLocation(updatedPoi.Latitude, updatedPoi.Longitude),
 	})
 }
 
-func (p *poiHandler) Get(id data.Id) (resp data.Poi, err error) {
-	result, err := p.dbHandler.GetPoi(string(id))
+func (p *poiHandler) Get(ctx context.Context, id data.Id) (resp data.Poi, err error) {
+	result, err := p.dbHandler.GetPoi(ctx, string(id))
 	if err != nil {
 		return
 	}
 
-	resp.Latitude = result.Location.Coordinates[0]
-	resp.Longitude = result.Location.Coordinates[1]
-	resp.Name = result.Name
-	return
+	return result.Poi(), nil
 }
 
-func (p *poiHandler) Delete(id data.Id) error {
-	return p.dbHandler.DeletePoi(string(id))
+func (p *poiHandler) Delete(ctx context.Context, id data.Id) error {
+	return p.dbHandler.DeletePoi(ctx, string(id))
 }
 
-func (p *poiHandler) Search(pos data.SearchArea) (resp data.Pois, err error) {
+func (p *poiHandler) Search(ctx context.Context, pos data.SearchArea) (resp data.Pois, err error) {
 	var pois PoiDbEntries
 
 	if pos.RadiusInMeter == 0 {
 		// TODO a proper solution would use paging - but that is something to be adder later
-		pois, err = p.dbHandler.GetAllPois()
+		pois, err = p.dbHandler.GetAllPois(ctx)
 	} else {
-		pois, err = p.dbHandler.SearchByRadius(NewLocation(pos.Latitude, pos.Longitude), pos.RadiusInMeter)
+		pois, err = p.dbHandler.SearchByRadius(ctx, NewLocation(pos.Latitude, pos.Longitude), pos.RadiusInMeter, 0)
+	}
+
+	if err != nil {
+		return
+	}
+
+	for _, entry := range pois {
+		resp = append(resp, entry.Poi())
 	}
 
+	return resp, nil
+}
+
+func (p *poiHandler) ListDeleted(ctx context.Context) (resp data.DeletedPois, err error) {
+	pois, err := p.dbHandler.GetDeletedPois(ctx)
 	if err != nil {
 		return
 	}
 
+	resp = data.DeletedPois{}
 	for _, entry := range pois {
-		resp = append(resp, data.Poi{
-			Name:      entry.Name,
-			Latitude:  entry.Location.Coordinates[0],
-			Longitude: entry.Location.Coordinates[1],
-		})
+		deleted := data.DeletedPoi{
+			Id:  data.Id(entry.Id),
+			Poi: entry.Poi(),
+		}
+		if entry.DeletedAt != nil {
+			deleted.DeletedAt = *entry.DeletedAt
+		}
+		resp = append(resp, deleted)
+	}
+	return resp, nil
+}
+
+func (p *poiHandler) Restore(ctx context.Context, id data.Id) error {
+	return p.dbHandler.RestorePoi(ctx, string(id))
+}
+
+func (p *poiHandler) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	return p.dbHandler.PurgeDeletedPois(ctx, time.Now().Add(-retention))
+}
+
+// RunPurge purges the pois that are in the trash for longer than retention every interval until ctx is done. The
+// retention is read before every purge, so it can be changed while running.
+func RunPurge(ctx context.Context, p PoiHandler, retention func() time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purged, err := p.PurgeDeleted(ctx, retention())
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("purging deleted pois failed")
+			continue
+		}
+		if purged > 0 {
+			log.Ctx(ctx).Info().Int64("purged", purged).Msg("deleted pois purged")
+		}
+	}
+}
+
+func (p *poiHandler) GetAsOf(ctx context.Context, id data.Id, asOf time.Time) (resp data.Poi, err error) {
+	result, err := p.dbHandler.GetPoiAsOf(ctx, string(id), asOf)
+	if err != nil {
+		return
+	}
+	return toRevision(result).Poi, nil
+}
+
+func (p *poiHandler) ListRevisions(ctx context.Context, id data.Id) (resp data.Revisions, err error) {
+	revisions, err := p.dbHandler.GetRevisions(ctx, string(id))
+	if err != nil {
+		return
 	}
 
+	resp = data.Revisions{}
+	for _, revision := range revisions {
+		resp = append(resp, toRevision(revision))
+	}
+	return resp, nil
+}
+
+func (p *poiHandler) GetRevision(ctx context.Context, id data.Id, revision int) (resp data.Revision, err error) {
+	result, err := p.dbHandler.GetRevision(ctx, string(id), revision)
+	if err != nil {
+		return
+	}
+	return toRevision(result), nil
+}
+
+func (p *poiHandler) Diff(ctx context.Context, id data.Id, from, to int) (resp data.Changes, err error) {
+	fromRev, err := p.GetRevision(ctx, id, from)
+	if err != nil {
+		return
+	}
+	toRev, err := p.GetRevision(ctx, id, to)
+	if err != nil {
+		return
+	}
+
+	resp = data.Changes{}
+	if fromRev.Name != toRev.Name {
+		resp = append(resp, data.Change{Field: "name", From: fromRev.Name, To: toRev.Name})
+	}
+	if fromRev.Latitude != toRev.Latitude {
+		resp = append(resp, data.Change{Field: "latitude", From: fromRev.Latitude, To: toRev.Latitude})
+	}
+	if fromRev.Longitude != toRev.Longitude {
+		resp = append(resp, data.Change{Field: "longitude", From: fromRev.Longitude, To: toRev.Longitude})
+	}
 	return resp, nil
 }
+
+func (p *poiHandler) Revert(ctx context.Context, id data.Id, revision int) error {
+	result, err := p.dbHandler.GetRevision(ctx, string(id), revision)
+	if err != nil {
+		return err
+	}
+
+	// the stored location is taken as it is, so the reverted poi equals the revision exactly
+	return p.dbHandler.UpdatePoi(ctx, string(id), PoiDbEntry{
+		Id:       string(id),
+		Name:     result.Name,
+		Location: result.Location,
+	})
+}
+
+func (p *poiHandler) GetByExternalId(ctx context.Context, source, externalId string) (id string, resp data.Poi, err error) {
+	result, err := p.dbHandler.GetPoiByExternalId(ctx, source, externalId)
+	if err != nil {
+		return
+	}
+	return result.Id, result.Poi(), nil
+}
+
+func (p *poiHandler) Upsert(ctx context.Context, poi *data.Poi) (uniqueId string, created bool, err error) {
+	if err := validate(poi); err != nil {
+		return "", false, err
+	}
+	if poi.Source == "" {
+		return "", false, InvalidExternalId
+	}
+	return p.dbHandler.UpsertPoi(ctx, newEntry(poi))
+}
+
+func (p *poiHandler) Merge(ctx context.Context, keep, merge data.Id) error {
+	return p.dbHandler.MergePoi(ctx, string(keep), string(merge))
+}
+
+func toRevision(revision PoiRevision) data.Revision {
+	return data.Revision{
+		Revision: revision.Revision,
+		Poi: data.Poi{
+			Name:      revision.Name,
+			Latitude:  revision.Location.Latitude(),
+			Longitude: revision.Location.Longitude(),
+		},
+		ValidFrom: revision.ValidFrom,
+	}
+}
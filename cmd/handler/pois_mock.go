@@ -5,8 +5,10 @@
 package handler
 
 import (
+	context "context"
 	data "poi-service/cmd/data"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,74 +37,238 @@ func (m *MockPoiHandler) EXPECT() *MockPoiHandlerMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockPoiHandler) Create(poi *data.Poi) (string, error) {
+func (m *MockPoiHandler) Create(ctx context.Context, poi *data.Poi) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", poi)
+	ret := m.ctrl.Call(m, "Create", ctx, poi)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockPoiHandlerMockRecorder) Create(poi interface{}) *gomock.Call {
+func (mr *MockPoiHandlerMockRecorder) Create(ctx, poi interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPoiHandler)(nil).Create), poi)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPoiHandler)(nil).Create), ctx, poi)
 }
 
 // Delete mocks base method.
-func (m *MockPoiHandler) Delete(id data.Id) error {
+func (m *MockPoiHandler) Delete(ctx context.Context, id data.Id) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", id)
+	ret := m.ctrl.Call(m, "Delete", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockPoiHandlerMockRecorder) Delete(id interface{}) *gomock.Call {
+func (mr *MockPoiHandlerMockRecorder) Delete(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockPoiHandler)(nil).Delete), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockPoiHandler)(nil).Delete), ctx, id)
+}
+
+// Diff mocks base method.
+func (m *MockPoiHandler) Diff(ctx context.Context, id data.Id, from, to int) (data.Changes, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Diff", ctx, id, from, to)
+	ret0, _ := ret[0].(data.Changes)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Diff indicates an expected call of Diff.
+func (mr *MockPoiHandlerMockRecorder) Diff(ctx, id, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Diff", reflect.TypeOf((*MockPoiHandler)(nil).Diff), ctx, id, from, to)
 }
 
 // Get mocks base method.
-func (m *MockPoiHandler) Get(id data.Id) (data.Poi, error) {
+func (m *MockPoiHandler) Get(ctx context.Context, id data.Id) (data.Poi, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", id)
+	ret := m.ctrl.Call(m, "Get", ctx, id)
 	ret0, _ := ret[0].(data.Poi)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get.
-func (mr *MockPoiHandlerMockRecorder) Get(id interface{}) *gomock.Call {
+func (mr *MockPoiHandlerMockRecorder) Get(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPoiHandler)(nil).Get), ctx, id)
+}
+
+// GetAsOf mocks base method.
+func (m *MockPoiHandler) GetAsOf(ctx context.Context, id data.Id, asOf time.Time) (data.Poi, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAsOf", ctx, id, asOf)
+	ret0, _ := ret[0].(data.Poi)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAsOf indicates an expected call of GetAsOf.
+func (mr *MockPoiHandlerMockRecorder) GetAsOf(ctx, id, asOf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAsOf", reflect.TypeOf((*MockPoiHandler)(nil).GetAsOf), ctx, id, asOf)
+}
+
+// GetByExternalId mocks base method.
+func (m *MockPoiHandler) GetByExternalId(ctx context.Context, source, externalId string) (string, data.Poi, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByExternalId", ctx, source, externalId)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(data.Poi)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetByExternalId indicates an expected call of GetByExternalId.
+func (mr *MockPoiHandlerMockRecorder) GetByExternalId(ctx, source, externalId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByExternalId", reflect.TypeOf((*MockPoiHandler)(nil).GetByExternalId), ctx, source, externalId)
+}
+
+// GetRevision mocks base method.
+func (m *MockPoiHandler) GetRevision(ctx context.Context, id data.Id, revision int) (data.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevision", ctx, id, revision)
+	ret0, _ := ret[0].(data.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevision indicates an expected call of GetRevision.
+func (mr *MockPoiHandlerMockRecorder) GetRevision(ctx, id, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevision", reflect.TypeOf((*MockPoiHandler)(nil).GetRevision), ctx, id, revision)
+}
+
+// ListDeleted mocks base method.
+func (m *MockPoiHandler) ListDeleted(ctx context.Context) (data.DeletedPois, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeleted", ctx)
+	ret0, _ := ret[0].(data.DeletedPois)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeleted indicates an expected call of ListDeleted.
+func (mr *MockPoiHandlerMockRecorder) ListDeleted(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPoiHandler)(nil).Get), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeleted", reflect.TypeOf((*MockPoiHandler)(nil).ListDeleted), ctx)
+}
+
+// ListRevisions mocks base method.
+func (m *MockPoiHandler) ListRevisions(ctx context.Context, id data.Id) (data.Revisions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRevisions", ctx, id)
+	ret0, _ := ret[0].(data.Revisions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRevisions indicates an expected call of ListRevisions.
+func (mr *MockPoiHandlerMockRecorder) ListRevisions(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRevisions", reflect.TypeOf((*MockPoiHandler)(nil).ListRevisions), ctx, id)
+}
+
+// Merge mocks base method.
+func (m *MockPoiHandler) Merge(ctx context.Context, keep, merge data.Id) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merge", ctx, keep, merge)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Merge indicates an expected call of Merge.
+func (mr *MockPoiHandlerMockRecorder) Merge(ctx, keep, merge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockPoiHandler)(nil).Merge), ctx, keep, merge)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockPoiHandler) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", ctx, retention)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockPoiHandlerMockRecorder) PurgeDeleted(ctx, retention interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockPoiHandler)(nil).PurgeDeleted), ctx, retention)
+}
+
+// Restore mocks base method.
+func (m *MockPoiHandler) Restore(ctx context.Context, id data.Id) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockPoiHandlerMockRecorder) Restore(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockPoiHandler)(nil).Restore), ctx, id)
+}
+
+// Revert mocks base method.
+func (m *MockPoiHandler) Revert(ctx context.Context, id data.Id, revision int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revert", ctx, id, revision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revert indicates an expected call of Revert.
+func (mr *MockPoiHandlerMockRecorder) Revert(ctx, id, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revert", reflect.TypeOf((*MockPoiHandler)(nil).Revert), ctx, id, revision)
 }
 
 // Search mocks base method.
-func (m *MockPoiHandler) Search(pos data.SearchArea) (data.Pois, error) {
+func (m *MockPoiHandler) Search(ctx context.Context, pos data.SearchArea) (data.Pois, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Search", pos)
+	ret := m.ctrl.Call(m, "Search", ctx, pos)
 	ret0, _ := ret[0].(data.Pois)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Search indicates an expected call of Search.
-func (mr *MockPoiHandlerMockRecorder) Search(pos interface{}) *gomock.Call {
+func (mr *MockPoiHandlerMockRecorder) Search(ctx, pos interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockPoiHandler)(nil).Search), pos)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockPoiHandler)(nil).Search), ctx, pos)
 }
 
 // Update mocks base method.
-func (m *MockPoiHandler) Update(idToUpdate data.Id, updatedPoi *data.Poi) error {
+func (m *MockPoiHandler) Update(ctx context.Context, idToUpdate data.Id, updatedPoi *data.Poi) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", idToUpdate, updatedPoi)
+	ret := m.ctrl.Call(m, "Update", ctx, idToUpdate, updatedPoi)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Update indicates an expected call of Update.
-func (mr *MockPoiHandlerMockRecorder) Update(idToUpdate, updatedPoi interface{}) *gomock.Call {
+func (mr *MockPoiHandlerMockRecorder) Update(ctx, idToUpdate, updatedPoi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockPoiHandler)(nil).Update), ctx, idToUpdate, updatedPoi)
+}
+
+// Upsert mocks base method.
+func (m *MockPoiHandler) Upsert(ctx context.Context, poi *data.Poi) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", ctx, poi)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockPoiHandlerMockRecorder) Upsert(ctx, poi interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockPoiHandler)(nil).Update), idToUpdate, updatedPoi)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockPoiHandler)(nil).Upsert), ctx, poi)
 }
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"poi-service/cmd/data"
 	"testing"
+	"time"
 )
 
 func Test_poiHandler_Create(t *testing.T) {
@@ -20,60 +22,66 @@ func Test_poiHandler_Create(t *testing.T) {
 	})
 
 	t.Run("poi nil", func(t *testing.T) {
-		id, err := handlerToTest.Create(nil)
+		id, err := handlerToTest.Create(context.Background(), nil)
 		assert.NotNil(t, err)
 		assert.Empty(t, id)
 	})
 
 	t.Run("longitude out of range", func(t *testing.T) {
-		id, err := handlerToTest.Create(&data.Poi{Longitude: 82})
-		assert.NotNil(t, err)
+		id, err := handlerToTest.Create(context.Background(), &data.Poi{Longitude: 181})
+		assert.ErrorIs(t, err, LongitudeOutOfRange)
 		assert.Empty(t, id)
 
-		id, err = handlerToTest.Create(&data.Poi{Longitude: -200})
+		id, err = handlerToTest.Create(context.Background(), &data.Poi{Longitude: -200})
 		assert.NotNil(t, err)
 		assert.Empty(t, id)
 	})
 
+	t.Run("longitude in the east", func(t *testing.T) {
+		mongoMock.EXPECT().AddPoi(gomock.Any(), gomock.Any()).Return("abc", nil)
+		_, err := handlerToTest.Create(context.Background(), &data.Poi{Name: "Tokyo Tower", Latitude: 35.66, Longitude: 139.75})
+		assert.NoError(t, err)
+	})
+
 	t.Run("latitude out of range", func(t *testing.T) {
-		id, err := handlerToTest.Create(&data.Poi{Latitude: 100})
+		id, err := handlerToTest.Create(context.Background(), &data.Poi{Latitude: 100})
 		assert.NotNil(t, err)
 		assert.Empty(t, id)
 
-		id, err = handlerToTest.Create(&data.Poi{Latitude: -100})
+		id, err = handlerToTest.Create(context.Background(), &data.Poi{Latitude: -100})
 		assert.NotNil(t, err)
 		assert.Empty(t, id)
 	})
 
 	t.Run("create entry in db", func(t *testing.T) {
-		mongoMock.EXPECT().AddPoi(gomock.Any()).Return("abc", nil)
+		mongoMock.EXPECT().AddPoi(gomock.Any(), gomock.Any()).Return("abc", nil)
 		data := &data.Poi{
 			Name:      "abc",
 			Latitude:  90,
 			Longitude: 20,
 		}
-		id, err := handlerToTest.Create(data)
+		id, err := handlerToTest.Create(context.Background(), data)
 		assert.Nil(t, err)
 		assert.Equal(t, "abc", id)
 
-		mongoMock.EXPECT().AddPoi(gomock.Any()).Return("", errors.New("Some error"))
-		id, err = handlerToTest.Create(data)
+		mongoMock.EXPECT().AddPoi(gomock.Any(), gomock.Any()).Return("", errors.New("Some error"))
+		id, err = handlerToTest.Create(context.Background(), data)
 		assert.NotNil(t, err)
 	})
 
 	t.Run("create entry in db", func(t *testing.T) {
-		mongoMock.EXPECT().AddPoi(gomock.Any()).Return("abc", nil)
+		mongoMock.EXPECT().AddPoi(gomock.Any(), gomock.Any()).Return("abc", nil)
 		data := &data.Poi{
 			Name:      "abc",
 			Latitude:  90,
 			Longitude: 20,
 		}
-		id, err := handlerToTest.Create(data)
+		id, err := handlerToTest.Create(context.Background(), data)
 		assert.Nil(t, err)
 		assert.Equal(t, "abc", id)
 
-		mongoMock.EXPECT().AddPoi(gomock.Any()).Return("", errors.New("Some error"))
-		id, err = handlerToTest.Create(data)
+		mongoMock.EXPECT().AddPoi(gomock.Any(), gomock.Any()).Return("", errors.New("Some error"))
+		id, err = handlerToTest.Create(context.Background(), data)
 		assert.NotNil(t, err)
 	})
 }
@@ -86,14 +94,20 @@ func Test_poiHandler_Update(t *testing.T) {
 	handlerToTest := NewPoiHandler(mongoMock)
 
 	t.Run("handler not nil", func(t *testing.T) {
-		mongoMock.EXPECT().UpdatePoi("abc", gomock.Any()).Return(errors.New("Some error"))
-		err := handlerToTest.Update(data.Id("abc"), &data.Poi{})
+		mongoMock.EXPECT().UpdatePoi(gomock.Any(), "abc", gomock.Any()).Return(errors.New("Some error"))
+		err := handlerToTest.Update(context.Background(), data.Id("abc"), &data.Poi{})
 		assert.NotNil(t, err)
 
-		mongoMock.EXPECT().UpdatePoi("abc", gomock.Any()).Return(nil)
-		err = handlerToTest.Update(data.Id("abc"), &data.Poi{})
+		mongoMock.EXPECT().UpdatePoi(gomock.Any(), "abc", gomock.Any()).Return(nil)
+		err = handlerToTest.Update(context.Background(), data.Id("abc"), &data.Poi{})
 		assert.Nil(t, err)
 	})
+
+	t.Run("invalid poi", func(t *testing.T) {
+		assert.Error(t, handlerToTest.Update(context.Background(), data.Id("abc"), nil))
+		assert.ErrorIs(t, handlerToTest.Update(context.Background(), data.Id("abc"), &data.Poi{Longitude: 181}), LongitudeOutOfRange)
+		assert.ErrorIs(t, handlerToTest.Update(context.Background(), data.Id("abc"), &data.Poi{Latitude: -91}), LatitudeOutOfRange)
+	})
 }
 
 func Test_poiHandler_Get(t *testing.T) {
@@ -104,16 +118,16 @@ func Test_poiHandler_Get(t *testing.T) {
 	handlerToTest := NewPoiHandler(mongoMock)
 
 	t.Run("handler not nil", func(t *testing.T) {
-		mongoMock.EXPECT().GetPoi("abc").Return(PoiDbEntry{
+		mongoMock.EXPECT().GetPoi(gomock.Any(), "abc").Return(PoiDbEntry{
 			Id:       "abc",
 			Name:     "mc donalds",
 			Location: NewLocation(23, 25),
 		}, nil)
-		data, err := handlerToTest.Get(data.Id("abc"))
+		data, err := handlerToTest.Get(context.Background(), data.Id("abc"))
 		assert.Nil(t, err)
 		assert.Equal(t, "mc donalds", data.Name)
-		assert.Equal(t, float64(23), data.Longitude)
-		assert.Equal(t, float64(25), data.Latitude)
+		assert.Equal(t, float64(23), data.Latitude)
+		assert.Equal(t, float64(25), data.Longitude)
 	})
 }
 
@@ -137,8 +151,8 @@ func Test_poiHandler_Search(t *testing.T) {
 			Location: NewLocation(23, 25),
 		})
 
-		mongoMock.EXPECT().SearchByRadius(gomock.Any(), uint64(20)).Return(resp, nil)
-		data, err := handlerToTest.Search(data.SearchArea{RadiusInMeter: 20})
+		mongoMock.EXPECT().SearchByRadius(gomock.Any(), gomock.Any(), uint64(20), int64(0)).Return(resp, nil)
+		data, err := handlerToTest.Search(context.Background(), data.SearchArea{RadiusInMeter: 20})
 		assert.Nil(t, err)
 		assert.Equal(t, 2, len(data))
 	})
@@ -156,10 +170,188 @@ func Test_poiHandler_Search(t *testing.T) {
 			Location: NewLocation(23, 25),
 		})
 
-		mongoMock.EXPECT().GetAllPois().Return(resp, nil)
-		data, err := handlerToTest.Search(data.SearchArea{})
+		mongoMock.EXPECT().GetAllPois(gomock.Any()).Return(resp, nil)
+		data, err := handlerToTest.Search(context.Background(), data.SearchArea{})
 		assert.Nil(t, err)
 		assert.Equal(t, 2, len(data))
 
 	})
 }
+
+func Test_poiHandler_Trash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mongoMock := NewMockDbHandler(ctrl)
+	handlerToTest := NewPoiHandler(mongoMock)
+
+	t.Run("list deleted", func(t *testing.T) {
+		deletedAt := time.Now()
+		mongoMock.EXPECT().GetDeletedPois(gomock.Any()).Return(PoiDbEntries{{
+			Id:        "abc",
+			Name:      "mc donalds",
+			Location:  NewLocation(23, 25),
+			DeletedAt: &deletedAt,
+		}}, nil)
+
+		resp, err := handlerToTest.ListDeleted(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(resp))
+		assert.Equal(t, data.Id("abc"), resp[0].Id)
+		assert.Equal(t, "mc donalds", resp[0].Name)
+		assert.Equal(t, deletedAt, resp[0].DeletedAt)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		mongoMock.EXPECT().RestorePoi(gomock.Any(), "abc").Return(PoiNotFound)
+		err := handlerToTest.Restore(context.Background(), data.Id("abc"))
+		assert.Equal(t, PoiNotFound, err)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		mongoMock.EXPECT().PurgeDeletedPois(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, deletedBefore time.Time) (int64, error) {
+			assert.WithinDuration(t, time.Now().Add(-time.Hour), deletedBefore, time.Second)
+			return 2, nil
+		})
+		purged, err := handlerToTest.PurgeDeleted(context.Background(), time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), purged)
+	})
+}
+
+func TestRunPurge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mongoMock := NewMockDbHandler(ctrl)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	purged := make(chan struct{})
+	mongoMock.EXPECT().PurgeDeletedPois(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, time.Time) (int64, error) {
+		cancel()
+		close(purged)
+		return 1, nil
+	})
+
+	RunPurge(ctx, NewPoiHandler(mongoMock), func() time.Duration { return time.Hour }, time.Millisecond)
+	<-purged
+}
+
+func Test_poiHandler_Revisions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mongoMock := NewMockDbHandler(ctrl)
+	handlerToTest := NewPoiHandler(mongoMock)
+
+	first := PoiRevision{PoiId: "abc", Revision: 1, Name: "mc donalds", Location: NewLocation(23, 25)}
+	second := PoiRevision{PoiId: "abc", Revision: 2, Name: "burger king", Location: NewLocation(23, 26)}
+
+	t.Run("list", func(t *testing.T) {
+		mongoMock.EXPECT().GetRevisions(gomock.Any(), "abc").Return(PoiRevisions{first, second}, nil)
+		resp, err := handlerToTest.ListRevisions(context.Background(), "abc")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(resp))
+		assert.Equal(t, 2, resp[1].Revision)
+		assert.Equal(t, "burger king", resp[1].Name)
+	})
+
+	t.Run("as of", func(t *testing.T) {
+		asOf := time.Now()
+		mongoMock.EXPECT().GetPoiAsOf(gomock.Any(), "abc", asOf).Return(first, nil)
+		resp, err := handlerToTest.GetAsOf(context.Background(), "abc", asOf)
+		assert.Nil(t, err)
+		assert.Equal(t, "mc donalds", resp.Name)
+
+		mongoMock.EXPECT().GetPoiAsOf(gomock.Any(), "abc", asOf).Return(PoiRevision{}, PoiNotFound)
+		_, err = handlerToTest.GetAsOf(context.Background(), "abc", asOf)
+		assert.Equal(t, PoiNotFound, err)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		mongoMock.EXPECT().GetRevision(gomock.Any(), "abc", 1).Return(first, nil)
+		mongoMock.EXPECT().GetRevision(gomock.Any(), "abc", 2).Return(second, nil)
+		resp, err := handlerToTest.Diff(context.Background(), "abc", 1, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, data.Changes{
+			{Field: "name", From: "mc donalds", To: "burger king"},
+			{Field: "longitude", From: float64(25), To: float64(26)},
+		}, resp)
+
+		mongoMock.EXPECT().GetRevision(gomock.Any(), "abc", 3).Return(PoiRevision{}, RevisionNotFound)
+		_, err = handlerToTest.Diff(context.Background(), "abc", 3, 2)
+		assert.Equal(t, RevisionNotFound, err)
+	})
+
+	t.Run("revert", func(t *testing.T) {
+		mongoMock.EXPECT().GetRevision(gomock.Any(), "abc", 1).Return(first, nil)
+		mongoMock.EXPECT().UpdatePoi(gomock.Any(), "abc", PoiDbEntry{Id: "abc", Name: first.Name, Location: first.Location}).Return(nil)
+		assert.Nil(t, handlerToTest.Revert(context.Background(), "abc", 1))
+
+		mongoMock.EXPECT().GetRevision(gomock.Any(), "abc", 5).Return(PoiRevision{}, RevisionNotFound)
+		assert.Equal(t, RevisionNotFound, handlerToTest.Revert(context.Background(), "abc", 5))
+	})
+}
+
+func Test_poiHandler_ExternalId(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mongoMock := NewMockDbHandler(ctrl)
+	handlerToTest := NewPoiHandler(mongoMock)
+	poi := data.Poi{Name: "Zwinger", Latitude: 51.05, Longitude: 13.73, Source: "partner", ExternalId: "z-1"}
+
+	t.Run("source and external id only together", func(t *testing.T) {
+		_, err := handlerToTest.Create(context.Background(), &data.Poi{Source: "partner"})
+		assert.ErrorIs(t, err, InvalidExternalId)
+		_, _, err = handlerToTest.Upsert(context.Background(), &data.Poi{ExternalId: "z-1"})
+		assert.ErrorIs(t, err, InvalidExternalId)
+	})
+
+	t.Run("upsert validates like create", func(t *testing.T) {
+		_, _, err := handlerToTest.Upsert(context.Background(), nil)
+		assert.Error(t, err)
+		_, _, err = handlerToTest.Upsert(context.Background(), &data.Poi{Latitude: 100, Source: "partner", ExternalId: "z-1"})
+		assert.ErrorIs(t, err, LatitudeOutOfRange)
+		_, _, err = handlerToTest.Upsert(context.Background(), &data.Poi{Longitude: -200, Source: "partner", ExternalId: "z-1"})
+		assert.ErrorIs(t, err, LongitudeOutOfRange)
+		_, _, err = handlerToTest.Upsert(context.Background(), &data.Poi{Latitude: 51.05, Longitude: 13.73})
+		assert.ErrorIs(t, err, InvalidExternalId, "upserts need the external id")
+	})
+
+	t.Run("create with external id", func(t *testing.T) {
+		mongoMock.EXPECT().AddPoi(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, entry PoiDbEntry) (string, error) {
+			assert.Equal(t, "partner", entry.Source)
+			assert.Equal(t, "z-1", entry.ExternalId)
+			return entry.Id, nil
+		})
+		id, err := handlerToTest.Create(context.Background(), &poi)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		mongoMock.EXPECT().UpsertPoi(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, entry PoiDbEntry) (string, bool, error) {
+			assert.NotEmpty(t, entry.Id)
+			assert.Equal(t, "z-1", entry.ExternalId)
+			return "abc", false, nil
+		})
+		id, created, err := handlerToTest.Upsert(context.Background(), &poi)
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, "abc", id)
+	})
+
+	t.Run("get by external id", func(t *testing.T) {
+		entry := newEntry(&poi)
+		mongoMock.EXPECT().GetPoiByExternalId(gomock.Any(), "partner", "z-1").Return(entry, nil)
+		id, found, err := handlerToTest.GetByExternalId(context.Background(), "partner", "z-1")
+		assert.NoError(t, err)
+		assert.Equal(t, entry.Id, id)
+		assert.Equal(t, "z-1", found.ExternalId)
+
+		mongoMock.EXPECT().GetPoiByExternalId(gomock.Any(), "partner", "unknown").Return(PoiDbEntry{}, PoiNotFound)
+		_, _, err = handlerToTest.GetByExternalId(context.Background(), "partner", "unknown")
+		assert.ErrorIs(t, err, PoiNotFound)
+	})
+}
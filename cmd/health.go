@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/health"
+)
+
+// jwksMaxAge is the age of the last successful JWKS download up to which the service is ready. The background
+// refresh runs at least every auth.DefaultMaxRefreshWait, so a single failed refresh is tolerated.
+const jwksMaxAge = 2 * auth.DefaultMaxRefreshWait
+
+// newHealthChecker checks the dependencies needed to serve requests. The JWKS of the issuer is only needed if JWTs
+// are accepted, it is checked in the cache and not downloaded by the checks.
+func newHealthChecker(client *mongo.Client, jwkStore auth.JwkStore, acceptsJwt bool) *health.Checker {
+	checker := health.NewChecker()
+	checker.Add("mongo", func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+	if acceptsJwt {
+		checker.Add("jwks", func(context.Context) error {
+			return jwkStore.Ready(jwksMaxAge)
+		})
+	}
+	return checker
+}
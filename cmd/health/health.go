@@ -0,0 +1,197 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check tests a dependency, it returns nil if the dependency is usable.
+type Check func(ctx context.Context) error
+
+// Status of the service and its dependencies.
+const (
+	StatusOk           = "ok"
+	StatusReady        = "ready"
+	StatusNotReady     = "not ready"
+	StatusShuttingDown = "shutting down"
+	StatusUp           = "up"
+	StatusDown         = "down"
+)
+
+// Defaults of the Checker.
+const (
+	// DefaultTimeout limits the duration of a single check.
+	DefaultTimeout = 2 * time.Second
+	// DefaultCacheTTL is the time a result is reused, so frequent probes do not put load on the dependencies.
+	DefaultCacheTTL = 5 * time.Second
+)
+
+// CheckResult is the state of a single dependency.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Latency   string    `json:"latency"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Report is the state of the service.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckerOption configures optional behaviour of the Checker.
+type CheckerOption func(c *Checker)
+
+// WithTimeout limits the duration of a single check.
+func WithTimeout(timeout time.Duration) CheckerOption {
+	return func(c *Checker) { c.timeout = timeout }
+}
+
+// WithCacheTTL sets the time a result is reused.
+func WithCacheTTL(ttl time.Duration) CheckerOption {
+	return func(c *Checker) { c.cacheTTL = ttl }
+}
+
+// Checker reports the liveness and the readiness of the service. The service is ready if all checks pass and it is
+// not shutting down.
+type Checker struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+	// shuttingDown is set to 1 once the shutdown started
+	shuttingDown int32
+
+	mu      sync.Mutex
+	checks  map[string]Check
+	results map[string]CheckResult
+}
+
+// NewChecker creates a Checker without checks.
+func NewChecker(opts ...CheckerOption) *Checker {
+	c := &Checker{
+		timeout:  DefaultTimeout,
+		cacheTTL: DefaultCacheTTL,
+		checks:   make(map[string]Check),
+		results:  make(map[string]CheckResult),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Add registers the check of a dependency with the given name.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// ShutDown marks the service as not ready, so no new requests are sent to it.
+func (c *Checker) ShutDown() {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+}
+
+// Ready runs all checks concurrently and reports the state of the service.
+func (c *Checker) Ready(ctx context.Context) Report {
+	if atomic.LoadInt32(&c.shuttingDown) == 1 {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	c.mu.Lock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	sort.Strings(names)
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = c.result(ctx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusReady, Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusNotReady
+		}
+	}
+	return report
+}
+
+// result returns the cached result of the check or runs it.
+func (c *Checker) result(ctx context.Context, name string) CheckResult {
+	c.mu.Lock()
+	check := c.checks[name]
+	cached, ok := c.results[name]
+	c.mu.Unlock()
+	if ok && time.Since(cached.CheckedAt) < c.cacheTTL {
+		return cached
+	}
+
+	result := c.run(ctx, check)
+	c.mu.Lock()
+	c.results[name] = result
+	c.mu.Unlock()
+	return result
+}
+
+// run executes check with the timeout. A check that does not return in time is reported as down.
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusUp, Latency: time.Since(start).String(), CheckedAt: time.Now()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler reports that the process is able to serve requests. It does not depend on other services, so an
+// outage of a dependency does not restart the service.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, _ *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOk})
+}
+
+// ReadinessHandler reports the state of the dependencies, it responds with 503 if the service is not ready.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusReady {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(h http.HandlerFunc) (int, Report) {
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var report Report
+	json.NewDecoder(w.Body).Decode(&report)
+	return w.Code, report
+}
+
+func TestChecker_Readiness(t *testing.T) {
+	var mongoDown int32 = 1
+	checker := NewChecker(WithCacheTTL(0), WithTimeout(50*time.Millisecond))
+	checker.Add("mongo", func(context.Context) error {
+		if atomic.LoadInt32(&mongoDown) == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	checker.Add("jwks", func(context.Context) error { return nil })
+
+	code, report := serve(checker.ReadinessHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusNotReady, report.Status)
+	assert.Equal(t, StatusDown, report.Checks["mongo"].Status)
+	assert.Equal(t, "connection refused", report.Checks["mongo"].Error)
+	assert.Equal(t, StatusUp, report.Checks["jwks"].Status)
+
+	atomic.StoreInt32(&mongoDown, 0)
+	code, report = serve(checker.ReadinessHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusReady, report.Status)
+
+	// the shutdown flips readiness but not liveness
+	checker.ShutDown()
+	code, report = serve(checker.ReadinessHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusShuttingDown, report.Status)
+	code, report = serve(checker.LivenessHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOk, report.Status)
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	checker := NewChecker(WithTimeout(10 * time.Millisecond))
+	checker.Add("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	report := checker.Ready(context.Background())
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	assert.Equal(t, StatusNotReady, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+}
+
+func TestChecker_Cache(t *testing.T) {
+	var calls int32
+	checker := NewChecker(WithCacheTTL(time.Minute))
+	checker.Add("jwks", func(context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, StatusReady, checker.Ready(context.Background()).Status)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "the dependency is not asked on every probe")
+}
@@ -0,0 +1,190 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/tracing"
+	"time"
+)
+
+const (
+	// Header contains the key chosen by the client, e.g. a UUID. Retries of a request must use the same key.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set to true if the response is the stored response of an earlier request.
+	ReplayedHeader = "Idempotent-Replayed"
+	// maxKeyLength limits the size of the keys stored.
+	maxKeyLength = 255
+	// DefaultTtl is the time a key is kept if not configured otherwise.
+	DefaultTtl = 24 * time.Hour
+	// DefaultLease is the time a key is reserved for a request in progress if not configured otherwise. If the
+	// request is not completed within the lease, e.g. because the instance crashed, the key can be used again.
+	DefaultLease = time.Minute
+	// storeTimeout limits the time to store the result of a request. It is stored even if the client is gone, so the
+	// key is not reserved until the lease expires.
+	storeTimeout = 5 * time.Second
+)
+
+// Keys makes requests idempotent: the response of a request with an Idempotency-Key is stored and returned again for
+// retries with the same key. Keys are scoped to the principal and the endpoint, so clients can not see the responses of
+// others.
+type Keys struct {
+	store Store
+	ttl   time.Duration
+	lease time.Duration
+}
+
+type KeysOption func(k *Keys)
+
+// WithTtl sets the time a key is kept, DefaultTtl if not set.
+func WithTtl(ttl time.Duration) KeysOption {
+	return func(k *Keys) {
+		k.ttl = ttl
+	}
+}
+
+// WithLease sets the time a key is reserved for a request in progress, DefaultLease if not set.
+func WithLease(lease time.Duration) KeysOption {
+	return func(k *Keys) {
+		k.lease = lease
+	}
+}
+
+// NewKeys creates Keys that are persisted in store.
+func NewKeys(store Store, opts ...KeysOption) *Keys {
+	k := &Keys{store: store, ttl: DefaultTtl, lease: DefaultLease}
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
+}
+
+// Middleware replays the stored response for a known key. A key reused for a different request is rejected with 422,
+// a retry while the first request is still processed with 409 until its lease expires. Requests without key are passed
+// on unchanged. Server errors are not stored, so the request can be retried with the same key.
+func (k *Keys) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			http.Error(w, Header+" too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		logger := log.Ctx(r.Context())
+		entry := Entry{
+			Id:          scope(r, key),
+			Fingerprint: fingerprint(r, body),
+			Lease:       uuid.New().String(),
+			ExpiresAt:   time.Now().Add(k.lease),
+		}
+		existing, err := k.store.Reserve(r.Context(), entry)
+		if errors.Is(err, KeyTaken) {
+			replay(w, r, existing, entry.Fingerprint)
+			return
+		}
+		if err != nil {
+			logger.Warn().Err(err).Msg("reserving idempotency key failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		ctx, cancel := context.WithTimeout(tracing.Detach(r.Context()), storeTimeout)
+		defer cancel()
+		if recorder.status >= http.StatusInternalServerError {
+			if err := k.store.Release(ctx, entry); err != nil {
+				logger.Warn().Err(err).Msg("releasing idempotency key failed")
+			}
+			return
+		}
+		entry.Completed = true
+		entry.Status = recorder.status
+		entry.ContentType = w.Header().Get("Content-Type")
+		entry.Body = recorder.body.Bytes()
+		entry.ExpiresAt = time.Now().Add(k.ttl)
+		if err := k.store.Complete(ctx, entry); err != nil {
+			logger.Warn().Err(err).Msg("storing idempotent response failed")
+		}
+	})
+}
+
+// replay answers a request with a key already used.
+func replay(w http.ResponseWriter, r *http.Request, existing Entry, fingerprint string) {
+	if !existing.Completed {
+		http.Error(w, "request with this "+Header+" is in progress", http.StatusConflict)
+		return
+	}
+	if existing.Fingerprint != fingerprint {
+		log.Ctx(r.Context()).Info().Msg("idempotency key reused for a different request")
+		http.Error(w, Header+" already used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(existing.Status)
+	w.Write(existing.Body)
+}
+
+// scope identifies the key by the principal and the endpoint. The principal id falls back to the client id, so
+// clients without subject (client credentials) do not share their keys.
+func scope(r *http.Request, key string) string {
+	principal := ""
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+		principal = p.Id()
+	}
+	return principal + "|" + r.Method + " " + r.URL.Path + "|" + key
+}
+
+// fingerprint identifies the request by method, path and body.
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,158 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/auth"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counter answers every request with the number of requests served.
+type counter struct {
+	calls  int
+	status int
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	c.calls++
+	w.Header().Set("Content-Type", "application/json")
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+	}
+	w.Write([]byte(strconv.Itoa(c.calls)))
+}
+
+// serve sends body with key as principal subject.
+func serve(h http.Handler, subject, key, body string) *httptest.ResponseRecorder {
+	return serveAs(h, &auth.Principal{Subject: subject}, "/v1/pois", key, body)
+}
+
+// serveAs posts body to path with key as principal.
+func serveAs(h http.Handler, principal *auth.Principal, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestKeys_replay(t *testing.T) {
+	next := &counter{}
+	h := NewKeys(NewMemoryStore()).Middleware(next)
+
+	w := serve(h, "alice", "key-1", `{"name":"a"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Body.String())
+	assert.Empty(t, w.Header().Get(ReplayedHeader))
+
+	w = serve(h, "alice", "key-1", `{"name":"a"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Body.String(), "stored response")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "true", w.Header().Get(ReplayedHeader))
+	assert.Equal(t, 1, next.calls)
+
+	// keys are scoped to the principal
+	assert.Equal(t, "2", serve(h, "bob", "key-1", `{"name":"a"}`).Body.String())
+	// requests without key are not stored
+	assert.Equal(t, "3", serve(h, "alice", "", `{"name":"a"}`).Body.String())
+	assert.Equal(t, "4", serve(h, "alice", "", `{"name":"a"}`).Body.String())
+}
+
+func TestKeys_scope(t *testing.T) {
+	next := &counter{}
+	h := NewKeys(NewMemoryStore()).Middleware(next)
+
+	// client credentials have no subject, they are scoped by the client id
+	assert.Equal(t, "1", serveAs(h, &auth.Principal{ClientId: "importer"}, "/v1/pois", "key-1", `{}`).Body.String())
+	assert.Equal(t, "2", serveAs(h, &auth.Principal{ClientId: "crawler"}, "/v1/pois", "key-1", `{}`).Body.String())
+	assert.Equal(t, "1", serveAs(h, &auth.Principal{ClientId: "importer"}, "/v1/pois", "key-1", `{}`).Body.String())
+
+	// the same key on another endpoint is a different key
+	assert.Equal(t, "3", serveAs(h, &auth.Principal{ClientId: "importer"}, "/v1/other", "key-1", `{}`).Body.String())
+}
+
+func TestKeys_differentRequest(t *testing.T) {
+	h := NewKeys(NewMemoryStore()).Middleware(&counter{})
+
+	require.Equal(t, http.StatusOK, serve(h, "alice", "key-1", `{"name":"a"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(h, "alice", "key-1", `{"name":"b"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(h, "alice", strings.Repeat("k", maxKeyLength+1), `{}`).Code)
+}
+
+func TestKeys_serverErrorNotStored(t *testing.T) {
+	next := &counter{status: http.StatusInternalServerError}
+	h := NewKeys(NewMemoryStore()).Middleware(next)
+
+	assert.Equal(t, http.StatusInternalServerError, serve(h, "alice", "key-1", `{}`).Code)
+	next.status = http.StatusCreated
+	w := serve(h, "alice", "key-1", `{}`)
+	assert.Equal(t, http.StatusCreated, w.Code, "retry is processed")
+	assert.Equal(t, "2", w.Body.String())
+	assert.Equal(t, http.StatusCreated, serve(h, "alice", "key-1", `{}`).Code)
+	assert.Equal(t, 2, next.calls)
+}
+
+func TestKeys_inProgress(t *testing.T) {
+	store := NewMemoryStore()
+	_, err := store.Reserve(context.Background(), Entry{Id: "alice|POST /v1/pois|key-1", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+
+	next := &counter{}
+	assert.Equal(t, http.StatusConflict, serve(NewKeys(store).Middleware(next), "alice", "key-1", `{}`).Code)
+	assert.Zero(t, next.calls)
+}
+
+func TestKeys_leaseExpired(t *testing.T) {
+	store := NewMemoryStore()
+	_, err := store.Reserve(context.Background(), Entry{Id: "alice|POST /v1/pois|key-1", ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+
+	next := &counter{}
+	h := NewKeys(store, WithLease(time.Minute)).Middleware(next)
+	assert.Equal(t, http.StatusOK, serve(h, "alice", "key-1", `{}`).Code, "the abandoned request is taken over")
+	assert.Equal(t, 1, next.calls)
+
+	// the completed response is kept for the ttl, not only for the lease
+	assert.Equal(t, "true", serve(h, "alice", "key-1", `{}`).Header().Get(ReplayedHeader))
+}
+
+func TestKeys_expired(t *testing.T) {
+	next := &counter{}
+	h := NewKeys(NewMemoryStore(), WithTtl(-time.Second)).Middleware(next)
+
+	assert.Equal(t, "1", serve(h, "alice", "key-1", `{}`).Body.String())
+	assert.Equal(t, "2", serve(h, "alice", "key-1", `{"other":true}`).Body.String(), "expired keys can be reused")
+}
+
+func TestKeys_leaseLost(t *testing.T) {
+	for _, status := range []int{http.StatusCreated, http.StatusInternalServerError} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			store := NewMemoryStore()
+			id := "alice|POST /v1/pois|key-1"
+			// the lease expires while the request is processed and a retry reserves the key again
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := store.Reserve(r.Context(), Entry{Id: id, Lease: "retry", ExpiresAt: time.Now().Add(time.Minute)})
+				require.NoError(t, err)
+				w.WriteHeader(status)
+			})
+			h := NewKeys(store, WithLease(-time.Second)).Middleware(next)
+			assert.Equal(t, status, serve(h, "alice", "key-1", `{}`).Code)
+
+			existing, err := store.Reserve(context.Background(), Entry{Id: id, ExpiresAt: time.Now().Add(time.Minute)})
+			assert.ErrorIs(t, err, KeyTaken)
+			assert.Equal(t, "retry", existing.Lease, "the reservation of the retry is kept")
+			assert.False(t, existing.Completed)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+// KeyTaken is given by Store.Reserve if the key is already used.
+const KeyTaken = StoreError("idempotency key already used")
+
+// LeaseLost is given by Store.Complete and Store.Release if the key is no longer reserved for the request, e.g.
+// because its lease expired and a retry reserved the key again.
+const LeaseLost = StoreError("lease of idempotency key lost")
+
+type StoreError string
+
+func (e StoreError) Error() string { return string(e) }
+
+// Entry is the stored request of an idempotency key and, once completed, its response.
+type Entry struct {
+	// Id is the key given by the client, scoped to the principal and the endpoint.
+	Id string `bson:"_id"`
+	// Fingerprint is the hash of the request the key was used for first.
+	Fingerprint string `bson:"fingerprint"`
+	// Lease identifies the reservation of the key, it is new for every request that reserves the key.
+	Lease string `bson:"lease"`
+	// Completed is false while the request is processed.
+	Completed   bool   `bson:"completed"`
+	Status      int    `bson:"status,omitempty"`
+	ContentType string `bson:"contentType,omitempty"`
+	Body        []byte `bson:"body,omitempty"`
+	// ExpiresAt is the end of the lease while the request is processed and the end of the replays once completed.
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// Store persists the idempotency keys. It is shared by all instances, so a retry can be served by any of them.
+type Store interface {
+	// Reserve stores the entry if its key is unused or expired, otherwise the stored entry and KeyTaken are returned.
+	Reserve(ctx context.Context, entry Entry) (Entry, error)
+	// Complete stores the response of the entry if the key is still reserved by its lease, otherwise LeaseLost is
+	// returned.
+	Complete(ctx context.Context, entry Entry) error
+	// Release removes the entry if the key is still reserved by its lease, so the key can be used again. Otherwise
+	// LeaseLost is returned.
+	Release(ctx context.Context, entry Entry) error
+}
+
+//------------------------------------------------------------------------------
+
+// NewMemoryStore creates a Store that keeps the keys in memory only, e.g. for tests.
+func NewMemoryStore() Store {
+	return &memoryStore{entries: make(map[string]Entry), now: time.Now}
+}
+
+// memoryStore implements interface Store
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	now     func() time.Time
+}
+
+func (m *memoryStore) Reserve(_ context.Context, entry Entry) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.entries[entry.Id]; ok && existing.ExpiresAt.After(m.now()) {
+		return existing, KeyTaken
+	}
+	m.entries[entry.Id] = entry
+	return entry, nil
+}
+
+func (m *memoryStore) Complete(_ context.Context, entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.reserved(entry) {
+		return LeaseLost
+	}
+	m.entries[entry.Id] = entry
+	return nil
+}
+
+func (m *memoryStore) Release(_ context.Context, entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.reserved(entry) {
+		return LeaseLost
+	}
+	delete(m.entries, entry.Id)
+	return nil
+}
+
+// reserved returns true if the key of entry is reserved by its lease and not completed yet.
+func (m *memoryStore) reserved(entry Entry) bool {
+	existing, ok := m.entries[entry.Id]
+	return ok && existing.Lease == entry.Lease && !existing.Completed
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoStore creates a Store that persists the keys in collection. Expired keys are removed by Mongo.
+func NewMongoStore(ctx context.Context, collection *mongo.Collection) Store {
+	expiry := mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	collection.Indexes().CreateOne(ctx, expiry)
+	return &mongoStore{collection: collection}
+}
+
+// mongoStore implements interface Store
+type mongoStore struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoStore) Reserve(ctx context.Context, entry Entry) (Entry, error) {
+	_, err := m.collection.InsertOne(ctx, entry)
+	if !mongo.IsDuplicateKeyError(err) {
+		return entry, err
+	}
+
+	// Mongo removes expired keys only once a minute, until then they are replaced
+	expired := bson.M{"_id": entry.Id, "expiresAt": bson.M{"$lte": time.Now()}}
+	result, err := m.collection.ReplaceOne(ctx, expired, entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	if result.MatchedCount == 1 {
+		return entry, nil
+	}
+
+	var existing Entry
+	err = m.collection.FindOne(ctx, bson.M{"_id": entry.Id}).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// released in the meantime, the client has to retry
+		return Entry{Id: entry.Id}, KeyTaken
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	return existing, KeyTaken
+}
+
+func (m *mongoStore) Complete(ctx context.Context, entry Entry) error {
+	result, err := m.collection.ReplaceOne(ctx, reservedBy(entry), entry)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return LeaseLost
+	}
+	return nil
+}
+
+func (m *mongoStore) Release(ctx context.Context, entry Entry) error {
+	result, err := m.collection.DeleteOne(ctx, reservedBy(entry))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return LeaseLost
+	}
+	return nil
+}
+
+// reservedBy matches the key of entry while it is reserved by its lease and not completed yet.
+func reservedBy(entry Entry) bson.M {
+	return bson.M{"_id": entry.Id, "lease": entry.Lease, "completed": false}
+}
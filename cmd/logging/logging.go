@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"os"
+	"poi-service/cmd/requestid"
+	"time"
+)
+
+// Formats of the log output.
+const (
+	// FormatJson writes one JSON object per line, e.g. for log collectors.
+	FormatJson = "json"
+	// FormatConsole writes human readable lines, e.g. for local development.
+	FormatConsole = "console"
+)
+
+// UnknownFormat is given if the configured format is not supported.
+const UnknownFormat = UnknownFormatError("unknown log format")
+
+type UnknownFormatError string
+
+func (e UnknownFormatError) Error() string { return string(e) }
+
+// Setup configures the global logger with level (e.g. "debug", "info", default "info") and format (FormatJson by
+// default). Loggers taken from a context without request logger fall back to the global logger. It replaces the
+// global logger, so it must be called before logging starts; use SetLevel afterwards.
+func Setup(level, format string) error {
+	return SetupWriter(os.Stderr, level, format)
+}
+
+// SetupWriter is like Setup but writes the logs to w.
+func SetupWriter(w io.Writer, level, format string) error {
+	parsedLevel, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "", FormatJson:
+	case FormatConsole:
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339}
+	default:
+		return fmt.Errorf("%w: %s", UnknownFormat, format)
+	}
+
+	zerolog.SetGlobalLevel(parsedLevel)
+	log.Logger = zerolog.New(w).With().Timestamp().Logger()
+	zerolog.DefaultContextLogger = &log.Logger
+	return nil
+}
+
+// SetLevel changes the level of all loggers, e.g. if the configuration is reloaded. Unlike Setup it only changes an
+// atomic value, so it is safe to call while logging.
+func SetLevel(level string) error {
+	parsedLevel, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(parsedLevel)
+	return nil
+}
+
+func parseLevel(level string) (zerolog.Level, error) {
+	if level == "" {
+		return zerolog.InfoLevel, nil
+	}
+	return zerolog.ParseLevel(level)
+}
+
+// AddFields adds fields to the request logger of ctx, e.g. once the caller is known. Nothing is changed if ctx carries
+// no request logger, so the global logger is never modified.
+func AddFields(ctx context.Context, update func(c zerolog.Context) zerolog.Context) {
+	if logger := zerolog.Ctx(ctx); logger != zerolog.DefaultContextLogger {
+		logger.UpdateContext(update)
+	}
+}
+
+// Middleware attaches a logger to the context of the request that carries the request id, the route and the trace
+// id. Every request is logged with its status and latency once it is handled. It must be used by the mux.Router
+// after requestid.Middleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logContext := log.Logger.With().
+			Str("requestId", requestid.FromContext(r.Context())).
+			Str("method", r.Method)
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				logContext = logContext.Str("route", template)
+			}
+		}
+		if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+			logContext = logContext.Str("traceId", span.TraceID().String())
+		}
+		logger := logContext.Logger()
+
+		// the context refers to logger, so the fields added by later layers like the principal are logged as well
+		m := httpsnoop.CaptureMetrics(next, w, r.WithContext(logger.WithContext(r.Context())))
+
+		event := logger.Info()
+		if m.Code >= http.StatusInternalServerError {
+			event = logger.Warn()
+		}
+		event.Int("status", m.Code).Dur("latency", m.Duration).Int64("bytes", m.Written).Msg("request handled")
+	})
+}
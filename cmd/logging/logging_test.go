@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"poi-service/cmd/requestid"
+)
+
+func TestSetupWriter(t *testing.T) {
+	defer SetupWriter(&bytes.Buffer{}, "", "")
+
+	var buf bytes.Buffer
+	require.NoError(t, SetupWriter(&buf, "warn", FormatJson))
+	log.Info().Msg("hidden")
+	log.Warn().Msg("shown")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), `"message":"shown"`)
+
+	buf.Reset()
+	require.NoError(t, SetupWriter(&buf, "debug", FormatConsole))
+	log.Debug().Msg("readable")
+	assert.Contains(t, buf.String(), "readable")
+	assert.NotContains(t, buf.String(), `"message"`)
+
+	assert.Error(t, SetupWriter(&buf, "loud", ""))
+	assert.ErrorIs(t, SetupWriter(&buf, "", "xml"), UnknownFormat)
+}
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, SetupWriter(&buf, "info", FormatJson))
+	defer SetupWriter(&bytes.Buffer{}, "", "")
+
+	require.NoError(t, SetLevel("debug"))
+	log.Debug().Msg("shown")
+	require.NoError(t, SetLevel(""))
+	log.Debug().Msg("hidden")
+	assert.Contains(t, buf.String(), `"message":"shown"`)
+	assert.NotContains(t, buf.String(), "hidden")
+
+	assert.Error(t, SetLevel("loud"))
+	assert.Equal(t, zerolog.InfoLevel, zerolog.GlobalLevel())
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, SetupWriter(&buf, "debug", FormatJson))
+	defer SetupWriter(&bytes.Buffer{}, "", "")
+
+	r := mux.NewRouter()
+	r.Use(requestid.Middleware, Middleware)
+	r.HandleFunc("/v1/pois/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// fields added by later layers are part of all following logs including the access log
+		AddFields(r.Context(), func(c zerolog.Context) zerolog.Context {
+			return c.Str("principal", "alice")
+		})
+		log.Ctx(r.Context()).Info().Msg("handling")
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodGet)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/pois/42", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "req-1", entry["requestId"])
+		assert.Equal(t, "/v1/pois/{id}", entry["route"])
+		assert.Equal(t, "alice", entry["principal"])
+	}
+
+	var access map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+	assert.Equal(t, "request handled", access["message"])
+	assert.Equal(t, float64(http.StatusNotFound), access["status"])
+	assert.Contains(t, access, "latency")
+}
+
+func TestAddFields_withoutRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, SetupWriter(&buf, "info", FormatJson))
+	defer SetupWriter(&bytes.Buffer{}, "", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	AddFields(req.Context(), func(c zerolog.Context) zerolog.Context {
+		return c.Str("principal", "alice")
+	})
+	log.Info().Msg("global")
+
+	// the global logger must not collect the fields of single requests
+	assert.NotContains(t, buf.String(), "alice")
+}
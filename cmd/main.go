@@ -2,236 +2,121 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 	"net/http"
 	"os"
 	"os/signal"
-	"poi-service/cmd/auth"
-	"poi-service/cmd/data"
-	"poi-service/cmd/download"
-	"poi-service/cmd/handler"
+	"poi-service/cmd/app"
+	"poi-service/cmd/config"
+	"poi-service/cmd/logging"
+	"poi-service/cmd/tracing"
+	"syscall"
 	"time"
 )
 
-// used components
-var (
-	poiHandler         handler.PoiHandler
-	dbHandler          handler.DbHandler
-	credentialUsername string
-	credentialPw       string
-	authorizer         auth.Authorizer
-	jwkStore           auth.JwkStore
-	httpClient         download.HttpRequester
-)
-
-// init is the reserved golang function that will initialize all components once.
-func init() {
-	mongodb := os.Getenv("DATABASE_URL")
-	dbHandler, err := handler.NewDbHandler(mongodb)
+func main() {
+	source, err := config.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse flags")
+		return
+	}
+	cfg, err := source.Load()
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create dbHandler")
+		log.Fatal().Err(err).Msg("Failed to load configuration")
 		return
 	}
-	poiHandler = handler.NewPoiHandler(dbHandler)
-	httpClient = download.NewHttpRequester(http.DefaultClient)
-	jwkCache := auth.JwkCache{}
-	jwkCache.Init()
-	jwkStore = auth.NewJwkStore("http://127.0.0.1:4444/", httpClient, jwkCache)
-	authorizer = auth.NewAuthorizer(jwkStore)
+	// settings provides the configuration including the settings reloaded while running
+	settings := config.NewWatcher(source, cfg)
 
-	if poiHandler == nil {
-		log.Fatal().Msg("poiHandler is nil")
+	if err := logging.Setup(cfg.Log.Level, cfg.Log.Format); err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up logging")
 		return
 	}
+	settings.OnReload(func(c config.Config) {
+		// only the level is reloaded, the global logger must not be replaced while it is in use
+		if err := logging.SetLevel(c.Log.Level); err != nil {
+			log.Warn().Err(err).Msg("reconfiguring logging failed")
+		}
+	})
+	log.Info().Interface("config", cfg.Redacted().Settings()).Msg("effective configuration")
 
-	if authorizer == nil {
-		log.Fatal().Msg("authorizer is nil")
+	// the exporter is set up first, so the setup of the other components can already be traced
+	shutdownTracing, err := tracing.Setup(context.Background(), app.ServiceName, cfg.Tracing.Exporter)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+		return
+	}
+
+	svc, err := newService(context.Background(), cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create service")
 		return
 	}
-}
 
-func main() {
 	quit := make(chan os.Signal, 1)
 	defer close(quit)
 	signal.Notify(quit, os.Interrupt)
 	res := make(chan error, 1)
 	defer close(res)
 
-	port := os.Getenv("SERVICE_PORT")
-	log.Printf("Listening in port %s", port)
+	// background tasks run until the server stops
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	svc.runBackground(backgroundCtx, settings)
+
+	// the config file is reloaded if it changes or on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	go settings.Run(backgroundCtx)
+	go func() {
+		for {
+			select {
+			case <-backgroundCtx.Done():
+				return
+			case <-reload:
+				if _, err := settings.Reload(); err != nil {
+					log.Warn().Err(err).Msg("reloading config failed")
+				}
+			}
+		}
+	}()
+
+	port := cfg.Service.Port
+	log.Info().Str("port", port).Msg("listening")
 
 	s := http.Server{
 		Addr:    ":" + port,
-		Handler: createRootHandler(),
+		Handler: svc.app.Router(),
 	}
 	go func() {
-		res <- s.ListenAndServe()
+		if svc.tlsReloader == nil {
+			res <- s.ListenAndServe()
+			return
+		}
+		s.TLSConfig = svc.tlsReloader.TLSConfig()
+		res <- s.ListenAndServeTLS("", "")
 	}()
 
 	select {
 	case <-quit:
 		log.Info().Msg("user initiated termination of server started")
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		// requests are still served for the delay after the service reported not ready
+		svc.components.Health.ShutDown()
+		time.Sleep(settings.Current().Service.ShutdownDelay.Duration())
+		ctx, cancel := context.WithTimeout(context.Background(), settings.Current().Service.ShutdownTimeout.Duration())
 		defer cancel()
 		err := s.Shutdown(ctx)
 		if err != nil {
 			log.Error().Err(err).Msg("graceful shutdown failed")
 		}
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("flushing traces failed")
+		}
+		if err := svc.close(ctx); err != nil {
+			log.Error().Err(err).Msg("disconnecting from mongodb failed")
+		}
 	case err := <-res:
 		log.Error().Err(err).Msg("server stopped with error")
 	}
 }
-
-func createRootHandler() http.Handler {
-
-	r := mux.NewRouter()
-	api := r.PathPrefix("/v1").Subrouter()
-	api.Use(authorizer.Authorize)
-	api.HandleFunc("/pois/{id}", getPoi).Methods(http.MethodGet)
-	api.HandleFunc("/pois", createPoi).Methods(http.MethodPost)
-	api.HandleFunc("/pois/{id}", deletePoi).Methods(http.MethodDelete)
-	api.HandleFunc("/pois/{id}", updatePoi).Methods(http.MethodPut)
-	api.HandleFunc("/pois/list", listPoi).Methods(http.MethodPost)
-	return r
-}
-
-func createPoi(rw http.ResponseWriter, r *http.Request) {
-	var poi data.Poi
-	if err := decode(r, &poi); err != nil {
-		rw.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	id, err := poiHandler.Create(&poi)
-	if err != nil {
-		log.Warn().Err(err).Msg("createPoi failed")
-		rw.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if err := encode(rw, &id); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	rw.WriteHeader(http.StatusOK)
-	return
-}
-
-func updatePoi(rw http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	if _, ok := params["id"]; !ok {
-		rw.WriteHeader(http.StatusInternalServerError)
-		log.Warn().Msg("id not available in path")
-		return
-	}
-
-	var poi data.Poi
-	if err := decode(r, &poi); err != nil {
-		rw.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	if err := poiHandler.Update(data.Id(params["id"]), &poi); err != nil {
-		log.Warn().Err(err).Msg("updatePoi failed")
-		rw.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	rw.WriteHeader(http.StatusOK)
-	return
-}
-
-func getPoi(rw http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	if _, ok := params["id"]; !ok {
-		rw.WriteHeader(http.StatusInternalServerError)
-		log.Warn().Msg("getPoi id not available in path")
-		return
-	}
-
-	resp, err := poiHandler.Get(data.Id(params["id"]))
-	if err != nil {
-		log.Warn().Err(err).Msg("getPoi failed")
-		rw.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if err := encode(rw, &resp); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	rw.WriteHeader(http.StatusOK)
-	return
-}
-
-func listPoi(rw http.ResponseWriter, r *http.Request) {
-	var area data.SearchArea
-
-	// if provided set a search area
-	if r.ContentLength > 0 {
-		if err := decode(r, &area); err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			return
-		}
-	}
-
-	resp, err := poiHandler.Search(area)
-	if err != nil {
-		log.Warn().Err(err).Msg("listPoi failed")
-		rw.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if err := encode(rw, &resp); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	rw.WriteHeader(http.StatusOK)
-	return
-}
-
-func deletePoi(rw http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	if _, ok := params["id"]; !ok {
-		rw.WriteHeader(http.StatusInternalServerError)
-		log.Warn().Msg("id not available in path")
-		return
-	}
-
-	if err := poiHandler.Delete(data.Id(params["id"])); err != nil {
-		log.Warn().Err(err).Msg("deletePoi failed")
-		rw.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	rw.WriteHeader(http.StatusOK)
-	return
-}
-
-func decode(r *http.Request, poi interface{}) (err error) {
-	if poi == nil {
-		return errors.New("is nil")
-	}
-	err = json.NewDecoder(r.Body).Decode(poi)
-	if err != nil {
-		log.Warn().Err(err).Msg("json decoding failed:")
-	}
-	return
-}
-
-func encode(rw http.ResponseWriter, poi interface{}) (err error) {
-	if poi == nil {
-		return errors.New("poi is nil")
-	}
-	err = json.NewEncoder(rw).Encode(poi)
-	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-	}
-	return
-}
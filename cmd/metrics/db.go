@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"poi-service/cmd/handler"
+	"time"
+)
+
+// NewDbHandler decorates next, so the latency and the result of every operation is measured.
+func NewDbHandler(next handler.DbHandler) handler.DbHandler {
+	if next == nil {
+		return nil
+	}
+	return &dbHandler{next: next}
+}
+
+// dbHandler implements interface handler.DbHandler
+type dbHandler struct {
+	next handler.DbHandler
+}
+
+// observe records an operation that started at start and ended with err.
+func observe(operation string, start time.Time, err error) {
+	dbDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+	result := "ok"
+	var notFound handler.PoiNotFoundError
+	var conflict handler.ConflictError
+	if errors.As(err, &notFound) {
+		result = "not_found"
+	} else if errors.As(err, &conflict) {
+		result = "conflict"
+	} else if err != nil {
+		result = "error"
+	}
+	dbOperations.WithLabelValues(operation, result).Inc()
+}
+
+func (d *dbHandler) AddPoi(ctx context.Context, poi handler.PoiDbEntry) (id string, err error) {
+	start := time.Now()
+	id, err = d.next.AddPoi(ctx, poi)
+	observe("AddPoi", start, err)
+	return
+}
+
+func (d *dbHandler) GetPoi(ctx context.Context, id string) (poi handler.PoiDbEntry, err error) {
+	start := time.Now()
+	poi, err = d.next.GetPoi(ctx, id)
+	observe("GetPoi", start, err)
+	return
+}
+
+func (d *dbHandler) UpdatePoi(ctx context.Context, id string, poi handler.PoiDbEntry) (err error) {
+	start := time.Now()
+	err = d.next.UpdatePoi(ctx, id, poi)
+	observe("UpdatePoi", start, err)
+	return
+}
+
+func (d *dbHandler) DeletePoi(ctx context.Context, id string) (err error) {
+	start := time.Now()
+	err = d.next.DeletePoi(ctx, id)
+	observe("DeletePoi", start, err)
+	return
+}
+
+func (d *dbHandler) SearchByRadius(ctx context.Context, location handler.Location, distanceInMeter uint64, limit int64) (result handler.PoiDbEntries, err error) {
+	start := time.Now()
+	result, err = d.next.SearchByRadius(ctx, location, distanceInMeter, limit)
+	observe("SearchByRadius", start, err)
+	return
+}
+
+func (d *dbHandler) GetAllPois(ctx context.Context) (result handler.PoiDbEntries, err error) {
+	start := time.Now()
+	result, err = d.next.GetAllPois(ctx)
+	observe("GetAllPois", start, err)
+	return
+}
+
+func (d *dbHandler) GetDeletedPois(ctx context.Context) (result handler.PoiDbEntries, err error) {
+	start := time.Now()
+	result, err = d.next.GetDeletedPois(ctx)
+	observe("GetDeletedPois", start, err)
+	return
+}
+
+func (d *dbHandler) RestorePoi(ctx context.Context, id string) (err error) {
+	start := time.Now()
+	err = d.next.RestorePoi(ctx, id)
+	observe("RestorePoi", start, err)
+	return
+}
+
+func (d *dbHandler) PurgeDeletedPois(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	start := time.Now()
+	purged, err = d.next.PurgeDeletedPois(ctx, deletedBefore)
+	observe("PurgeDeletedPois", start, err)
+	return
+}
+
+func (d *dbHandler) GetRevisions(ctx context.Context, id string) (result handler.PoiRevisions, err error) {
+	start := time.Now()
+	result, err = d.next.GetRevisions(ctx, id)
+	observe("GetRevisions", start, err)
+	return
+}
+
+func (d *dbHandler) GetRevision(ctx context.Context, id string, revision int) (result handler.PoiRevision, err error) {
+	start := time.Now()
+	result, err = d.next.GetRevision(ctx, id, revision)
+	observe("GetRevision", start, err)
+	return
+}
+
+func (d *dbHandler) GetPoiAsOf(ctx context.Context, id string, asOf time.Time) (result handler.PoiRevision, err error) {
+	start := time.Now()
+	result, err = d.next.GetPoiAsOf(ctx, id, asOf)
+	observe("GetPoiAsOf", start, err)
+	return
+}
+
+func (d *dbHandler) GetPoiByExternalId(ctx context.Context, source, externalId string) (poi handler.PoiDbEntry, err error) {
+	start := time.Now()
+	poi, err = d.next.GetPoiByExternalId(ctx, source, externalId)
+	observe("GetPoiByExternalId", start, err)
+	return
+}
+
+func (d *dbHandler) UpsertPoi(ctx context.Context, poi handler.PoiDbEntry) (id string, created bool, err error) {
+	start := time.Now()
+	id, created, err = d.next.UpsertPoi(ctx, poi)
+	observe("UpsertPoi", start, err)
+	return
+}
+
+func (d *dbHandler) MergePoi(ctx context.Context, keep, merge string) (err error) {
+	start := time.Now()
+	err = d.next.MergePoi(ctx, keep, merge)
+	observe("MergePoi", start, err)
+	return
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_dbHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	next := handler.NewMockDbHandler(ctrl)
+	dbHandlerToTest := NewDbHandler(next)
+	assert.Nil(t, NewDbHandler(nil))
+
+	count := func(operation, result string) float64 {
+		return testutil.ToFloat64(dbOperations.WithLabelValues(operation, result))
+	}
+	okBefore, notFoundBefore, errorBefore := count("GetPoi", "ok"), count("GetPoi", "not_found"), count("DeletePoi", "error")
+
+	entry := handler.PoiDbEntry{Id: "abc", Name: "Dresden"}
+	next.EXPECT().GetPoi(ctx, "abc").Return(entry, nil)
+	next.EXPECT().GetPoi(ctx, "other").Return(handler.PoiDbEntry{}, handler.PoiNotFound)
+	next.EXPECT().DeletePoi(ctx, "abc").Return(errors.New("connection lost"))
+
+	result, err := dbHandlerToTest.GetPoi(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, entry, result)
+	_, err = dbHandlerToTest.GetPoi(ctx, "other")
+	assert.Equal(t, handler.PoiNotFound, err)
+	assert.Error(t, dbHandlerToTest.DeletePoi(ctx, "abc"))
+
+	assert.Equal(t, okBefore+1, count("GetPoi", "ok"))
+	assert.Equal(t, notFoundBefore+1, count("GetPoi", "not_found"))
+	assert.Equal(t, errorBefore+1, count("DeletePoi", "error"))
+	assert.Equal(t, 2, testutil.CollectAndCount(dbDuration), "one series per operation")
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Middleware)
+	r.HandleFunc("/v1/pois/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// streaming handlers must still be able to flush
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		w.Write([]byte("ok"))
+	}).Methods(http.MethodGet)
+
+	found := httpRequests.WithLabelValues("/v1/pois/{id}", http.MethodGet, "200")
+	missing := httpRequests.WithLabelValues("/v1/pois/{id}", http.MethodGet, "404")
+	foundBefore, missingBefore := testutil.ToFloat64(found), testutil.ToFloat64(missing)
+
+	for _, id := range []string{"a", "b", "missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/pois/"+id, nil))
+	}
+
+	// the route is used instead of the path, so the ids do not create new series
+	assert.Equal(t, foundBefore+2, testutil.ToFloat64(found))
+	assert.Equal(t, missingBefore+1, testutil.ToFloat64(missing))
+}
+
+func TestHandler(t *testing.T) {
+	ObserveAuth("expired")
+	ObserveJwksCache(true)
+	ObserveJwksFetch(nil)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	for _, expected := range []string{
+		`poi_auth_requests_total{outcome="expired"}`,
+		`poi_jwks_cache_requests_total{result="hit"}`,
+		`poi_jwks_fetches_total{result="success"}`,
+		"go_goroutines",
+	} {
+		assert.True(t, strings.Contains(body, expected), expected)
+	}
+}
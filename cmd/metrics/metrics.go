@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+// namespace prefixes all metrics of the service.
+const namespace = "poi"
+
+// Registry contains all metrics of the service including the Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	authOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_requests_total",
+		Help:      "Number of authentications by outcome, either ok or the reason of the rejection.",
+	}, []string{"outcome"})
+
+	jwksCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jwks_cache_requests_total",
+		Help:      "Number of JWK lookups by result, hit or miss.",
+	}, []string{"result"})
+
+	jwksFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jwks_fetches_total",
+		Help:      "Number of JWKS downloads by result, success or error.",
+	}, []string{"result"})
+
+	dbOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_operations_total",
+		Help:      "Number of database operations by operation and result, ok, not_found or error.",
+	}, []string{"operation", "result"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_operation_duration_seconds",
+		Help:      "Latency of database operations by operation.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, authOutcomes, jwksCache, jwksFetches, dbOperations, dbDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveAuth counts an authentication with its outcome.
+func ObserveAuth(outcome string) {
+	authOutcomes.WithLabelValues(outcome).Inc()
+}
+
+// ObserveJwksCache counts a lookup of the JWK cache.
+func ObserveJwksCache(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	jwksCache.WithLabelValues(result).Inc()
+}
+
+// ObserveJwksFetch counts a download of the JWKS.
+func ObserveJwksFetch(err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	jwksFetches.WithLabelValues(result).Inc()
+}
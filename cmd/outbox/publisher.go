@@ -0,0 +1,77 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"poi-service/cmd/events"
+	"sync"
+)
+
+// Publisher delivers the events of the outbox. Publish must only return nil once the event is delivered, otherwise
+// it is published again. Events may be published more than once, consumers can detect duplicates by the event id.
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event) error
+}
+
+// PublisherFunc allows to use a function as Publisher.
+type PublisherFunc func(ctx context.Context, event events.Event) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event events.Event) error { return f(ctx, event) }
+
+// NewBusPublisher publishes the events to bus, so they reach the stream and the webhooks.
+func NewBusPublisher(bus *events.Bus) Publisher {
+	return PublisherFunc(func(ctx context.Context, event events.Event) error {
+		bus.Publish(ctx, event)
+		return nil
+	})
+}
+
+// NewChannelPublisher sends the events to ch. Publish blocks until the event is received or ctx is done.
+func NewChannelPublisher(ch chan<- events.Event) Publisher {
+	return PublisherFunc(func(ctx context.Context, event events.Event) error {
+		select {
+		case ch <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+//------------------------------------------------------------------------------
+
+// FilePublisher appends the events as JSON lines to a local file.
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFilePublisher opens or creates the file at path for appending.
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePublisher{file: file}, nil
+}
+
+// Publish returns after the event is synced to disk.
+func (f *FilePublisher) Publish(_ context.Context, event events.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err = f.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file.
+func (f *FilePublisher) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"poi-service/cmd/events"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	publisher, err := NewFilePublisher(path)
+	require.NoError(t, err)
+
+	first := events.NewEvent(events.Created, "a", nil)
+	second := events.NewEvent(events.Deleted, "a", nil)
+	require.NoError(t, publisher.Publish(context.Background(), first))
+	require.NoError(t, publisher.Close())
+
+	// the file is appended
+	publisher, err = NewFilePublisher(path)
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(context.Background(), second))
+	require.NoError(t, publisher.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event events.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		ids = append(ids, event.Id)
+	}
+	assert.Equal(t, []string{first.Id, second.Id}, ids)
+}
+
+func TestBusPublisher(t *testing.T) {
+	bus := events.NewBus()
+	ch, cancel := bus.Subscribe(1)
+	defer cancel()
+
+	event := events.NewEvent(events.Updated, "a", nil)
+	require.NoError(t, NewBusPublisher(bus).Publish(context.Background(), event))
+	assert.Equal(t, event, <-ch)
+}
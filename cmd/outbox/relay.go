@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"poi-service/cmd/events"
+	"poi-service/cmd/handler"
+	"time"
+)
+
+// Defaults of the relay.
+const (
+	DefaultInterval   = time.Second
+	DefaultBatchSize  = 100
+	DefaultMaxBackoff = time.Minute
+)
+
+// RelayOption configures optional behaviour of the Relay.
+type RelayOption func(r *Relay)
+
+// WithInterval sets the interval in which the outbox is checked for new entries.
+func WithInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) { r.interval = interval }
+}
+
+// WithBatchSize sets the number of entries read at once.
+func WithBatchSize(size int) RelayOption {
+	return func(r *Relay) { r.batchSize = size }
+}
+
+// WithMaxBackoff limits the wait time after failures, it doubles with every failure starting with the interval.
+func WithMaxBackoff(max time.Duration) RelayOption {
+	return func(r *Relay) { r.maxBackoff = max }
+}
+
+// Relay publishes the entries of the outbox. An entry is removed only after it is published, so every change is
+// published at least once, even if the service stops in between.
+type Relay struct {
+	store      Store
+	publisher  Publisher
+	interval   time.Duration
+	batchSize  int
+	maxBackoff time.Duration
+}
+
+// NewRelay creates a Relay that publishes the entries of store with publisher.
+func NewRelay(store Store, publisher Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{
+		store:      store,
+		publisher:  publisher,
+		interval:   DefaultInterval,
+		batchSize:  DefaultBatchSize,
+		maxBackoff: DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run publishes the entries until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	wait := r.interval
+	for {
+		if _, err := r.Flush(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			wait *= 2
+			if wait > r.maxBackoff {
+				wait = r.maxBackoff
+			}
+			log.Ctx(ctx).Warn().Err(err).Dur("backoff", wait).Msg("publishing outbox failed")
+		} else {
+			wait = r.interval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Flush publishes all pending entries in order and returns the number of published entries. It stops at the first
+// failure, so the order of the changes is kept.
+func (r *Relay) Flush(ctx context.Context) (published int, err error) {
+	for {
+		entries, err := r.store.Pending(ctx, r.batchSize)
+		if err != nil || len(entries) == 0 {
+			return published, err
+		}
+
+		for _, entry := range entries {
+			event, ok := eventFromEntry(entry)
+			if ok {
+				if err = r.publisher.Publish(ctx, event); err != nil {
+					return published, err
+				}
+			} else {
+				log.Ctx(ctx).Warn().Str("id", entry.Id).Str("change", string(entry.Change)).Msg("dropping unknown outbox entry")
+			}
+			if err = r.store.Delete(ctx, entry.Id); err != nil {
+				return published, err
+			}
+			if ok {
+				published++
+			}
+		}
+	}
+}
+
+// eventFromEntry maps an entry to an event. The event keeps the id of the entry, so consumers can detect duplicates.
+func eventFromEntry(entry handler.OutboxEntry) (events.Event, bool) {
+	var eventType events.Type
+	switch entry.Change {
+	case handler.PoiCreated:
+		eventType = events.Created
+	case handler.PoiUpdated:
+		eventType = events.Updated
+	case handler.PoiDeleted:
+		eventType = events.Deleted
+	case handler.PoiRestored:
+		eventType = events.Restored
+	default:
+		return events.Event{}, false
+	}
+
+	event := events.Event{
+		Id:    entry.Id,
+		Type:  eventType,
+		PoiId: entry.Poi.Id,
+		Time:  entry.CreatedAt,
+	}
+	if len(entry.Poi.Location.Coordinates) == 2 {
+		poi := entry.Poi.Poi()
+		event.Poi = &poi
+	}
+	return event, true
+}
@@ -0,0 +1,114 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"poi-service/cmd/events"
+	"poi-service/cmd/handler"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEntry(change handler.ChangeType, id string, createdAt time.Time) handler.OutboxEntry {
+	entry := handler.NewOutboxEntry(change, handler.PoiDbEntry{Id: id, Name: id, Location: handler.NewLocation(51.05, 13.73)})
+	entry.CreatedAt = createdAt
+	return entry
+}
+
+func TestRelay_Flush(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	created := newEntry(handler.PoiCreated, "a", now)
+	store.Add(newEntry(handler.PoiDeleted, "a", now.Add(2*time.Second)))
+	store.Add(created)
+	store.Add(newEntry(handler.PoiUpdated, "a", now.Add(time.Second)))
+	store.Add(newEntry("unknown", "a", now.Add(3*time.Second)))
+
+	ch := make(chan events.Event, 10)
+	relay := NewRelay(store, NewChannelPublisher(ch), WithBatchSize(2))
+
+	published, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, published)
+
+	for _, expected := range []events.Type{events.Created, events.Updated, events.Deleted} {
+		event := <-ch
+		assert.Equal(t, expected, event.Type)
+		assert.Equal(t, "a", event.PoiId)
+		require.NotNil(t, event.Poi)
+		assert.Equal(t, created.Poi.Poi(), *event.Poi)
+	}
+	pending, _ := store.Pending(context.Background(), 10)
+	assert.Empty(t, pending, "unknown entries are dropped")
+
+	// the event keeps the id of the entry, so consumers can detect duplicates
+	store.Add(created)
+	_, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, created.Id, (<-ch).Id)
+}
+
+func TestRelay_FlushKeepsFailedEntries(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.Add(newEntry(handler.PoiCreated, "a", now))
+	store.Add(newEntry(handler.PoiCreated, "b", now.Add(time.Second)))
+
+	var published []string
+	fail := true
+	relay := NewRelay(store, PublisherFunc(func(_ context.Context, event events.Event) error {
+		if event.PoiId == "b" && fail {
+			return errors.New("unavailable")
+		}
+		published = append(published, event.PoiId)
+		return nil
+	}))
+
+	count, err := relay.Flush(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 1, count)
+	pending, _ := store.Pending(context.Background(), 10)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "b", pending[0].Poi.Id)
+
+	fail = false
+	_, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, published)
+}
+
+func TestRelay_Run(t *testing.T) {
+	store := NewMemoryStore()
+	ch := make(chan events.Event)
+	relay := NewRelay(store, NewChannelPublisher(ch), WithInterval(5*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	store.Add(newEntry(handler.PoiRestored, "a", time.Now()))
+	select {
+	case event := <-ch:
+		assert.Equal(t, events.Restored, event.Type)
+	case <-time.After(time.Second):
+		require.Fail(t, "entry not published")
+	}
+
+	// a blocked publisher is released by the cancellation
+	store.Add(newEntry(handler.PoiCreated, "b", time.Now()))
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "relay did not stop")
+	}
+	pending, _ := store.Pending(context.Background(), 10)
+	assert.Len(t, pending, 1)
+}
@@ -0,0 +1,90 @@
+package outbox
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poi-service/cmd/handler"
+	"sort"
+	"sync"
+)
+
+// Store gives the relay access to the entries of the outbox. The entries are written by the DbHandler, see
+// handler.WithOutbox.
+type Store interface {
+	// Pending returns at most limit entries that are not published yet, the oldest first.
+	Pending(ctx context.Context, limit int) ([]handler.OutboxEntry, error)
+	// Delete removes a published entry.
+	Delete(ctx context.Context, id string) error
+}
+
+//------------------------------------------------------------------------------
+
+// NewMemoryStore creates a Store that keeps the entries in memory only, e.g. for tests.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]handler.OutboxEntry)}
+}
+
+// MemoryStore implements interface Store
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]handler.OutboxEntry
+}
+
+// Add stores entry, the memory store has no transactions.
+func (m *MemoryStore) Add(entry handler.OutboxEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.Id] = entry
+}
+
+func (m *MemoryStore) Pending(_ context.Context, limit int) ([]handler.OutboxEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]handler.OutboxEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, id)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoStore creates a Store for the outbox in collection.
+func NewMongoStore(collection *mongo.Collection) Store {
+	return &mongoStore{collection: collection}
+}
+
+// mongoStore implements interface Store
+type mongoStore struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoStore) Pending(ctx context.Context, limit int) (result []handler.OutboxEntry, err error) {
+	cur, err := m.collection.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return
+	}
+	result = []handler.OutboxEntry{}
+	err = cur.All(ctx, &result)
+	return
+}
+
+func (m *mongoStore) Delete(ctx context.Context, id string) error {
+	// an entry published twice by concurrent relays is already gone, which is fine
+	_, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
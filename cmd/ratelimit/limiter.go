@@ -0,0 +1,235 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"math"
+	"net"
+	"net/http"
+	"poi-service/cmd/auth"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers of the responses, see https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+const (
+	// HeaderLimit is the number of requests a client can send at once.
+	HeaderLimit = "RateLimit-Limit"
+	// HeaderRemaining is the number of requests the client can still send at once.
+	HeaderRemaining = "RateLimit-Remaining"
+	// HeaderReset is the number of seconds until the limit is available completely again.
+	HeaderReset = "RateLimit-Reset"
+)
+
+// idleTimeout is the time after which the bucket of an inactive client is removed.
+const idleTimeout = 10 * time.Minute
+
+// Limit describes how many requests a client may send.
+type Limit struct {
+	// Rate is the number of requests per second that are refilled into the bucket of a client.
+	Rate float64 `json:"rate" yaml:"rate" toml:"rate"`
+	// Burst is the size of the bucket, i.e. the number of requests a client can send at once.
+	Burst int `json:"burst" yaml:"burst" toml:"burst"`
+	// DailyQuota limits the requests of a client per day (UTC), 0 means unlimited.
+	DailyQuota int64 `json:"dailyQuota" yaml:"dailyQuota" toml:"dailyQuota"`
+}
+
+// Policy describes the limits of all routes.
+type Policy struct {
+	// Default applies to all routes without own limit.
+	Default Limit
+	// Routes contains the limits of single routes by method and path template, e.g. "POST /v1/pois/list". Each
+	// route has its own buckets and quotas.
+	Routes map[string]Limit
+}
+
+// limitFor returns the limit of the route and the name its buckets and quotas are kept under.
+func (p Policy) limitFor(route string) (name string, limit Limit) {
+	if limit, ok := p.Routes[route]; ok {
+		return route, limit
+	}
+	return "*", p.Default
+}
+
+// Limiter limits the requests of every client by a token bucket and a daily quota. Clients are identified by the
+// authenticated principal, or by their IP address if the request is not authenticated or ByIp is set.
+type Limiter struct {
+	policy Policy
+	quotas QuotaStore
+	now    func() time.Time
+	byIp   bool
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type LimiterOption func(l *Limiter)
+
+// WithClock replaces the clock, e.g. for tests.
+func WithClock(now func() time.Time) LimiterOption {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+// ByIp identifies the clients by their IP address only, so the Limiter can be used before the authorization and
+// limits the requests that are not authenticated as well.
+func ByIp() LimiterOption {
+	return func(l *Limiter) {
+		l.byIp = true
+	}
+}
+
+// NewLimiter creates a Limiter for policy that counts the daily quotas in quotas.
+func NewLimiter(policy Policy, quotas QuotaStore, opts ...LimiterOption) *Limiter {
+	l := &Limiter{
+		policy:  policy,
+		quotas:  quotas,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Middleware rejects the requests of clients that exceeded their limit with 429 and Retry-After. All other
+// responses carry the RateLimit-* headers. Unless the clients are identified ByIp it must be used after the
+// authorization, so the principal is known.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, limit := l.policy.limitFor(routeOf(r))
+		client := l.clientOf(r)
+		now := l.now()
+
+		allowed, remaining, wait, reset := l.take(name+"|"+client, limit, now)
+		w.Header().Set(HeaderLimit, strconv.Itoa(limit.Burst))
+		w.Header().Set(HeaderRemaining, strconv.Itoa(remaining))
+		w.Header().Set(HeaderReset, seconds(reset))
+		if !allowed {
+			log.Ctx(r.Context()).Info().Str("client", client).Str("route", name).Msg("rate limit exceeded")
+			tooManyRequests(w, wait)
+			return
+		}
+
+		if limit.DailyQuota > 0 && l.quotas != nil {
+			day := now.UTC().Truncate(24 * time.Hour)
+			count, err := l.quotas.Increment(r.Context(), QuotaKey{Client: client, Route: name, Day: day})
+			// the service stays available if the quotas can not be counted
+			if err != nil {
+				log.Ctx(r.Context()).Warn().Err(err).Msg("counting quota failed")
+			} else if count > limit.DailyQuota {
+				log.Ctx(r.Context()).Info().Str("client", client).Str("route", name).Msg("daily quota exceeded")
+				untilTomorrow := day.Add(24 * time.Hour).Sub(now)
+				w.Header().Set(HeaderRemaining, "0")
+				w.Header().Set(HeaderReset, seconds(untilTomorrow))
+				tooManyRequests(w, untilTomorrow)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take removes a token from the bucket of key. It returns the tokens left, the time until the next token is available
+// if the request is not allowed and the time until the bucket is full again.
+func (l *Limiter) take(key string, limit Limit, now time.Time) (allowed bool, remaining int, wait, reset time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(limit, now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		wait = toDuration((1 - b.tokens) / limit.Rate)
+	}
+	return allowed, int(b.tokens), wait, toDuration((float64(limit.Burst) - b.tokens) / limit.Rate)
+}
+
+// Run removes the buckets of inactive clients until ctx is done.
+func (l *Limiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(idleTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.removeIdle(l.now())
+		}
+	}
+}
+
+func (l *Limiter) removeIdle(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(limit Limit, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	}
+	b.last = now
+}
+
+//------------------------------------------------------------------------------
+
+// routeOf returns method and path template of the matched route, e.g. "GET /v1/pois/{id}".
+func routeOf(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return r.Method + " " + template
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// clientOf identifies the client by the principal, or by the IP address if the request is not authenticated or
+// the clients are identified ByIp.
+func (l *Limiter) clientOf(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok && principal.Id() != "" && !l.byIp {
+		return "principal:" + principal.Id()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", seconds(wait))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// seconds rounds d up to full seconds.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+func toDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
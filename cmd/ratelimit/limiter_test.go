@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"poi-service/cmd/auth"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// clock is a time that is only changed by the test.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestRouter(l *Limiter) http.Handler {
+	r := mux.NewRouter()
+	r.Use(l.Middleware)
+	r.HandleFunc("/pois/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	r.HandleFunc("/pois/list", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost)
+	return r
+}
+
+// serve sends a request of the principal, or of remoteAddr if subject is empty.
+func serve(router http.Handler, method, target, subject, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = remoteAddr
+	if subject != "" {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: subject}))
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLimiter_tokenBucket(t *testing.T) {
+	c := &clock{now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	router := newTestRouter(NewLimiter(Policy{Default: Limit{Rate: 1, Burst: 2}}, nil, WithClock(c.Now)))
+
+	w := serve(router, http.MethodGet, "/pois/1", "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(HeaderLimit))
+	assert.Equal(t, "1", w.Header().Get(HeaderRemaining))
+	assert.Equal(t, "1", w.Header().Get(HeaderReset))
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/2", "alice", "").Code, "same route template")
+	w = serve(router, http.MethodGet, "/pois/3", "alice", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get(HeaderRemaining))
+
+	// other clients have their own bucket
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "bob", "").Code)
+
+	// tokens are refilled by the rate
+	c.now = c.now.Add(1500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "alice", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/pois/1", "alice", "").Code)
+}
+
+func TestLimiter_routes(t *testing.T) {
+	policy := Policy{
+		Default: Limit{Rate: 1, Burst: 5},
+		Routes:  map[string]Limit{"POST /pois/list": {Rate: 0.5, Burst: 1}},
+	}
+	router := newTestRouter(NewLimiter(policy, nil))
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/pois/list", "alice", "").Code)
+	w := serve(router, http.MethodPost, "/pois/list", "alice", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	w = serve(router, http.MethodGet, "/pois/1", "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get(HeaderLimit))
+}
+
+func TestLimiter_ipFallback(t *testing.T) {
+	router := newTestRouter(NewLimiter(Policy{Default: Limit{Rate: 1, Burst: 1}}, nil))
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "", "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/pois/1", "", "10.0.0.1:5678").Code, "port is ignored")
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "", "10.0.0.2:1234").Code)
+}
+
+func TestLimiter_byIp(t *testing.T) {
+	router := newTestRouter(NewLimiter(Policy{Default: Limit{Rate: 1, Burst: 1}}, nil, ByIp()))
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "alice", "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/pois/1", "bob", "10.0.0.1:1234").Code,
+		"principals of the same address share the limit")
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "alice", "10.0.0.2:1234").Code)
+}
+
+func TestLimiter_dailyQuota(t *testing.T) {
+	c := &clock{now: time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)}
+	limiter := NewLimiter(Policy{Default: Limit{Rate: 100, Burst: 100, DailyQuota: 2}}, NewMemoryQuotaStore(), WithClock(c.Now))
+	router := newTestRouter(limiter)
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "alice", "").Code)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "alice", "").Code)
+	w := serve(router, http.MethodGet, "/pois/1", "alice", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"), "until midnight UTC")
+	assert.Equal(t, "0", w.Header().Get(HeaderRemaining))
+
+	c.now = c.now.Add(time.Hour)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "alice", "").Code)
+}
+
+type failingQuotaStore struct{}
+
+func (failingQuotaStore) Increment(context.Context, QuotaKey) (int64, error) {
+	return 0, errors.New("database down")
+}
+
+func TestLimiter_quotaStoreFailing(t *testing.T) {
+	router := newTestRouter(NewLimiter(Policy{Default: Limit{Rate: 1, Burst: 1, DailyQuota: 1}}, failingQuotaStore{}))
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/pois/1", "alice", "").Code)
+}
+
+func TestLimiter_removeIdle(t *testing.T) {
+	c := &clock{now: time.Now()}
+	limiter := NewLimiter(Policy{Default: Limit{Rate: 1, Burst: 1}}, nil, WithClock(c.Now))
+	serve(newTestRouter(limiter), http.MethodGet, "/pois/1", "alice", "")
+	assert.Len(t, limiter.buckets, 1)
+
+	limiter.removeIdle(c.now.Add(idleTimeout / 2))
+	assert.Len(t, limiter.buckets, 1)
+	limiter.removeIdle(c.now.Add(2 * idleTimeout))
+	assert.Empty(t, limiter.buckets)
+}
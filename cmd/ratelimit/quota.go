@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+// QuotaKey identifies the counter of a client for a route and day.
+type QuotaKey struct {
+	Client string
+	// Route is the name of the limit, i.e. the route or "*" for all routes without own limit.
+	Route string
+	// Day is the start of the day in UTC.
+	Day time.Time
+}
+
+func (k QuotaKey) id() string {
+	return k.Day.Format("2006-01-02") + "|" + k.Route + "|" + k.Client
+}
+
+// QuotaStore counts the requests of the clients per day. The counters are shared by all instances of the service.
+type QuotaStore interface {
+	// Increment counts a request and returns the number of requests of the day including this one.
+	Increment(ctx context.Context, key QuotaKey) (count int64, err error)
+}
+
+//------------------------------------------------------------------------------
+
+// NewMemoryQuotaStore creates a QuotaStore that keeps the counters in memory only, e.g. for tests.
+func NewMemoryQuotaStore() QuotaStore {
+	return &memoryQuotaStore{counts: make(map[string]int64)}
+}
+
+// memoryQuotaStore implements interface QuotaStore
+type memoryQuotaStore struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (m *memoryQuotaStore) Increment(_ context.Context, key QuotaKey) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[key.id()]++
+	return m.counts[key.id()], nil
+}
+
+//------------------------------------------------------------------------------
+
+// quotaEntry is the counter stored in Mongo.
+type quotaEntry struct {
+	Id    string `bson:"_id"`
+	Count int64  `bson:"count"`
+	// ExpiresAt lets Mongo remove the counter once the day is over.
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// NewMongoQuotaStore creates a QuotaStore that counts in collection. Counters are removed by Mongo a day after they
+// expired.
+func NewMongoQuotaStore(ctx context.Context, collection *mongo.Collection) QuotaStore {
+	expiry := mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(int32((24 * time.Hour).Seconds())),
+	}
+	collection.Indexes().CreateOne(ctx, expiry)
+	return &mongoQuotaStore{collection: collection}
+}
+
+// mongoQuotaStore implements interface QuotaStore
+type mongoQuotaStore struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoQuotaStore) Increment(ctx context.Context, key QuotaKey) (int64, error) {
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expiresAt": key.Day.Add(24 * time.Hour)},
+	}
+	var entry quotaEntry
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"_id": key.id()}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&entry)
+	return entry.Count, err
+}
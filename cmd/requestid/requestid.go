@@ -0,0 +1,40 @@
+package requestid
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+	"regexp"
+)
+
+// Header carries the id of a request. A valid id given by the caller is used, otherwise a new one is generated.
+const Header = "X-Request-ID"
+
+// validId limits ids of callers, so they can not inject arbitrary content into logs and audit records.
+var validId = regexp.MustCompile(`^[a-zA-Z0-9._\-]{1,128}$`)
+
+type contextKey struct{}
+
+// Middleware makes the id of the request available in the context and returns it in the response header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !validId.MatchString(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithId(r.Context(), id)))
+	})
+}
+
+// WithId returns a copy of ctx that carries the request id.
+func WithId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request id or empty string if ctx carries none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
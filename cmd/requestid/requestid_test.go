@@ -0,0 +1,47 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var seen string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+
+	t.Run("generated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.NotEmpty(t, seen)
+		assert.Equal(t, seen, w.Header().Get(Header))
+	})
+
+	t.Run("given by caller", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(Header, "abc-123")
+		h.ServeHTTP(w, r)
+		assert.Equal(t, "abc-123", seen)
+		assert.Equal(t, "abc-123", w.Header().Get(Header))
+	})
+
+	t.Run("invalid id replaced", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(Header, "abc\n{\"level\":\"error\"}")
+		h.ServeHTTP(w, r)
+		assert.NotEqual(t, "abc\n{\"level\":\"error\"}", seen)
+		assert.NotEmpty(t, seen)
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+	assert.Equal(t, "id", FromContext(WithId(context.Background(), "id")))
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"net/http"
+	"poi-service/cmd/app"
+	"poi-service/cmd/audit"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/config"
+	"poi-service/cmd/dedup"
+	"poi-service/cmd/download"
+	"poi-service/cmd/events"
+	"poi-service/cmd/geofence"
+	"poi-service/cmd/handler"
+	"poi-service/cmd/idempotency"
+	"poi-service/cmd/metrics"
+	"poi-service/cmd/outbox"
+	"poi-service/cmd/ratelimit"
+	"poi-service/cmd/tlsconfig"
+	"poi-service/cmd/tracing"
+	"time"
+)
+
+const (
+	// outboxCollection contains the changes not yet published if events.source is "outbox".
+	outboxCollection = "outbox"
+	// purgeInterval is the interval in which pois with exceeded retention are purged.
+	purgeInterval = time.Hour
+	// geofenceReloadInterval is the interval in which fences registered by other instances are picked up.
+	geofenceReloadInterval = time.Minute
+	// webhookBuffer is the number of events queued for the webhook dispatcher.
+	webhookBuffer = 256
+)
+
+// service contains the components of the service that are created from the configuration.
+type service struct {
+	app         *app.App
+	components  app.Components
+	mongoClient *mongo.Client
+	jwkStore    auth.JwkStore
+	// refreshJwks is true if the keys of the issuer are downloaded, i.e. JWTs are accepted and not issued by the
+	// service itself
+	refreshJwks bool
+	tlsReloader *tlsconfig.Reloader
+	// changeStream is the collection watched for events, nil if the events are published in-process
+	changeStream *mongo.Collection
+	// relay publishes the events of the outbox, nil if the outbox is disabled
+	relay *outbox.Relay
+}
+
+// newService connects to Mongo and creates all components for cfg.
+func newService(ctx context.Context, cfg config.Config) (*service, error) {
+	mongoClient, err := handler.NewMongoClient(cfg.Database.Url)
+	if err != nil {
+		return nil, err
+	}
+	s := &service{mongoClient: mongoClient}
+	c := &s.components
+
+	// change streams and the outbox need a replica set, otherwise the changes made by this instance are published
+	c.EventBus = events.NewBus()
+	db := mongoClient.Database(cfg.Database.Name)
+	withDatabase := handler.WithDatabase(cfg.Database.Name, cfg.Database.PoiCollection)
+	var dbHandler handler.DbHandler
+	switch cfg.Events.Source {
+	case config.EventSourceHandler:
+		dbHandler = handler.NewDbHandlerWithClient(mongoClient, withDatabase)
+	case config.EventSourceChangeStream:
+		dbHandler = handler.NewDbHandlerWithClient(mongoClient, withDatabase)
+		s.changeStream = db.Collection(cfg.Database.PoiCollection)
+	case config.EventSourceOutbox:
+		dbHandler = handler.NewDbHandlerWithClient(mongoClient, withDatabase, handler.WithOutbox(outboxCollection))
+		s.relay = outbox.NewRelay(outbox.NewMongoStore(db.Collection(outboxCollection)), outbox.NewBusPublisher(c.EventBus))
+	}
+	dbHandler = metrics.NewDbHandler(dbHandler)
+	c.AuditLog = audit.NewLog(audit.NewMongoStore(db.Collection("audit")), auditSigningKey(cfg.Audit.SigningKey))
+	poiHandler := audit.NewPoiHandler(handler.NewPoiHandler(dbHandler), c.AuditLog)
+	if cfg.Events.Source == config.EventSourceHandler {
+		poiHandler = events.NewPoiHandler(poiHandler, c.EventBus)
+	}
+	c.Webhooks = events.NewMongoWebhookStore(db.Collection("webhooks"), db.Collection("webhookDeadLetters"))
+
+	c.ApiKeys = auth.NewMongoApiKeyStore(db.Collection("apikeys"))
+	// outgoing requests pass the trace context on
+	httpClient := download.NewHttpRequester(&http.Client{
+		Timeout:   cfg.Http.ClientTimeout.Duration(),
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	})
+	c.Dispatcher = events.NewDispatcher(c.Webhooks, download.NewHttpRequester(&http.Client{
+		Timeout:   cfg.Http.WebhookTimeout.Duration(),
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}))
+	c.Geofences, err = geofence.NewRegistry(ctx, geofence.NewMongoStore(db.Collection("geofences")), c.Dispatcher)
+	if err != nil {
+		return nil, err
+	}
+	c.Pois = tracing.NewPoiHandler(geofence.NewPoiHandler(poiHandler, c.Geofences))
+	c.Duplicates = dedup.NewDeduplicator(dbHandler, c.Pois, dedup.NewMongoRedirectStore(ctx, db.Collection("redirects")),
+		dedup.WithMaxDistance(cfg.Dedup.MaxDistance), dedup.WithMinScore(cfg.Dedup.MinScore), dedup.WithMaxPois(cfg.Dedup.MaxPois))
+	c.IdempotencyKeys = idempotency.NewKeys(idempotency.NewMongoStore(ctx, db.Collection("idempotencyKeys")),
+		idempotency.WithTtl(cfg.Idempotency.KeyTtl.Duration()), idempotency.WithLease(cfg.Idempotency.Lease.Duration()))
+	if cfg.RateLimit.Enabled {
+		c.IpRateLimiter = ratelimit.NewLimiter(cfg.RateLimit.IpPolicy(), nil, ratelimit.ByIp())
+		c.RateLimiter = ratelimit.NewLimiter(cfg.RateLimit.Policy(), ratelimit.NewMongoQuotaStore(ctx, db.Collection("quotas")))
+	}
+	jwkCache := &auth.JwkCache{}
+	jwkCache.Init()
+
+	// serve TLS only if a certificate is configured
+	if cfg.Tls.CertFile != "" {
+		s.tlsReloader, err = tlsconfig.NewReloader(tlsconfig.Config{
+			CertFile:     cfg.Tls.CertFile,
+			KeyFile:      cfg.Tls.KeyFile,
+			ClientCAFile: cfg.Tls.ClientCaFile,
+			ClientAuth:   cfg.Tls.ClientAuth,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	issuer := cfg.Auth.Issuer
+	var jwkOpts []auth.JwkStoreOption
+	if cfg.Auth.DevTokenIssuer {
+		scheme := "http"
+		if s.tlsReloader != nil {
+			scheme = "https"
+		}
+		c.DevIssuer, err = auth.NewDevIssuer(scheme + "://127.0.0.1:" + cfg.Service.Port + "/")
+		if err != nil {
+			return nil, err
+		}
+		issuer = c.DevIssuer.Issuer()
+		// the service can not download its own keys before it is listening
+		jwkOpts = append(jwkOpts, auth.WithStaticKeys(c.DevIssuer.Jwks()))
+		log.Warn().Str("issuer", issuer).Msg("DEV TOKEN ISSUER ENABLED, everyone can get tokens. Never use in production!")
+	}
+	s.jwkStore = auth.NewJwkStore(issuer, httpClient, jwkCache, jwkOpts...)
+	s.refreshJwks = cfg.Auth.AcceptsJwt() && !cfg.Auth.DevTokenIssuer
+
+	c.Authorizer = s.newAuthorizer(cfg.Auth, cfg.Tls.ClientAuth, httpClient)
+	c.Health = newHealthChecker(mongoClient, s.jwkStore, cfg.Auth.AcceptsJwt())
+
+	s.app, err = app.New(s.components)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newAuthorizer creates the authorizer for the configured mode:
+// - jwt (default): bearer tokens must be JWTs signed by the trusted backend
+// - introspection: bearer tokens are validated by the OAuth2 introspection endpoint
+// - jwt+introspection: JWT validation with fallback to introspection, e.g. for opaque tokens
+// In all modes machine clients can alternatively use API keys and, if enabled, client certificates. The config is
+// validated already, so the mode is known.
+func (s *service) newAuthorizer(cfg config.Auth, clientAuth string, httpClient download.HttpRequester) auth.Authorizer {
+	introspection := func() auth.Authenticator {
+		return auth.NewIntrospectionAuthenticator(
+			cfg.Introspection.Url,
+			cfg.Introspection.ClientId,
+			cfg.Introspection.ClientSecret,
+			httpClient)
+	}
+
+	var authenticators []auth.Authenticator
+	switch cfg.Mode {
+	case config.AuthModeJwt:
+		authenticators = append(authenticators, auth.NewJwtAuthenticator(s.jwkStore))
+	case config.AuthModeIntrospection:
+		authenticators = append(authenticators, introspection())
+	case config.AuthModeJwtIntrospection:
+		authenticators = append(authenticators, auth.NewJwtAuthenticator(s.jwkStore), introspection())
+	}
+
+	authenticators = append(authenticators, auth.NewApiKeyAuthenticator(s.components.ApiKeys))
+	if s.tlsReloader != nil && clientAuth != tlsconfig.ClientAuthNone {
+		authenticators = append(authenticators, auth.NewClientCertAuthenticator())
+	}
+	return auth.NewAuthorizerWith(authenticators...)
+}
+
+// runBackground starts the background tasks that run until ctx is done. The trash retention is read from settings
+// before every purge.
+func (s *service) runBackground(ctx context.Context, settings *config.Watcher) {
+	// keep the JWKs up to date in the background, so key rotation does not block requests
+	if s.refreshJwks {
+		go s.jwkStore.RunRefresh(ctx)
+	}
+
+	// deleted pois can be restored until the retention is over
+	go handler.RunPurge(ctx, s.components.Pois, func() time.Duration {
+		return settings.Current().Trash.Retention.Duration()
+	}, purgeInterval)
+
+	// publish the changes to the webhooks
+	if s.changeStream != nil {
+		go events.WatchChangeStream(ctx, s.changeStream, s.components.EventBus)
+	}
+	if s.relay != nil {
+		go s.relay.Run(ctx)
+	}
+	webhookEvents, stopWebhooks := s.components.EventBus.Subscribe(webhookBuffer)
+	go func() {
+		<-ctx.Done()
+		stopWebhooks()
+	}()
+	go s.components.Dispatcher.Run(ctx, webhookEvents)
+	go s.components.Geofences.RunReload(ctx, geofenceReloadInterval)
+	if s.components.RateLimiter != nil {
+		go s.components.IpRateLimiter.Run(ctx)
+		go s.components.RateLimiter.Run(ctx)
+	}
+
+	// certificates are provided by the reloader, so rotated certificates are picked up without restart
+	if s.tlsReloader != nil {
+		go s.tlsReloader.Run(ctx)
+	}
+}
+
+// close disconnects from Mongo.
+func (s *service) close(ctx context.Context) error {
+	return s.mongoClient.Disconnect(ctx)
+}
+
+// auditSigningKey returns the key to sign audit records or nil if records are only hash chained.
+func auditSigningKey(key string) []byte {
+	if key == "" {
+		log.Warn().Msg("audit.signingKey not set, audit records are not signed")
+		return nil
+	}
+	return []byte(key)
+}
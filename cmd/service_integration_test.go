@@ -0,0 +1,219 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"poi-service/cmd/auth"
+	"poi-service/cmd/config"
+	"poi-service/cmd/data"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// harness runs the complete service against the mongodb of INTEGRATION_DATABASE_URL (default
+// mongodb://localhost:27017). Every test gets its own database that is dropped afterwards. Tokens are issued by the
+// dev token issuer.
+type harness struct {
+	t      *testing.T
+	server *httptest.Server
+	token  string
+}
+
+func newHarness(t *testing.T, scopes ...string) *harness {
+	url := os.Getenv("INTEGRATION_DATABASE_URL")
+	if url == "" {
+		url = "mongodb://localhost:27017"
+	}
+
+	cfg := config.Default()
+	cfg.Database.Url = url
+	cfg.Database.Name = "poiIntegration" + uuid.New().String()[:8]
+	cfg.Auth.DevTokenIssuer = true
+	require.NoError(t, cfg.Validate())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	svc, err := newService(ctx, cfg)
+	require.NoError(t, err)
+	svc.runBackground(ctx, config.NewWatcher(config.Source{}, cfg))
+
+	h := &harness{t: t, server: httptest.NewServer(svc.app.Router())}
+	t.Cleanup(func() {
+		h.server.Close()
+		cancel()
+		require.NoError(t, svc.mongoClient.Database(cfg.Database.Name).Drop(context.Background()))
+		require.NoError(t, svc.close(context.Background()))
+	})
+
+	var token auth.DevTokenResponse
+	rsp := h.do(http.MethodPost, "/dev/token", auth.DevTokenRequest{Subject: "integration", Scopes: scopes}, &token)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	h.token = token.AccessToken
+	return h
+}
+
+// do sends body as JSON and decodes the response into result, if not nil.
+func (h *harness) do(method, path string, body, result interface{}) *http.Response {
+	var content []byte
+	if body != nil {
+		var err error
+		content, err = json.Marshal(body)
+		require.NoError(h.t, err)
+	}
+	req, err := http.NewRequest(method, h.server.URL+path, bytes.NewReader(content))
+	require.NoError(h.t, err)
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	rsp, err := h.server.Client().Do(req)
+	require.NoError(h.t, err)
+	defer rsp.Body.Close()
+	if result != nil && rsp.StatusCode < http.StatusBadRequest {
+		require.NoError(h.t, json.NewDecoder(rsp.Body).Decode(result))
+	}
+	return rsp
+}
+
+func TestIntegration_poiLifecycle(t *testing.T) {
+	h := newHarness(t, "poi:read", "poi:write")
+	poi := data.Poi{Name: "Frauenkirche", Latitude: 51.0519, Longitude: 13.7415}
+
+	var id string
+	require.Equal(t, http.StatusOK, h.do(http.MethodPost, "/v1/pois", poi, &id).StatusCode)
+	require.NotEmpty(t, id)
+
+	var found data.Pois
+	area := data.SearchArea{Latitude: poi.Latitude, Longitude: poi.Longitude, RadiusInMeter: 1000}
+	require.Equal(t, http.StatusOK, h.do(http.MethodPost, "/v1/pois/list", area, &found).StatusCode)
+	require.Len(t, found, 1)
+	assert.Equal(t, poi.Name, found[0].Name)
+
+	poi.Name = "Dresdner Frauenkirche"
+	require.Equal(t, http.StatusOK, h.do(http.MethodPut, "/v1/pois/"+id, poi, nil).StatusCode)
+
+	var revisions data.Revisions
+	require.Equal(t, http.StatusOK, h.do(http.MethodGet, "/v1/pois/"+id+"/revisions", nil, &revisions).StatusCode)
+	assert.Len(t, revisions, 2)
+
+	require.Equal(t, http.StatusOK, h.do(http.MethodDelete, "/v1/pois/"+id, nil, nil).StatusCode)
+	assert.Equal(t, http.StatusNotFound, h.do(http.MethodGet, "/v1/pois/"+id, nil, nil).StatusCode)
+
+	require.Equal(t, http.StatusOK, h.do(http.MethodPost, "/v1/trash/"+id+"/restore", nil, nil).StatusCode)
+	var restored data.Poi
+	require.Equal(t, http.StatusOK, h.do(http.MethodGet, "/v1/pois/"+id, nil, &restored).StatusCode)
+	assert.Equal(t, "Dresdner Frauenkirche", restored.Name)
+}
+
+func TestIntegration_upsertBySource(t *testing.T) {
+	h := newHarness(t, "poi:read", "poi:write")
+	poi := data.Poi{Name: "Zwinger", Latitude: 51.0530, Longitude: 13.7339}
+	path := "/v1/sources/partner/pois/z-1"
+
+	var id string
+	require.Equal(t, http.StatusCreated, h.do(http.MethodPut, path, poi, &id).StatusCode)
+	poi.Name = "Dresdner Zwinger"
+	var updatedId string
+	require.Equal(t, http.StatusOK, h.do(http.MethodPut, path, poi, &updatedId).StatusCode)
+	assert.Equal(t, id, updatedId, "the second sync updates the poi")
+
+	var found struct {
+		Id string `json:"id"`
+		data.Poi
+	}
+	require.Equal(t, http.StatusOK, h.do(http.MethodGet, path, nil, &found).StatusCode)
+	assert.Equal(t, id, found.Id)
+	assert.Equal(t, "Dresdner Zwinger", found.Name)
+
+	poi.Source, poi.ExternalId = "partner", "z-1"
+	assert.Equal(t, http.StatusConflict, h.do(http.MethodPost, "/v1/pois", poi, nil).StatusCode)
+
+	require.Equal(t, http.StatusOK, h.do(http.MethodDelete, "/v1/pois/"+id, nil, nil).StatusCode)
+	assert.Equal(t, http.StatusNotFound, h.do(http.MethodGet, path, nil, nil).StatusCode)
+	assert.Equal(t, http.StatusConflict, h.do(http.MethodPut, path, poi, nil).StatusCode)
+}
+
+func TestIntegration_concurrentUpserts(t *testing.T) {
+	h := newHarness(t, "poi:read", "poi:write")
+	poi := data.Poi{Name: "Zwinger", Latitude: 51.0530, Longitude: 13.7339}
+
+	const syncs = 5
+	ids := make([]string, syncs)
+	statuses := make([]int, syncs)
+	wg := sync.WaitGroup{}
+	for i := 0; i < syncs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i] = h.do(http.MethodPut, "/v1/sources/partner/pois/z-2", poi, &ids[i]).StatusCode
+		}(i)
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, []int{http.StatusCreated, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK}, statuses,
+		"the poi is created once")
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id)
+	}
+}
+
+func TestIntegration_mergeDuplicates(t *testing.T) {
+	h := newHarness(t, "poi:read", "poi:write", "poi:admin")
+
+	var keep, merge string
+	require.Equal(t, http.StatusOK, h.do(http.MethodPost, "/v1/pois", data.Poi{Name: "Frauenkirche", Latitude: 51.05190, Longitude: 13.74150}, &keep).StatusCode)
+	require.Equal(t, http.StatusCreated, h.do(http.MethodPut, "/v1/sources/partner/pois/f-1", data.Poi{Name: "Dresdner Frauenkirche", Latitude: 51.05195, Longitude: 13.74160}, &merge).StatusCode)
+
+	var candidates []struct {
+		A struct{ Id string } `json:"a"`
+		B struct{ Id string } `json:"b"`
+	}
+	area := data.SearchArea{Latitude: 51.05190, Longitude: 13.74150, RadiusInMeter: 1000}
+	require.Equal(t, http.StatusOK, h.do(http.MethodPost, "/v1/duplicates/list", area, &candidates).StatusCode)
+	require.Len(t, candidates, 1)
+	assert.ElementsMatch(t, []string{keep, merge}, []string{candidates[0].A.Id, candidates[0].B.Id})
+
+	request := map[string]string{"keep": keep, "merge": merge}
+	require.Equal(t, http.StatusOK, h.do(http.MethodPost, "/v1/duplicates/merge", request, nil).StatusCode)
+	// a repeated merge is done already
+	require.Equal(t, http.StatusOK, h.do(http.MethodPost, "/v1/duplicates/merge", request, nil).StatusCode)
+
+	// the client follows the redirect to the kept poi
+	var poi data.Poi
+	require.Equal(t, http.StatusOK, h.do(http.MethodGet, "/v1/pois/"+merge, nil, &poi).StatusCode)
+	assert.Equal(t, "Frauenkirche", poi.Name)
+
+	// the external id moved to the kept poi, so the next sync updates it
+	var synced string
+	require.Equal(t, http.StatusOK, h.do(http.MethodPut, "/v1/sources/partner/pois/f-1", data.Poi{Name: "Frauenkirche", Latitude: 51.05190, Longitude: 13.74150}, &synced).StatusCode)
+	assert.Equal(t, keep, synced)
+}
+
+func TestIntegration_auth(t *testing.T) {
+	h := newHarness(t)
+
+	assert.Equal(t, http.StatusForbidden, h.do(http.MethodGet, "/admin/apikeys", nil, nil).StatusCode)
+
+	h.token = "invalid"
+	assert.Equal(t, http.StatusUnauthorized, h.do(http.MethodGet, "/v1/pois/1", nil, nil).StatusCode)
+
+	h.token = ""
+	assert.Equal(t, http.StatusUnauthorized, h.do(http.MethodGet, "/v1/pois/1", nil, nil).StatusCode)
+	assert.Equal(t, http.StatusOK, h.do(http.MethodGet, "/healthz", nil, nil).StatusCode)
+
+	// mongo is reachable and the JWKs of the dev issuer are cached
+	assert.Eventually(t, func() bool {
+		return h.do(http.MethodGet, "/readyz", nil, nil).StatusCode == http.StatusOK
+	}, 5*time.Second, 100*time.Millisecond)
+}
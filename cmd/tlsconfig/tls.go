@@ -0,0 +1,189 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Supported modes for the authentication of clients by certificates.
+const (
+	// ClientAuthNone does not ask for client certificates.
+	ClientAuthNone = "none"
+	// ClientAuthRequest asks for a client certificate and verifies it if given.
+	ClientAuthRequest = "request"
+	// ClientAuthRequire requires a valid client certificate for every connection (mTLS).
+	ClientAuthRequire = "require"
+)
+
+// DefaultReloadInterval is the interval in which the files are checked for changes.
+const DefaultReloadInterval = 30 * time.Second
+
+// Config describes the TLS setup of the server.
+type Config struct {
+	// CertFile and KeyFile contain the PEM encoded certificate (chain) and private key of the server.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile contains the PEM encoded CAs that are trusted to issue client certificates.
+	ClientCAFile string
+	// ClientAuth is one of ClientAuthNone, ClientAuthRequest or ClientAuthRequire.
+	ClientAuth string
+	// ReloadInterval is the interval in which the files are checked for changes.
+	ReloadInterval time.Duration
+}
+
+// Reloader provides the TLS configuration of the server and reloads certificates and CAs if the files change, so
+// rotated certificates are used without restart.
+type Reloader struct {
+	config Config
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modified    time.Time
+}
+
+// NewReloader loads the files of config. It fails if they can not be loaded.
+func NewReloader(config Config) (*Reloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("certificate and key file required")
+	}
+	switch config.ClientAuth {
+	case "", ClientAuthNone:
+		config.ClientAuth = ClientAuthNone
+	case ClientAuthRequest, ClientAuthRequire:
+		if config.ClientCAFile == "" {
+			return nil, fmt.Errorf("client CA file required for client auth %s", config.ClientAuth)
+		}
+	default:
+		return nil, fmt.Errorf("unknown client auth %q", config.ClientAuth)
+	}
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = DefaultReloadInterval
+	}
+
+	r := &Reloader{config: config}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns the configuration for the http.Server. Certificates and CAs are always taken from the last
+// successful load.
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.getCertificate,
+	}
+	if r.config.ClientAuth == ClientAuthNone {
+		return base
+	}
+
+	clientAuth := tls.VerifyClientCertIfGiven
+	if r.config.ClientAuth == ClientAuthRequire {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: r.getCertificate,
+			ClientAuth:     clientAuth,
+			ClientCAs:      r.clientCAs,
+		}, nil
+	}
+	return base
+}
+
+// Run checks the files for changes until ctx is done.
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			// keep the old certificates if the new ones are broken, e.g. written only partially
+			if err := r.load(); err != nil {
+				log.Warn().Err(err).Msg("reloading TLS certificates failed")
+				continue
+			}
+			log.Info().Str("cert", r.config.CertFile).Msg("TLS certificates reloaded")
+		}
+	}
+}
+
+func (r *Reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.certificate, nil
+}
+
+// changed returns true if one of the files was modified after the last load.
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return latestModification(r.files()...).After(r.modified)
+}
+
+func (r *Reloader) load() error {
+	modified := latestModification(r.files()...)
+
+	certificate, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading key pair failed: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading client CAs failed: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no client CA found in %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modified = modified
+	return nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+func latestModification(files ...string) (latest time.Time) {
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return
+}
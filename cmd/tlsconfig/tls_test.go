@@ -0,0 +1,114 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair writes a self-signed certificate for commonName and its key to dir.
+func writeKeyPair(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	cert, err := r.TLSConfig().GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return parsed.Subject.CommonName
+}
+
+func TestNewReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "server")
+
+	t.Run("missing files", func(t *testing.T) {
+		_, err := NewReloader(Config{CertFile: certFile})
+		assert.Error(t, err)
+		_, err = NewReloader(Config{CertFile: filepath.Join(dir, "unknown"), KeyFile: keyFile})
+		assert.Error(t, err)
+	})
+
+	t.Run("client auth without CA", func(t *testing.T) {
+		_, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire})
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown client auth", func(t *testing.T) {
+		_, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"})
+		assert.Error(t, err)
+	})
+
+	t.Run("server only", func(t *testing.T) {
+		r, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile})
+		require.NoError(t, err)
+		assert.Equal(t, "server", commonName(t, r))
+		assert.Nil(t, r.TLSConfig().GetConfigForClient)
+	})
+
+	t.Run("require client certificates", func(t *testing.T) {
+		r, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, ClientAuth: ClientAuthRequire})
+		require.NoError(t, err)
+		config, err := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+		assert.NotNil(t, config.ClientCAs)
+	})
+}
+
+func TestReloader_Run(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "old")
+
+	r, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ReloadInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	// broken files keep the old certificate
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0600))
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "old", commonName(t, r))
+
+	writeKeyPair(t, dir, "new")
+	future = future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	assert.Eventually(t, func() bool { return commonName(t, r) == "new" }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"time"
+)
+
+var tracer = otel.Tracer("poi-service/cmd/tracing")
+
+// NewPoiHandler decorates next, so every operation is recorded as span of the trace in ctx.
+func NewPoiHandler(next handler.PoiHandler) handler.PoiHandler {
+	if next == nil {
+		return nil
+	}
+	return &poiHandler{next: next}
+}
+
+// poiHandler implements interface handler.PoiHandler
+type poiHandler struct {
+	next handler.PoiHandler
+}
+
+// start begins the span of operation, id identifies the poi if the operation is about a single one.
+func start(ctx context.Context, operation string, id data.Id) (context.Context, trace.Span) {
+	var opts []trace.SpanStartOption
+	if id != "" {
+		opts = append(opts, trace.WithAttributes(attribute.String("poi.id", string(id))))
+	}
+	return tracer.Start(ctx, "PoiHandler."+operation, opts...)
+}
+
+func (p *poiHandler) Create(ctx context.Context, poi *data.Poi) (uniqueId string, err error) {
+	ctx, span := start(ctx, "Create", "")
+	uniqueId, err = p.next.Create(ctx, poi)
+	if err == nil {
+		span.SetAttributes(attribute.String("poi.id", uniqueId))
+	}
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Update(ctx context.Context, idToUpdate data.Id, updatedPoi *data.Poi) (err error) {
+	ctx, span := start(ctx, "Update", idToUpdate)
+	err = p.next.Update(ctx, idToUpdate, updatedPoi)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Get(ctx context.Context, id data.Id) (resp data.Poi, err error) {
+	ctx, span := start(ctx, "Get", id)
+	resp, err = p.next.Get(ctx, id)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Delete(ctx context.Context, id data.Id) (err error) {
+	ctx, span := start(ctx, "Delete", id)
+	err = p.next.Delete(ctx, id)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Search(ctx context.Context, pos data.SearchArea) (resp data.Pois, err error) {
+	ctx, span := start(ctx, "Search", "")
+	resp, err = p.next.Search(ctx, pos)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) ListDeleted(ctx context.Context) (resp data.DeletedPois, err error) {
+	ctx, span := start(ctx, "ListDeleted", "")
+	resp, err = p.next.ListDeleted(ctx)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Restore(ctx context.Context, id data.Id) (err error) {
+	ctx, span := start(ctx, "Restore", id)
+	err = p.next.Restore(ctx, id)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) PurgeDeleted(ctx context.Context, retention time.Duration) (purged int64, err error) {
+	ctx, span := start(ctx, "PurgeDeleted", "")
+	purged, err = p.next.PurgeDeleted(ctx, retention)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) GetAsOf(ctx context.Context, id data.Id, asOf time.Time) (resp data.Poi, err error) {
+	ctx, span := start(ctx, "GetAsOf", id)
+	resp, err = p.next.GetAsOf(ctx, id, asOf)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) ListRevisions(ctx context.Context, id data.Id) (resp data.Revisions, err error) {
+	ctx, span := start(ctx, "ListRevisions", id)
+	resp, err = p.next.ListRevisions(ctx, id)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) GetRevision(ctx context.Context, id data.Id, revision int) (resp data.Revision, err error) {
+	ctx, span := start(ctx, "GetRevision", id)
+	resp, err = p.next.GetRevision(ctx, id, revision)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Diff(ctx context.Context, id data.Id, from, to int) (resp data.Changes, err error) {
+	ctx, span := start(ctx, "Diff", id)
+	resp, err = p.next.Diff(ctx, id, from, to)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Revert(ctx context.Context, id data.Id, revision int) (err error) {
+	ctx, span := start(ctx, "Revert", id)
+	err = p.next.Revert(ctx, id, revision)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) GetByExternalId(ctx context.Context, source, externalId string) (id string, resp data.Poi, err error) {
+	ctx, span := start(ctx, "GetByExternalId", "")
+	span.SetAttributes(attribute.String("poi.source", source), attribute.String("poi.externalId", externalId))
+	id, resp, err = p.next.GetByExternalId(ctx, source, externalId)
+	if err == nil {
+		span.SetAttributes(attribute.String("poi.id", id))
+	}
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Merge(ctx context.Context, keep, merge data.Id) (err error) {
+	ctx, span := start(ctx, "Merge", merge)
+	span.SetAttributes(attribute.String("poi.keep", string(keep)))
+	err = p.next.Merge(ctx, keep, merge)
+	End(span, err)
+	return
+}
+
+func (p *poiHandler) Upsert(ctx context.Context, poi *data.Poi) (uniqueId string, created bool, err error) {
+	ctx, span := start(ctx, "Upsert", "")
+	uniqueId, created, err = p.next.Upsert(ctx, poi)
+	if err == nil {
+		span.SetAttributes(attribute.String("poi.id", uniqueId), attribute.Bool("poi.created", created))
+	}
+	End(span, err)
+	return
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+)
+
+// Exporters of the traces.
+const (
+	// ExporterNone disables the export, the trace context of incoming requests is still passed on.
+	ExporterNone = "none"
+	// ExporterStdout writes the spans to stdout, e.g. for local debugging.
+	ExporterStdout = "stdout"
+	// ExporterOtlp sends the spans via OTLP/HTTP. The endpoint is configured by the standard OTEL_EXPORTER_OTLP_*
+	// environment variables, by default it is localhost:4318.
+	ExporterOtlp = "otlp"
+)
+
+// UnknownExporter is given if the configured exporter is not supported.
+const UnknownExporter = UnknownExporterError("unknown trace exporter")
+
+type UnknownExporterError string
+
+func (e UnknownExporterError) Error() string { return string(e) }
+
+// Setup installs the global tracer provider with the given exporter and the W3C trace context propagation. The
+// returned shutdown function flushes the remaining spans.
+func Setup(ctx context.Context, serviceName, exporter string) (shutdown func(ctx context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOtlp:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("%w: %s", UnknownExporter, exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// End records err at span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Detach returns a context that keeps the trace of ctx but is not cancelled with it, e.g. for work that is shared by
+// several requests.
+func Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"poi-service/cmd/data"
+	"poi-service/cmd/handler"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record installs a tracer provider that keeps the ended spans in memory.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func Test_poiHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	recorder := record(t)
+
+	next := handler.NewMockPoiHandler(ctrl)
+	handlerToTest := NewPoiHandler(next)
+	assert.Nil(t, NewPoiHandler(nil))
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	next.EXPECT().Get(gomock.Any(), data.Id("abc")).DoAndReturn(func(ctx context.Context, _ data.Id) (data.Poi, error) {
+		// the following layers continue the trace
+		assert.True(t, trace.SpanContextFromContext(ctx).IsValid())
+		return data.Poi{Name: "Dresden"}, nil
+	})
+	next.EXPECT().Delete(gomock.Any(), data.Id("abc")).Return(handler.PoiNotFound)
+
+	poi, err := handlerToTest.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "Dresden", poi.Name)
+	assert.Equal(t, handler.PoiNotFound, handlerToTest.Delete(ctx, "abc"))
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	get, del := spans[0], spans[1]
+	assert.Equal(t, "PoiHandler.Get", get.Name())
+	assert.Equal(t, parent.SpanContext().TraceID(), get.SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), get.Parent().SpanID())
+	assert.Contains(t, get.Attributes(), attribute.String("poi.id", "abc"))
+	assert.Equal(t, codes.Unset, get.Status().Code)
+
+	assert.Equal(t, "PoiHandler.Delete", del.Name())
+	assert.Equal(t, codes.Error, del.Status().Code)
+	assert.Len(t, del.Events(), 1, "the error is recorded")
+}
+
+func TestDetach(t *testing.T) {
+	record(t)
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	detached := Detach(ctx)
+	assert.NoError(t, detached.Err())
+	assert.Equal(t, span.SpanContext(), trace.SpanContextFromContext(detached))
+}
+
+func TestSetup(t *testing.T) {
+	_, err := Setup(context.Background(), "test", "zipkin")
+	assert.True(t, errors.Is(err, UnknownExporter))
+
+	shutdown, err := Setup(context.Background(), "test", ExporterNone)
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}